@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/security"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotQuestion = errors.New("post is not a question")
+	ErrNotAnswer   = errors.New("only top-level comments on a question are answers")
+	ErrOwnAnswer   = errors.New("you cannot upvote your own answer")
+)
+
+// QuestionStatus - answered questions have an accepted answer
+type QuestionStatus string
+
+const (
+	QuestionAnswered   QuestionStatus = "answered"
+	QuestionUnanswered QuestionStatus = "unanswered"
+)
+
+// professionalRoles - roles that earn reputation for accepted answers
+var professionalRoles = []security.Role{security.Contractor, security.Manufacturer, security.Designer}
+
+// AnswerVote - one upvote per user and answer, unique on (comment_id, user_id)
+type AnswerVote struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CommentID primitive.ObjectID `json:"comment_id" bson:"comment_id"`
+	PostID    primitive.ObjectID `json:"post_id" bson:"post_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// questionCondition - questions with or without an accepted answer
+func questionCondition(status QuestionStatus) bson.M {
+	accepted := bson.M{"$exists": status == QuestionAnswered}
+	return bson.M{"question": true, "accepted_answer_id": accepted}
+}
+
+// IsAnswer - top-level comments on a question
+func IsAnswer(post *Post, comment *Comment) bool {
+	return post.Question && comment.PostID == post.ID && comment.ParentID == nil
+}
+
+// ToggleUpvote - upvote or take back the upvote of an answer, vote_count changes in the same transaction
+func (c *CommentStorage) ToggleUpvote(ctx context.Context, userID primitive.ObjectID, answer *Comment) (bool, error) {
+	if answer.UserID == userID {
+		return false, ErrOwnAnswer
+	}
+
+	client := c.collection.Database().Client()
+
+	var upvoted bool
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		filter := bson.M{"comment_id": answer.ID, "user_id": userID}
+
+		result, err := c.answerVoteCollection.DeleteOne(sessCtx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove upvote: %w", err)
+		}
+
+		delta := -1
+		upvoted = result.DeletedCount == 0
+		if upvoted {
+			vote := AnswerVote{ID: primitive.NewObjectID(), CommentID: answer.ID, PostID: answer.PostID, UserID: userID, CreatedAt: time.Now()}
+			if _, err := c.answerVoteCollection.InsertOne(sessCtx, vote); err != nil {
+				return nil, fmt.Errorf("failed to upvote answer: %w", err)
+			}
+			delta = 1
+		}
+
+		if _, err := c.collection.UpdateByID(sessCtx, answer.ID, bson.M{"$inc": bson.M{"vote_count": delta}}); err != nil {
+			return nil, fmt.Errorf("failed to update vote count: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if err := withTransaction(ctxTimeout, client, txnFunc); err != nil {
+		return false, err
+	}
+
+	return upvoted, nil
+}
+
+// GetUpvotedAnswerIDs - answers of the page the user has upvoted, resolved in one query
+func (c *CommentStorage) GetUpvotedAnswerIDs(ctx context.Context, userID primitive.ObjectID, commentIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	upvoted := make(map[primitive.ObjectID]bool)
+	if len(commentIDs) == 0 {
+		return upvoted, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	ids, err := c.answerVoteCollection.Distinct(ctxTimeout, "comment_id", bson.M{
+		"user_id":    userID,
+		"comment_id": bson.M{"$in": commentIDs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upvoted answers: %w", err)
+	}
+
+	for _, id := range ids {
+		if objID, ok := id.(primitive.ObjectID); ok {
+			upvoted[objID] = true
+		}
+	}
+
+	return upvoted, nil
+}
+
+// AcceptAnswer - mark the answer as accepted, replacing any previously accepted answer of the question
+func (c *CommentStorage) AcceptAnswer(ctx context.Context, post *Post, answer *Comment) error {
+	if !IsAnswer(post, answer) {
+		return ErrNotAnswer
+	}
+
+	client := c.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var previous Post
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+		err := c.postStorage.collection.FindOneAndUpdate(sessCtx,
+			bson.M{"_id": post.ID, "question": true, "deleted_at": notDeleted()},
+			bson.M{"$set": bson.M{"accepted_answer_id": answer.ID}},
+			opts,
+		).Decode(&previous)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrNotQuestion
+			}
+			return nil, fmt.Errorf("failed to accept answer: %w", err)
+		}
+
+		if previous.AcceptedAnswerID != nil && *previous.AcceptedAnswerID == answer.ID {
+			return nil, nil
+		}
+
+		if err := c.unaccept(sessCtx, previous.AcceptedAnswerID); err != nil {
+			return nil, err
+		}
+
+		result, err := c.collection.UpdateOne(sessCtx,
+			bson.M{"_id": answer.ID, "deleted_at": notDeleted()},
+			bson.M{"$set": bson.M{"accepted": true}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to accept answer: %w", err)
+		}
+
+		if result.MatchedCount == 0 {
+			return nil, ErrCommentNotFound
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// UnacceptAnswer - the question goes back to unanswered
+func (c *CommentStorage) UnacceptAnswer(ctx context.Context, post *Post) error {
+	client := c.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var previous Post
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+		err := c.postStorage.collection.FindOneAndUpdate(sessCtx,
+			bson.M{"_id": post.ID, "question": true},
+			bson.M{"$unset": bson.M{"accepted_answer_id": ""}},
+			opts,
+		).Decode(&previous)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrNotQuestion
+			}
+			return nil, fmt.Errorf("failed to unaccept answer: %w", err)
+		}
+
+		return nil, c.unaccept(sessCtx, previous.AcceptedAnswerID)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// unaccept - clear the accepted flag, no-op without an accepted answer
+func (c *CommentStorage) unaccept(ctx context.Context, answerID *primitive.ObjectID) error {
+	if answerID == nil {
+		return nil
+	}
+
+	if _, err := c.collection.UpdateByID(ctx, *answerID, bson.M{"$unset": bson.M{"accepted": ""}}); err != nil {
+		return fmt.Errorf("failed to unaccept answer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,469 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrBoardNotFound     = errors.New("board not found")
+	ErrBoardItemNotFound = errors.New("board item not found")
+	ErrAlreadySaved      = errors.New("post is already saved in this board")
+	ErrInviteNotPending  = errors.New("no pending invite for this board")
+)
+
+type BoardVisibility string
+
+const (
+	BoardPublic        BoardVisibility = "public"
+	BoardPrivate       BoardVisibility = "private"
+	BoardCollaborators BoardVisibility = "collaborators"
+)
+
+type Board struct {
+	ID            primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
+	OwnerID       primitive.ObjectID   `json:"owner_id" bson:"owner_id"`
+	Name          string               `json:"name" bson:"name"`
+	Description   string               `json:"description,omitempty" bson:"description,omitempty"`
+	Visibility    BoardVisibility      `json:"visibility" bson:"visibility"`
+	Collaborators []primitive.ObjectID `json:"collaborators" bson:"collaborators"`
+	Invited       []primitive.ObjectID `json:"invited" bson:"invited"` // pending collaborator invites
+	ItemCount     int64                `json:"item_count" bson:"item_count"`
+	CreatedAt     time.Time            `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time            `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// BoardItem - a post saved into a board, items are ordered by position within the board
+type BoardItem struct {
+	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	BoardID   primitive.ObjectID `json:"board_id" bson:"board_id"`
+	PostID    primitive.ObjectID `json:"post_id" bson:"post_id"`
+	SavedBy   primitive.ObjectID `json:"saved_by" bson:"saved_by"`
+	Note      string             `json:"note,omitempty" bson:"note,omitempty"`
+	Position  int                `json:"position" bson:"position"`
+	CreatedAt time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Board) IsCollaborator(userID primitive.ObjectID) bool {
+	return containsID(b.Collaborators, userID)
+}
+
+func (b *Board) IsInvited(userID primitive.ObjectID) bool {
+	return containsID(b.Invited, userID)
+}
+
+// CanView - private boards are only visible to the owner
+func (b *Board) CanView(userID primitive.ObjectID) bool {
+	switch {
+	case b.OwnerID == userID:
+		return true
+	case b.Visibility == BoardPublic:
+		return true
+	case b.Visibility == BoardCollaborators:
+		return b.IsCollaborator(userID)
+	default:
+		return false
+	}
+}
+
+// CanEdit - collaborators can curate items unless the owner made the board private
+func (b *Board) CanEdit(userID primitive.ObjectID) bool {
+	if b.OwnerID == userID {
+		return true
+	}
+	return b.Visibility != BoardPrivate && b.IsCollaborator(userID)
+}
+
+type BoardStorage struct {
+	collection     *mongo.Collection
+	itemCollection *mongo.Collection
+}
+
+func (b *BoardStorage) Create(ctx context.Context, board *Board) error {
+	now := time.Now()
+	board.ID = primitive.NewObjectID()
+	board.Collaborators = []primitive.ObjectID{}
+	board.Invited = []primitive.ObjectID{}
+	board.ItemCount = 0
+	board.CreatedAt = now
+	board.UpdatedAt = now
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if _, err := b.collection.InsertOne(ctxTimeout, board); err != nil {
+		return fmt.Errorf("failed to create board: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BoardStorage) GetByID(ctx context.Context, boardID string) (*Board, error) {
+	objID, err := primitive.ObjectIDFromHex(boardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert boardID to ObjectID: %w", err)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var board Board
+	err = b.collection.FindOne(ctxTimeout, bson.M{"_id": objID}).Decode(&board)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrBoardNotFound
+		}
+		return nil, fmt.Errorf("board query failed: %w", err)
+	}
+
+	return &board, nil
+}
+
+// GetByUserID - boards owned by or shared with a user, filtered to what the viewer can see
+func (b *BoardStorage) GetByUserID(ctx context.Context, userID, viewerID primitive.ObjectID) ([]Board, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{"owner_id": userID}
+	if userID == viewerID {
+		filter = bson.M{"$or": []bson.M{
+			{"owner_id": userID},
+			{"collaborators": userID},
+		}}
+	}
+
+	cursor, err := b.collection.Find(ctxTimeout, filter, options.Find().SetSort(bson.M{"updated_at": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find boards: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	var boards []Board
+	if err := cursor.All(ctxTimeout, &boards); err != nil {
+		return nil, fmt.Errorf("failed to decode boards: %w", err)
+	}
+
+	visible := make([]Board, 0, len(boards))
+	for _, board := range boards {
+		if board.CanView(viewerID) {
+			visible = append(visible, board)
+		}
+	}
+
+	return visible, nil
+}
+
+func (b *BoardStorage) GetInvites(ctx context.Context, userID primitive.ObjectID) ([]Board, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	cursor, err := b.collection.Find(ctxTimeout, bson.M{"invited": userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find board invites: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	boards := make([]Board, 0)
+	if err := cursor.All(ctxTimeout, &boards); err != nil {
+		return nil, fmt.Errorf("failed to decode boards: %w", err)
+	}
+
+	return boards, nil
+}
+
+func (b *BoardStorage) Update(ctx context.Context, board *Board) error {
+	board.UpdatedAt = time.Now()
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":        board.Name,
+			"description": board.Description,
+			"visibility":  board.Visibility,
+			"updated_at":  board.UpdatedAt,
+		},
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if _, err := b.collection.UpdateByID(ctxTimeout, board.ID, update); err != nil {
+		return fmt.Errorf("failed to update board: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BoardStorage) Delete(ctx context.Context, boardID primitive.ObjectID) error {
+	client := b.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := b.collection.DeleteOne(sessCtx, bson.M{"_id": boardID}); err != nil {
+			return nil, fmt.Errorf("failed to delete board: %w", err)
+		}
+
+		if _, err := b.itemCollection.DeleteMany(sessCtx, bson.M{"board_id": boardID}); err != nil {
+			return nil, fmt.Errorf("failed to delete board items: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+// Invite - add user to the pending invites, no-op if already invited or collaborating
+func (b *BoardStorage) Invite(ctx context.Context, boardID, userID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := b.collection.UpdateOne(ctxTimeout,
+		bson.M{"_id": boardID, "collaborators": bson.M{"$ne": userID}},
+		bson.M{"$addToSet": bson.M{"invited": userID}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to invite collaborator: %w", err)
+	}
+
+	return nil
+}
+
+// RespondInvite - move an invited user to collaborators when accepted, drop the invite either way
+func (b *BoardStorage) RespondInvite(ctx context.Context, boardID, userID primitive.ObjectID, accept bool) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	update := bson.M{"$pull": bson.M{"invited": userID}}
+	if accept {
+		update["$addToSet"] = bson.M{"collaborators": userID}
+	}
+
+	result, err := b.collection.UpdateOne(ctxTimeout, bson.M{"_id": boardID, "invited": userID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to respond to board invite: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrInviteNotPending
+	}
+
+	return nil
+}
+
+// RemoveCollaborator - also cancels a pending invite for the user
+func (b *BoardStorage) RemoveCollaborator(ctx context.Context, boardID, userID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := b.collection.UpdateByID(ctxTimeout, boardID, bson.M{
+		"$pull": bson.M{"collaborators": userID, "invited": userID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove collaborator: %w", err)
+	}
+
+	return nil
+}
+
+// AddItem - append a post to the end of the board
+func (b *BoardStorage) AddItem(ctx context.Context, item *BoardItem) error {
+	client := b.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var last BoardItem
+		err := b.itemCollection.FindOne(sessCtx,
+			bson.M{"board_id": item.BoardID},
+			options.FindOne().SetSort(bson.M{"position": -1}),
+		).Decode(&last)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("failed to get last board item: %w", err)
+		}
+
+		item.ID = primitive.NewObjectID()
+		item.Position = 0
+		if err == nil {
+			item.Position = last.Position + 1
+		}
+		item.CreatedAt = time.Now()
+
+		if _, err := b.itemCollection.InsertOne(sessCtx, item); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, ErrAlreadySaved
+			}
+			return nil, fmt.Errorf("failed to save post to board: %w", err)
+		}
+
+		_, err = b.collection.UpdateByID(sessCtx, item.BoardID, bson.M{
+			"$inc": bson.M{"item_count": 1},
+			"$set": bson.M{"updated_at": item.CreatedAt},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to increment item count: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+func (b *BoardStorage) GetItem(ctx context.Context, boardID primitive.ObjectID, itemID string) (*BoardItem, error) {
+	objID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert itemID to ObjectID: %w", err)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var item BoardItem
+	err = b.itemCollection.FindOne(ctxTimeout, bson.M{"_id": objID, "board_id": boardID}).Decode(&item)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrBoardItemNotFound
+		}
+		return nil, fmt.Errorf("board item query failed: %w", err)
+	}
+
+	return &item, nil
+}
+
+// GetItems - items in board order, cursor is the position of the last item on the previous page
+func (b *BoardStorage) GetItems(ctx context.Context, boardID primitive.ObjectID, cursor *int, limit int) ([]BoardItem, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{"board_id": boardID}
+	if cursor != nil {
+		filter["position"] = bson.M{"$gt": *cursor}
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"position": 1}).
+		SetLimit(int64(limit))
+
+	cur, err := b.itemCollection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find board items: %w", err)
+	}
+	defer cur.Close(ctxTimeout)
+
+	items := make([]BoardItem, 0)
+	if err := cur.All(ctxTimeout, &items); err != nil {
+		return nil, fmt.Errorf("failed to decode board items: %w", err)
+	}
+
+	return items, nil
+}
+
+func (b *BoardStorage) UpdateItemNote(ctx context.Context, item *BoardItem) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if _, err := b.itemCollection.UpdateByID(ctxTimeout, item.ID, bson.M{"$set": bson.M{"note": item.Note}}); err != nil {
+		return fmt.Errorf("failed to update board item: %w", err)
+	}
+
+	return nil
+}
+
+func (b *BoardStorage) RemoveItem(ctx context.Context, item *BoardItem) error {
+	client := b.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := b.itemCollection.DeleteOne(sessCtx, bson.M{"_id": item.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove board item: %w", err)
+		}
+		if result.DeletedCount == 0 {
+			return nil, ErrBoardItemNotFound
+		}
+
+		_, err = b.collection.UpdateByID(sessCtx, item.BoardID, bson.M{"$inc": bson.M{"item_count": -1}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrement item count: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+// Reorder - itemIDs must contain every item of the board exactly once, in the new order
+func (b *BoardStorage) Reorder(ctx context.Context, boardID primitive.ObjectID, itemIDs []primitive.ObjectID) error {
+	client := b.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		count, err := b.itemCollection.CountDocuments(sessCtx, bson.M{"board_id": boardID, "_id": bson.M{"$in": itemIDs}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count board items: %w", err)
+		}
+
+		total, err := b.itemCollection.CountDocuments(sessCtx, bson.M{"board_id": boardID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count board items: %w", err)
+		}
+
+		if int(count) != len(itemIDs) || count != total {
+			return nil, ErrBoardItemNotFound
+		}
+
+		models := make([]mongo.WriteModel, 0, len(itemIDs))
+		for i, id := range itemIDs {
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": id, "board_id": boardID}).
+				SetUpdate(bson.M{"$set": bson.M{"position": i}}))
+		}
+
+		if _, err := b.itemCollection.BulkWrite(sessCtx, models); err != nil {
+			return nil, fmt.Errorf("failed to reorder board items: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+// GetSavedPostIDs - which of the posts the user has saved into any board
+func (b *BoardStorage) GetSavedPostIDs(ctx context.Context, userID primitive.ObjectID, postIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	saved := make(map[primitive.ObjectID]bool)
+	if len(postIDs) == 0 {
+		return saved, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	ids, err := b.itemCollection.Distinct(ctxTimeout, "post_id", bson.M{
+		"saved_by": userID,
+		"post_id":  bson.M{"$in": postIDs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved posts: %w", err)
+	}
+
+	for _, id := range ids {
+		if objID, ok := id.(primitive.ObjectID); ok {
+			saved[objID] = true
+		}
+	}
+
+	return saved, nil
+}
@@ -24,30 +24,97 @@ type Collection struct {
 		GetAll(ctx context.Context) (*[]User, error)
 		GetByID(ctx context.Context, userID string) (*User, error)
 		GetByEmail(ctx context.Context, email string) (*User, error)
-		ValidateUsername(ctx context.Context, mentions []string) ([]string, error)
+		GetIDsByUsernames(ctx context.Context, usernames []string) (map[string]primitive.ObjectID, error)
+		Search(ctx context.Context, query string, cq CursorQuery) ([]UserSummary, error)
+		Autocomplete(ctx context.Context, prefix string, limit int) ([]UserSummary, error)
 		AddRating(ctx context.Context, user *User, score int) error
 		ReduceRating(ctx context.Context, user *User, score int) error
 		Delete(ctx context.Context, userID primitive.ObjectID) error
+		PinPost(ctx context.Context, userID, postID primitive.ObjectID) error
+		UnpinPost(ctx context.Context, userID, postID primitive.ObjectID) error
+		SetPortfolio(ctx context.Context, userID primitive.ObjectID, sections []PortfolioSection) error
 	}
 
 	Post interface {
 		Create(ctx context.Context, p *Post) error
 		GetFeed(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, error)
 		GetTrending(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, error)
-		GetByID(ctx context.Context, postID string) (*Post, error)
-		GetByUserID(ctx context.Context, userID primitive.ObjectID, cq CursorQuery) ([]PostWithLikeStatus, error)
-		GetCountByUserID(ctx context.Context, userID primitive.ObjectID) (int, error)
+		RefreshTrendingScores(ctx context.Context) error
+		GetForYou(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, string, error)
+		GetByID(ctx context.Context, postID string, viewer *User) (*Post, error)
+		GetByIDs(ctx context.Context, postIDs []primitive.ObjectID, viewer *User) ([]Post, error)
+		GetByUserID(ctx context.Context, userID primitive.ObjectID, viewer *User, cq CursorQuery) ([]PostWithLikeStatus, error)
+		GetCountByUserID(ctx context.Context, userID primitive.ObjectID, viewer *User) (int, error)
 		Search(ctx context.Context, user *User, query string, cq CursorQuery) ([]PostWithLikeStatus, error)
 		Update(ctx context.Context, post *Post) error
 		ToggleLike(ctx context.Context, userID primitive.ObjectID, post *Post) (bool, error)
+		GetLikedPostIDs(ctx context.Context, userID primitive.ObjectID, postIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error)
+		GetLikes(ctx context.Context, postID primitive.ObjectID, cq CursorQuery) ([]PostLiker, error)
 		IncrementCommentCount(ctx context.Context, postID primitive.ObjectID) error
+		RespondCollaboration(ctx context.Context, postID, userID primitive.ObjectID, confirm bool) error
+		GetPendingCollaborations(ctx context.Context, userID primitive.ObjectID) ([]Post, error)
+		GetDrafts(ctx context.Context, userID primitive.ObjectID, cq CursorQuery) ([]Post, error)
+		Publish(ctx context.Context, postID primitive.ObjectID) (time.Time, error)
+		PublishDue(ctx context.Context, now time.Time) ([]primitive.ObjectID, error)
+		GetRevisions(ctx context.Context, postID primitive.ObjectID, cq CursorQuery) ([]PostRevision, error)
+		GetRevision(ctx context.Context, postID primitive.ObjectID, version int64) (*PostRevision, error)
+		Restore(ctx context.Context, postID, userID primitive.ObjectID) error
+		GetTrash(ctx context.Context, userID primitive.ObjectID) ([]Post, error)
+		GetExpiredTrash(ctx context.Context, cutoff time.Time, limit int) ([]Post, error)
+		ImageKeys(ctx context.Context, post *Post) ([]string, error)
+		Purge(ctx context.Context, postID primitive.ObjectID) error
 		Delete(ctx context.Context, postID string) error
+		Share(ctx context.Context, post *Post) error
+		Unrepost(ctx context.Context, userID, originalID primitive.ObjectID) (primitive.ObjectID, error)
+		SetShareToken(ctx context.Context, postID primitive.ObjectID, token string) error
+		RevokeShareToken(ctx context.Context, postID primitive.ObjectID) error
+		GetByShareToken(ctx context.Context, token string) (*Post, error)
+		Vote(ctx context.Context, postID, userID primitive.ObjectID, options []int) error
+		Unvote(ctx context.Context, postID, userID primitive.ObjectID) error
+		GetPollVotes(ctx context.Context, userID primitive.ObjectID, postIDs []primitive.ObjectID) (map[primitive.ObjectID][]int, error)
 	}
 
 	Comment interface {
 		Create(ctx context.Context, c *Comment) (CommentWithParentAndUser, error)
 		Exists(context.Context, primitive.ObjectID) (bool, error)
-		GetByPostID(ctx context.Context, postID primitive.ObjectID) ([]CommentWithParentAndUser, error)
+		GetByID(ctx context.Context, commentID primitive.ObjectID) (*Comment, error)
+		GetTopLevel(ctx context.Context, postID primitive.ObjectID, cq CursorQuery) ([]CommentWithParentAndUser, error)
+		GetReplies(ctx context.Context, postID, parentID primitive.ObjectID, cq CursorQuery) ([]CommentWithParentAndUser, error)
+		Update(ctx context.Context, comment *Comment) error
+		Delete(ctx context.Context, comment *Comment, deletedBy primitive.ObjectID) error
+		Restore(ctx context.Context, commentID, userID primitive.ObjectID) error
+		GetTrash(ctx context.Context, userID primitive.ObjectID) ([]Comment, error)
+		Purge(ctx context.Context, cutoff time.Time) (int64, error)
+		ToggleUpvote(ctx context.Context, userID primitive.ObjectID, answer *Comment) (bool, error)
+		GetUpvotedAnswerIDs(ctx context.Context, userID primitive.ObjectID, commentIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error)
+		AcceptAnswer(ctx context.Context, post *Post, answer *Comment) error
+		UnacceptAnswer(ctx context.Context, post *Post) error
+	}
+
+	Reaction interface {
+		React(ctx context.Context, target ReactionTarget, targetID, userID primitive.ObjectID, reactionType ReactionType) (ReactionCounts, error)
+		Unreact(ctx context.Context, target ReactionTarget, targetID, userID primitive.ObjectID) (ReactionCounts, error)
+		GetUserReactions(ctx context.Context, target ReactionTarget, userID primitive.ObjectID, targetIDs []primitive.ObjectID) (map[primitive.ObjectID]ReactionType, error)
+	}
+
+	Tag interface {
+		Use(ctx context.Context, tags []string) error
+		GetByName(ctx context.Context, name string) (*Tag, error)
+		Autocomplete(ctx context.Context, prefix string, limit int) ([]Tag, error)
+		Search(ctx context.Context, query string, cq CursorQuery) ([]Tag, error)
+		GetTrending(ctx context.Context, since time.Time, limit int) ([]TrendingTag, error)
+		RefreshCounts(ctx context.Context) error
+		Follow(ctx context.Context, userID primitive.ObjectID, name string) error
+		Unfollow(ctx context.Context, userID primitive.ObjectID, name string) error
+		IsFollowing(ctx context.Context, userID primitive.ObjectID, name string) (bool, error)
+		GetFollowed(ctx context.Context, userID primitive.ObjectID) ([]string, error)
+	}
+
+	Reputation interface {
+		Record(ctx context.Context, userID primitive.ObjectID, event ReputationEvent, key string) error
+		Revoke(ctx context.Context, key string) error
+		GetEntries(ctx context.Context, userID primitive.ObjectID, cq CursorQuery) ([]ReputationEntry, error)
+		Recompute(ctx context.Context) error
 	}
 
 	Review interface {
@@ -65,32 +132,121 @@ type Collection struct {
 		UnfollowUser(ctx context.Context, followerID, followingID primitive.ObjectID) error
 	}
 
+	Timeline interface {
+		FanOut(ctx context.Context, postID primitive.ObjectID) error
+		Remove(ctx context.Context, postID primitive.ObjectID) error
+		Backfill(ctx context.Context, followerID, followeeID primitive.ObjectID) error
+		RemoveAuthor(ctx context.Context, followerID, followeeID primitive.ObjectID) error
+		Get(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, string, error)
+	}
+
 	Invite interface {
 		CreateTTLIndex(ctx context.Context)
 		Create(ctx context.Context, userID primitive.ObjectID, token string, inviteExp time.Duration) error
 		Delete(ctx context.Context, userID primitive.ObjectID) error
 	}
+
+	Product interface {
+		Create(ctx context.Context, product *Product) error
+		GetByID(ctx context.Context, productID string) (*Product, error)
+		Browse(ctx context.Context, pq ProductQuery) ([]Product, error)
+		GetFacets(ctx context.Context, pq ProductQuery) (*ProductFacets, error)
+		Update(ctx context.Context, product *Product) error
+		Delete(ctx context.Context, productID primitive.ObjectID) error
+	}
+
+	Board interface {
+		Create(ctx context.Context, board *Board) error
+		GetByID(ctx context.Context, boardID string) (*Board, error)
+		GetByUserID(ctx context.Context, userID, viewerID primitive.ObjectID) ([]Board, error)
+		GetInvites(ctx context.Context, userID primitive.ObjectID) ([]Board, error)
+		Update(ctx context.Context, board *Board) error
+		Delete(ctx context.Context, boardID primitive.ObjectID) error
+		Invite(ctx context.Context, boardID, userID primitive.ObjectID) error
+		RespondInvite(ctx context.Context, boardID, userID primitive.ObjectID, accept bool) error
+		RemoveCollaborator(ctx context.Context, boardID, userID primitive.ObjectID) error
+		AddItem(ctx context.Context, item *BoardItem) error
+		GetItem(ctx context.Context, boardID primitive.ObjectID, itemID string) (*BoardItem, error)
+		GetItems(ctx context.Context, boardID primitive.ObjectID, cursor *int, limit int) ([]BoardItem, error)
+		UpdateItemNote(ctx context.Context, item *BoardItem) error
+		RemoveItem(ctx context.Context, item *BoardItem) error
+		Reorder(ctx context.Context, boardID primitive.ObjectID, itemIDs []primitive.ObjectID) error
+		GetSavedPostIDs(ctx context.Context, userID primitive.ObjectID, postIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error)
+	}
+
+	Project interface {
+		Create(ctx context.Context, project *Project, owner *User) error
+		GetByID(ctx context.Context, projectID string) (*Project, error)
+		GetByMemberID(ctx context.Context, userID primitive.ObjectID) ([]Project, error)
+		Update(ctx context.Context, project *Project, actorID primitive.ObjectID) error
+		Delete(ctx context.Context, projectID primitive.ObjectID) error
+		AddMember(ctx context.Context, projectID, actorID primitive.ObjectID, member ProjectMember) error
+		UpdateMemberRole(ctx context.Context, projectID, actorID, userID primitive.ObjectID, role ProjectRole) error
+		RemoveMember(ctx context.Context, projectID, actorID, userID primitive.ObjectID) error
+		CreateUpdate(ctx context.Context, update *ProjectUpdate) error
+		GetUpdates(ctx context.Context, projectID primitive.ObjectID, cq CursorQuery) ([]ProjectUpdate, error)
+		GetActivity(ctx context.Context, projectID primitive.ObjectID, cq CursorQuery) ([]ProjectActivity, error)
+		CreateTask(ctx context.Context, task *ProjectTask) error
+		GetTask(ctx context.Context, projectID primitive.ObjectID, taskID string) (*ProjectTask, error)
+		GetTasks(ctx context.Context, projectID primitive.ObjectID) ([]ProjectTask, error)
+		UpdateTask(ctx context.Context, task *ProjectTask, actorID primitive.ObjectID, justCompleted bool) error
+		DeleteTask(ctx context.Context, task *ProjectTask, actorID primitive.ObjectID) error
+		AddDocument(ctx context.Context, document *ProjectDocument) error
+		GetDocument(ctx context.Context, projectID primitive.ObjectID, documentID string) (*ProjectDocument, error)
+		GetDocuments(ctx context.Context, projectID primitive.ObjectID) ([]ProjectDocument, error)
+		RemoveDocument(ctx context.Context, document *ProjectDocument, actorID primitive.ObjectID) error
+	}
 }
 
 func NewMongoDBCollections(dbConn *db.DBConnection) Collection {
 	userCollection := dbConn.GetCollection("user")
 	postCollection := dbConn.GetCollection("post")
+	postRevisionCollection := dbConn.GetCollection("post_revision")
+	reactionCollection := dbConn.GetCollection("reaction")
+	likeCollection := dbConn.GetCollection("like")
+	tagCollection := dbConn.GetCollection("tag")
+	pollVoteCollection := dbConn.GetCollection("poll_vote")
+	answerVoteCollection := dbConn.GetCollection("answer_vote")
+	reputationCollection := dbConn.GetCollection("reputation_entry")
+	tagFollowCollection := dbConn.GetCollection("tag_follow")
 	commentCollection := dbConn.GetCollection("comment")
 	inviteCollection := dbConn.GetCollection("invite")
 	reviewCollection := dbConn.GetCollection("review")
 	followCollection := dbConn.GetCollection("follow")
+	timelineCollection := dbConn.GetCollection("timeline")
+	forYouSessionCollection := dbConn.GetCollection("for_you_session")
+	productCollection := dbConn.GetCollection("product")
+	boardCollection := dbConn.GetCollection("board")
+	boardItemCollection := dbConn.GetCollection("board_item")
+	projectCollection := dbConn.GetCollection("project")
+	projectUpdateCollection := dbConn.GetCollection("project_update")
+	projectTaskCollection := dbConn.GetCollection("project_task")
+	projectDocumentCollection := dbConn.GetCollection("project_document")
+	projectActivityCollection := dbConn.GetCollection("project_activity")
 
 	userStorage := &UserStorage{
 		collection:    userCollection,
 		inviteStorage: &InviteStorage{collection: inviteCollection},
 	}
 	postStorage := &PostStorage{
-		collection: postCollection,
+		collection:           postCollection,
+		revisionCollection:   postRevisionCollection,
+		commentCollection:    commentCollection,
+		reactionCollection:   reactionCollection,
+		likeCollection:       likeCollection,
+		followCollection:     followCollection,
+		pollVoteCollection:   pollVoteCollection,
+		answerVoteCollection: answerVoteCollection,
+		timelineCollection:   timelineCollection,
+		userCollection:       userCollection,
+		forYouCollection:     forYouSessionCollection,
 	}
 	commentStorage := &CommentStorage{
-		collection:  commentCollection,
-		userStorage: &UserStorage{collection: userCollection},
-		postStorage: &PostStorage{collection: postCollection},
+		collection:           commentCollection,
+		reactionCollection:   reactionCollection,
+		answerVoteCollection: answerVoteCollection,
+		userStorage:          &UserStorage{collection: userCollection},
+		postStorage:          &PostStorage{collection: postCollection},
 	}
 	inviteStorage := &InviteStorage{
 		collection: inviteCollection,
@@ -104,16 +260,71 @@ func NewMongoDBCollections(dbConn *db.DBConnection) Collection {
 	}
 
 	followStorage := &FollowStorage{
-		collection: followCollection,
+		collection:     followCollection,
+		userCollection: userCollection,
+	}
+
+	timelineStorage := &TimelineStorage{
+		collection:       timelineCollection,
+		userCollection:   userCollection,
+		followCollection: followCollection,
+		postStorage:      postStorage,
+	}
+
+	productStorage := &ProductStorage{
+		collection: productCollection,
+	}
+
+	boardStorage := &BoardStorage{
+		collection:     boardCollection,
+		itemCollection: boardItemCollection,
+	}
+
+	reactionStorage := &ReactionStorage{
+		collection:        reactionCollection,
+		postCollection:    postCollection,
+		commentCollection: commentCollection,
+		likeCollection:    likeCollection,
+	}
+
+	tagStorage := &TagStorage{
+		collection:       tagCollection,
+		followCollection: tagFollowCollection,
+		postCollection:   postCollection,
+	}
+
+	reputationStorage := &ReputationStorage{
+		collection:        reputationCollection,
+		userCollection:    userCollection,
+		postCollection:    postCollection,
+		likeCollection:    likeCollection,
+		commentCollection: commentCollection,
+		reviewCollection:  reviewCollection,
+		followCollection:  followCollection,
+	}
+
+	projectStorage := &ProjectStorage{
+		collection:         projectCollection,
+		updateCollection:   projectUpdateCollection,
+		taskCollection:     projectTaskCollection,
+		documentCollection: projectDocumentCollection,
+		activityCollection: projectActivityCollection,
 	}
 
 	return Collection{
-		User:    userStorage,
-		Post:    postStorage,
-		Comment: commentStorage,
-		Invite:  inviteStorage,
-		Review:  reviewStorage,
-		Follow:  followStorage,
+		User:       userStorage,
+		Post:       postStorage,
+		Comment:    commentStorage,
+		Invite:     inviteStorage,
+		Review:     reviewStorage,
+		Follow:     followStorage,
+		Timeline:   timelineStorage,
+		Product:    productStorage,
+		Board:      boardStorage,
+		Project:    projectStorage,
+		Reaction:   reactionStorage,
+		Tag:        tagStorage,
+		Reputation: reputationStorage,
 	}
 }
 
@@ -145,6 +356,23 @@ func EnsureIndexes(ctx context.Context, c Collection) error {
 		{
 			Keys: bson.D{{Key: "created_at", Value: 1}}, // sorting/filtering by creation date
 		},
+		{
+			Keys: bson.D{{Key: "role", Value: 1}, {Key: "profile.location", Value: 1}, {Key: "reputation", Value: -1}}, // top professionals per location
+		},
+		{
+			Keys: bson.D{{Key: "follower_count", Value: 1}}, // large accounts read at request time
+		},
+		{
+			Keys: bson.D{{Key: "username_lower", Value: 1}}, // mention typeahead
+		},
+		{
+			Keys: bson.D{{Key: "username", Value: "text"}, {Key: "role", Value: "text"}, {Key: "profile.location", Value: "text"}, {Key: "profile.bio", Value: "text"}}, // people search
+			Options: options.Index().
+				SetName("user_text").
+				SetWeights(bson.M{"username": 10, "role": 5, "profile.location": 5, "profile.bio": 1}).
+				SetDefaultLanguage("english").
+				SetLanguageOverride("text_language"),
+		},
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create user indexes: %w", err)
@@ -152,22 +380,166 @@ func EnsureIndexes(ctx context.Context, c Collection) error {
 
 	//Post collection
 	_, err = c.Post.(*PostStorage).collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
-		{Keys: bson.D{{Key: "user_id", Value: 1}}},     // find posts by user
-		{Keys: bson.D{{Key: "user_role", Value: 1}}},   // filter by user role
-		{Keys: bson.D{{Key: "created_at", Value: -1}}}, // sorting feed
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},                                              // find posts by user
+		{Keys: bson.D{{Key: "user_role", Value: 1}}},                                            // filter by user role
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},                                          // sorting feed
+		{Keys: bson.D{{Key: "type", Value: 1}}},                                                 // filter by post type
+		{Keys: bson.D{{Key: "showcase.collaborators.user_id", Value: 1}}},                       // pending collaborations
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}}},                   // author's drafts
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "publish_at", Value: 1}}},                // scheduled publishing
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}, Options: options.Index().SetSparse(true)}, // trash and purge
+		{Keys: bson.D{{Key: "mentions", Value: 1}}},                                             // mentioned me filter
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "repost_of", Value: 1}}, // one repost per user and post
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"type": PostRepost}),
+		},
+		{
+			Keys:    bson.D{{Key: "share_token", Value: 1}}, // unlisted share links
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{Keys: bson.D{{Key: "visibility", Value: 1}}},                                                         // public feed
+		{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "published_at", Value: -1}, {Key: "_id", Value: -1}}},    // tag pages and followed tags
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "published_at", Value: -1}, {Key: "_id", Value: -1}}},  // feed pages and trending tags window
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "published_at", Value: -1}, {Key: "_id", Value: -1}}}, // profile pages, timeline backfill and large accounts
+		{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "tags", Value: "text"}, {Key: "content", Value: "text"}}, // full-text search
+			Options: options.Index().
+				SetName("post_text").
+				SetWeights(bson.M{"title": 10, "tags": 5, "content": 1}).
+				SetDefaultLanguage("english").
+				SetLanguageOverride("text_language"), // posts have no language field, keep the default "language" free
+		},
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create post indexes: %w", err)
 	}
 
+	// trending posts by precomputed score, only scored posts are indexed
+	trendingIndexes := make([]mongo.IndexModel, 0, len(trendingWindows))
+	for window := range trendingWindows {
+		field := trendingScoreField(window)
+		trendingIndexes = append(trendingIndexes, mongo.IndexModel{
+			Keys:    bson.D{{Key: field, Value: -1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{field: bson.M{"$exists": true}}),
+		})
+	}
+	trendingIndexes = append(trendingIndexes, mongo.IndexModel{
+		Keys:    bson.D{{Key: "trending_refreshed_at", Value: -1}}, // latest refresh, stale trending cursors
+		Options: options.Index().SetSparse(true),
+	})
+	if _, err = c.Post.(*PostStorage).collection.Indexes().CreateMany(ctx, trendingIndexes); err != nil {
+		return fmt.Errorf("failed to create trending indexes: %w", err)
+	}
+
+	//Like collection
+	_, err = c.Post.(*PostStorage).likeCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}}, // one like per user and post
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "_id", Value: -1}}},    // who liked a post
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "post_id", Value: 1}}}, // liked status for a page
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create like indexes: %w", err)
+	}
+
+	//Post revision collection
+	_, err = c.Post.(*PostStorage).revisionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "version", Value: 1}}, // one revision per version
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create post revision indexes: %w", err)
+	}
+
+	//Reaction collection
+	_, err = c.Reaction.(*ReactionStorage).collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "target_type", Value: 1}, {Key: "target_id", Value: 1}, {Key: "user_id", Value: 1}}, // one reaction per user and target
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "target_type", Value: 1}, {Key: "target_id", Value: 1}}}, // viewer's reactions for a page
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create reaction indexes: %w", err)
+	}
+
+	//Poll vote collection
+	_, err = c.Post.(*PostStorage).pollVoteCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}}, // one vote per user and poll
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "post_id", Value: 1}}}, // viewer's votes for a page
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create poll vote indexes: %w", err)
+	}
+
+	//Reputation entry collection
+	_, err = c.Reputation.(*ReputationStorage).collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}}, // one entry per source event
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}}, // ledger of a user
+		{Keys: bson.D{{Key: "synced_at", Value: 1}}},                        // drop stale entries
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create reputation entry indexes: %w", err)
+	}
+
+	//Tag collection
+	_, err = c.Tag.(*TagStorage).collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}}, // tag page, autocomplete prefix and count refresh
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "post_count", Value: -1}}}, // most used tags first
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tag indexes: %w", err)
+	}
+
+	//Tag follow collection
+	_, err = c.Tag.(*TagStorage).followCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "tag", Value: 1}}, // one follow per user and tag
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tag follow indexes: %w", err)
+	}
+
 	//Comment collection
-	_, err = c.Comment.(*CommentStorage).collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.D{{Key: "post_id", Value: 1}}, // get comments by post
+	_, err = c.Comment.(*CommentStorage).collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}}},                                                                // get comments by post
+		{Keys: bson.D{{Key: "mentions", Value: 1}}},                                                               // posts with comments mentioning a user
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "reply_count", Value: -1}}}, // threads sorted by top
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "vote_count", Value: -1}}},  // answers sorted by votes
+		{Keys: bson.D{{Key: "parent_id", Value: 1}}, Options: options.Index().SetSparse(true)},                    // replies of a comment
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}, Options: options.Index().SetSparse(true)},                   // trash and purge
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create comment indexes: %w", err)
 	}
 
+	//Answer vote collection
+	_, err = c.Comment.(*CommentStorage).answerVoteCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "comment_id", Value: 1}, {Key: "user_id", Value: 1}}, // one upvote per user and answer
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "comment_id", Value: 1}}}, // viewer's upvotes for a page
+		{Keys: bson.D{{Key: "post_id", Value: 1}}},                                // purge with the post
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create answer vote indexes: %w", err)
+	}
+
 	//Review collection
 	_, err = c.Review.(*ReviewStorage).collection.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{{Key: "rated_user_id", Value: 1}}, // get reviews for a user
@@ -177,13 +549,105 @@ func EnsureIndexes(ctx context.Context, c Collection) error {
 	}
 
 	//Follow collection
+	// duplicate follows made before the pair was unique would fail the index build
+	if err := dedupeFollows(ctx, c.Follow.(*FollowStorage)); err != nil {
+		return fmt.Errorf("failed to remove duplicate follows: %w", err)
+	}
+
 	_, err = c.Follow.(*FollowStorage).collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
-		{Keys: bson.D{{Key: "follower_id", Value: 1}}},  // get who a user follows
+		{
+			Keys:    bson.D{{Key: "follower_id", Value: 1}, {Key: "followee_id", Value: 1}}, // a user is followed once per follower
+			Options: options.Index().SetUnique(true),
+		},
 		{Keys: bson.D{{Key: "following_id", Value: 1}}}, // get followers of a user
+		{Keys: bson.D{{Key: "followee_id", Value: 1}}},  // fan out to followers
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create follow indexes: %w", err)
 	}
 
+	//Timeline collection
+	_, err = c.Timeline.(*TimelineStorage).collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "post_id", Value: 1}}, // a post is in a timeline once, $merge target
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "published_at", Value: -1}, {Key: "post_id", Value: -1}}}, // timeline pages
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "author_id", Value: 1}}},                                  // unfollow
+		{Keys: bson.D{{Key: "post_id", Value: 1}}},                                                                // deleted posts
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create timeline indexes: %w", err)
+	}
+
+	//For You session collection
+	_, err = c.Post.(*PostStorage).forYouCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1}, // sessions expire at the time stored in expires_at
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create for you session indexes: %w", err)
+	}
+
+	//Product collection
+	_, err = c.Product.(*ProductStorage).collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "manufacturer_id", Value: 1}, {Key: "sku", Value: 1}}, // sku is unique per manufacturer
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "category", Value: 1}}},  // facet filter
+		{Keys: bson.D{{Key: "materials", Value: 1}}}, // facet filter
+		{Keys: bson.D{{Key: "finishes", Value: 1}}},  // facet filter
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create product indexes: %w", err)
+	}
+
+	//Board collection
+	boardStorage := c.Board.(*BoardStorage)
+	_, err = boardStorage.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner_id", Value: 1}}},      // boards of a user
+		{Keys: bson.D{{Key: "collaborators", Value: 1}}}, // boards shared with a user
+		{Keys: bson.D{{Key: "invited", Value: 1}}},       // pending invites of a user
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create board indexes: %w", err)
+	}
+
+	_, err = boardStorage.itemCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "board_id", Value: 1}, {Key: "post_id", Value: 1}}, // a post is saved once per board
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "board_id", Value: 1}, {Key: "position", Value: 1}}}, // board order
+		{Keys: bson.D{{Key: "saved_by", Value: 1}, {Key: "post_id", Value: 1}}},  // saved status
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create board item indexes: %w", err)
+	}
+
+	//Project collections
+	projectStorage := c.Project.(*ProjectStorage)
+	_, err = projectStorage.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "members.user_id", Value: 1}}, // projects of a member
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create project indexes: %w", err)
+	}
+
+	for _, pc := range []*mongo.Collection{
+		projectStorage.updateCollection,
+		projectStorage.taskCollection,
+		projectStorage.documentCollection,
+		projectStorage.activityCollection,
+	} {
+		_, err = pc.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "_id", Value: -1}}, // items of a project, newest first
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create %s indexes: %w", pc.Name(), err)
+		}
+	}
+
 	return nil
 }
@@ -9,20 +9,32 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
 	ErrCommentNotFound = errors.New("comment not found")
 )
 
+// DeletedCommentContent - shown in place of a deleted comment that still has replies
+const DeletedCommentContent = "[deleted]"
+
 type Comment struct {
-	ID        primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
-	UserID    primitive.ObjectID  `json:"user_id" bson:"user_id"`
-	PostID    primitive.ObjectID  `json:"post_id" bson:"post_id"`
-	ParentID  *primitive.ObjectID `json:"parent_id,omitempty" bson:"parent_id,omitempty"` // make it pointer to allow nil
-	Content   string              `json:"content" bson:"content"`
-	CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+	ID              primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+	UserID          primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	PostID          primitive.ObjectID  `json:"post_id" bson:"post_id"`
+	ParentID        *primitive.ObjectID `json:"parent_id,omitempty" bson:"parent_id,omitempty"` // make it pointer to allow nil
+	Depth           int                 `json:"depth" bson:"depth"`                             // 0 for top-level comments
+	ReplyCount      int64               `json:"reply_count" bson:"reply_count"`                 // direct replies shown in the thread, live or tombstones with shown replies
+	ReactionCounts  ReactionCounts      `json:"reaction_counts,omitempty" bson:"reaction_counts,omitempty"`
+	VoteCount       int64               `json:"vote_count" bson:"vote_count"`                 // upvotes of an answer
+	Accepted        bool                `json:"accepted,omitempty" bson:"accepted,omitempty"` // accepted answer of the question
+	Content         string              `json:"content" bson:"content"`
+	Mentions        []string            `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	MentionEntities []Mention           `json:"mention_entities,omitempty" bson:"mention_entities,omitempty"`
+	CreatedAt       time.Time           `json:"created_at" bson:"created_at"`
+	EditedAt        *time.Time          `json:"edited_at,omitempty" bson:"edited_at,omitempty"`
+	DeletedAt       *time.Time          `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy       *primitive.ObjectID `json:"-" bson:"deleted_by,omitempty"` // only comments deleted by the author go to their trash
 }
 
 // ParentComment - need to add 'bson' in the struct to be able to decode from mongoDB
@@ -31,22 +43,36 @@ type ParentComment struct {
 	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
 	Content   string             `json:"content" bson:"content"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	DeletedAt *time.Time         `json:"-" bson:"deleted_at,omitempty"`
+	Deleted   bool               `json:"deleted,omitempty" bson:"-"` // set from DeletedAt, the parent is a tombstone
 }
 
 type CommentWithParentAndUser struct {
-	ID            primitive.ObjectID `json:"id"`
-	UserID        primitive.ObjectID `json:"user_id"`
-	Username      string             `json:"username"`
-	PostID        primitive.ObjectID `json:"post_id"`
-	Content       string             `json:"content"`
-	CreatedAt     time.Time          `json:"created_at"`
-	ParentComment *ParentComment     `json:"parent_comment,omitempty"`
+	ID              primitive.ObjectID `json:"id"`
+	UserID          primitive.ObjectID `json:"user_id"`
+	Username        string             `json:"username"`
+	PostID          primitive.ObjectID `json:"post_id"`
+	Depth           int                `json:"depth"`
+	ReplyCount      int64              `json:"reply_count"`
+	ReactionCounts  ReactionCounts     `json:"reaction_counts,omitempty"`
+	ViewerReaction  ReactionType       `json:"viewer_reaction,omitempty"`
+	VoteCount       int64              `json:"vote_count"`
+	Accepted        bool               `json:"accepted,omitempty"`
+	ViewerUpvoted   bool               `json:"viewer_upvoted,omitempty"`
+	Content         string             `json:"content"`
+	MentionEntities []Mention          `json:"mention_entities,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+	EditedAt        *time.Time         `json:"edited_at,omitempty"`
+	Deleted         bool               `json:"deleted,omitempty"`
+	ParentComment   *ParentComment     `json:"parent_comment,omitempty"`
 }
 
 type CommentStorage struct {
-	collection  *mongo.Collection
-	userStorage *UserStorage
-	postStorage *PostStorage
+	collection           *mongo.Collection
+	reactionCollection   *mongo.Collection
+	answerVoteCollection *mongo.Collection
+	userStorage          *UserStorage
+	postStorage          *PostStorage
 }
 
 func (c *CommentStorage) Create(ctx context.Context, comment *Comment) (CommentWithParentAndUser, error) {
@@ -58,10 +84,7 @@ func (c *CommentStorage) Create(ctx context.Context, comment *Comment) (CommentW
 		comment.ID = primitive.NewObjectID()
 		comment.CreatedAt = time.Now()
 
-		ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
-		defer cancel()
-
-		_, err := c.collection.InsertOne(ctxTimeout, comment)
+		_, err := c.collection.InsertOne(sessCtx, comment)
 
 		if err != nil {
 			return nil, fmt.Errorf("failed to create comment: %w", err)
@@ -69,42 +92,57 @@ func (c *CommentStorage) Create(ctx context.Context, comment *Comment) (CommentW
 
 		// can not directly use Collection.Post.IncrementCommentCount, because it's not initialized on that path
 		// it's only initialized through app := &application in main, but not worthy to import from there
-		if err := c.postStorage.IncrementCommentCount(ctxTimeout, comment.PostID); err != nil {
+		if err := c.postStorage.IncrementCommentCount(sessCtx, comment.PostID); err != nil {
 			return nil, fmt.Errorf("failed to increment comment count: %w", err)
 		}
 
+		if err := c.addShownReply(sessCtx, comment.ParentID); err != nil {
+			return nil, err
+		}
+
 		var user User
-		if err := c.userStorage.collection.FindOne(ctxTimeout, bson.M{"_id": comment.UserID}).Decode(&user); err != nil {
+		if err := c.userStorage.collection.FindOne(sessCtx, bson.M{"_id": comment.UserID}).Decode(&user); err != nil {
 			return nil, fmt.Errorf("failed to get user: %w", err)
 		}
 
 		result = CommentWithParentAndUser{
-			ID:        comment.ID,
-			UserID:    comment.UserID,
-			Username:  user.Username,
-			PostID:    comment.PostID,
-			Content:   comment.Content,
-			CreatedAt: comment.CreatedAt,
+			ID:              comment.ID,
+			UserID:          comment.UserID,
+			Username:        user.Username,
+			PostID:          comment.PostID,
+			Depth:           comment.Depth,
+			Content:         comment.Content,
+			MentionEntities: comment.MentionEntities,
+			CreatedAt:       comment.CreatedAt,
 		}
 
 		var parent ParentComment
 		if comment.ParentID != nil {
-			if err := c.collection.FindOne(ctxTimeout, bson.M{"_id": comment.ParentID}).Decode(&parent); err != nil {
+			if err := c.collection.FindOne(sessCtx, bson.M{"_id": comment.ParentID}).Decode(&parent); err != nil {
 				return nil, fmt.Errorf("failed to get parent comment: %w", err)
 			}
+			// a tombstone parent doesn't show its author or content
+			if parent.DeletedAt != nil {
+				parent.UserID = primitive.NilObjectID
+				parent.Content = DeletedCommentContent
+				parent.Deleted = true
+			}
 			result.ParentComment = &parent
 		}
 
 		return nil, nil
 	}
 
-	err := withTransaction(ctx, client, txnFunc)
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := withTransaction(ctxTimeout, client, txnFunc)
 
 	return result, err
 }
 
 func (c *CommentStorage) Exists(ctx context.Context, commentID primitive.ObjectID) (bool, error) {
-	count, err := c.collection.CountDocuments(ctx, bson.M{"_id": commentID})
+	count, err := c.collection.CountDocuments(ctx, bson.M{"_id": commentID, "deleted_at": notDeleted()})
 	if err != nil {
 		return false, fmt.Errorf("failed to check if the comment exists: %w", err)
 	}
@@ -115,94 +153,58 @@ func (c *CommentStorage) Exists(ctx context.Context, commentID primitive.ObjectI
 	return true, nil
 }
 
-func (c *CommentStorage) GetByPostID(ctx context.Context, postID primitive.ObjectID) ([]CommentWithParentAndUser, error) {
+func (c *CommentStorage) GetByID(ctx context.Context, commentID primitive.ObjectID) (*Comment, error) {
 	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	// fetch comment
-	cursor, err := c.collection.Find(ctxTimeout, bson.M{"post_id": postID},
-		options.Find().SetSort(bson.M{"created_at": -1}))
+	var comment Comment
+	err := c.collection.FindOne(ctxTimeout, bson.M{"_id": commentID, "deleted_at": notDeleted()}).Decode(&comment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get comment by post id: %w", err)
-	}
-	defer cursor.Close(ctxTimeout)
-
-	var comments []Comment
-
-	if err = cursor.All(ctxTimeout, &comments); err != nil {
-		return nil, fmt.Errorf("failed to decode comments: %w", err)
-	}
-
-	// get all parent comments and username in a single batch query
-	// collect parentIDs and userIDs
-	parentIDs := make([]primitive.ObjectID, 0)
-	userIDs := make([]primitive.ObjectID, 0, len(comments))
-	for _, c := range comments {
-		// check if ParentID is a non-zero value (not omitted.empty)
-		// can not use ParentID != nil, because it's not a pointer
-		if c.ParentID != nil {
-			parentIDs = append(parentIDs, *c.ParentID)
-		}
-		userIDs = append(userIDs, c.UserID)
-	}
-
-	// fetch all parent comments
-	var parentComments []ParentComment
-	if len(parentIDs) > 0 {
-		pCursor, err := c.collection.Find(ctxTimeout, bson.M{"_id": bson.M{"$in": parentIDs}})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get comment by parent id: %w", err)
-		}
-		defer pCursor.Close(ctxTimeout)
-
-		if err = pCursor.All(ctxTimeout, &parentComments); err != nil {
-			return nil, fmt.Errorf("failed to decode parent comments: %w", err)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrCommentNotFound
 		}
+		return nil, fmt.Errorf("comment query failed: %w", err)
 	}
 
-	// fetch all usernames
-	var users []User
-	if len(userIDs) > 0 {
-		uCursor, err := c.userStorage.collection.Find(ctxTimeout, bson.M{"_id": bson.M{"$in": userIDs}})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get users: %w", err)
-		}
-		defer uCursor.Close(ctxTimeout)
+	return &comment, nil
+}
 
-		if err = uCursor.All(ctxTimeout, &users); err != nil {
-			return nil, fmt.Errorf("failed to decode users: %w", err)
-		}
-	}
+// tombstone - hide author and content of a deleted comment that is kept because it has replies
+func tombstone(comment CommentWithParentAndUser) CommentWithParentAndUser {
+	comment.UserID = primitive.NilObjectID
+	comment.Username = ""
+	comment.Content = DeletedCommentContent
+	comment.MentionEntities = nil
+	comment.VoteCount = 0
+	comment.Accepted = false
+	comment.EditedAt = nil
+	comment.Deleted = true
+	return comment
+}
 
-	// index for quick lookup
-	parentMap := make(map[primitive.ObjectID]*ParentComment)
-	for _, p := range parentComments {
-		parentMap[p.ID] = &p
-	}
+// Update - edit the content of a comment that hasn't been deleted
+func (c *CommentStorage) Update(ctx context.Context, comment *Comment) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
 
-	userMap := make(map[primitive.ObjectID]string)
-	for _, u := range users {
-		userMap[u.ID] = u.Username
+	now := time.Now()
+	result, err := c.collection.UpdateOne(ctxTimeout,
+		bson.M{"_id": comment.ID, "deleted_at": notDeleted()},
+		bson.M{"$set": bson.M{
+			"content":          comment.Content,
+			"mentions":         comment.Mentions,
+			"mention_entities": comment.MentionEntities,
+			"edited_at":        now,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
 	}
 
-	// final response
-	result := make([]CommentWithParentAndUser, 0, len(comments))
-	for _, c := range comments {
-		comment := CommentWithParentAndUser{
-			ID:        c.ID,
-			UserID:    c.UserID,
-			Username:  userMap[c.UserID],
-			PostID:    c.PostID,
-			Content:   c.Content,
-			CreatedAt: c.CreatedAt,
-		}
-		if c.ParentID != nil {
-			if parent, ok := parentMap[*c.ParentID]; ok {
-				comment.ParentComment = parent
-			}
-		}
-		result = append(result, comment)
+	if result.MatchedCount == 0 {
+		return ErrCommentNotFound
 	}
 
-	return result, nil
+	comment.EditedAt = &now
+	return nil
 }
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrPostPublished = errors.New("post is already published")
+)
+
+// PostStatus - only published posts show up in feeds, search and profiles
+type PostStatus string
+
+const (
+	PostDraft     PostStatus = "draft"
+	PostScheduled PostStatus = "scheduled"
+	PostPublished PostStatus = "published"
+)
+
+// GetDrafts - the author's draft and scheduled posts, newest first
+func (p *PostStorage) GetDrafts(ctx context.Context, userID primitive.ObjectID, cq CursorQuery) ([]Post, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":    userID,
+		"status":     bson.M{"$in": []PostStatus{PostDraft, PostScheduled}},
+		"deleted_at": notDeleted(),
+	}
+
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		cursorID, err := primitive.ObjectIDFromHex(cq.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor ID: %w", err)
+		}
+		filter["_id"] = bson.M{"$lt": cursorID}
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"_id": -1}).
+		SetLimit(int64(cq.Limit))
+
+	cursor, err := p.collection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find drafts: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	posts := make([]Post, 0)
+	if err := cursor.All(ctxTimeout, &posts); err != nil {
+		return nil, fmt.Errorf("failed to decode drafts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// Publish - publish a draft or scheduled post right away
+func (p *PostStorage) Publish(ctx context.Context, postID primitive.ObjectID) (time.Time, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"_id": postID, "status": bson.M{"$ne": PostPublished}, "deleted_at": notDeleted()}
+	update := bson.M{
+		"$set":   bson.M{"status": PostPublished, "published_at": now, "updated_at": now},
+		"$unset": bson.M{"publish_at": ""},
+	}
+
+	result, err := p.collection.UpdateOne(ctxTimeout, filter, update)
+	if err != nil {
+		return now, fmt.Errorf("failed to publish post: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return now, ErrPostPublished
+	}
+
+	return now, nil
+}
+
+// PublishDue - publish every scheduled post whose publish time has passed, returns the published post ids
+func (p *PostStorage) PublishDue(ctx context.Context, now time.Time) ([]primitive.ObjectID, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"status":     PostScheduled,
+		"publish_at": bson.M{"$lte": now},
+		"deleted_at": notDeleted(),
+	}
+
+	dueIDs, err := p.collection.Distinct(ctxTimeout, "_id", filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find scheduled posts: %w", err)
+	}
+	if len(dueIDs) == 0 {
+		return nil, nil
+	}
+
+	// the status is checked again so a post published in the meantime isn't published twice
+	filter["_id"] = bson.M{"$in": dueIDs}
+	update := bson.M{
+		"$set":   bson.M{"status": PostPublished, "published_at": now, "updated_at": now},
+		"$unset": bson.M{"publish_at": ""},
+	}
+
+	if _, err := p.collection.UpdateMany(ctxTimeout, filter, update); err != nil {
+		return nil, fmt.Errorf("failed to publish scheduled posts: %w", err)
+	}
+
+	postIDs := make([]primitive.ObjectID, 0, len(dueIDs))
+	for _, id := range dueIDs {
+		if postID, ok := id.(primitive.ObjectID); ok {
+			postIDs = append(postIDs, postID)
+		}
+	}
+	return postIDs, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Follow struct {
@@ -19,7 +20,8 @@ type Follow struct {
 }
 
 type FollowStorage struct {
-	collection *mongo.Collection
+	collection     *mongo.Collection
+	userCollection *mongo.Collection
 }
 
 func (f *FollowStorage) GetFollowing(ctx context.Context, followerID primitive.ObjectID) ([]primitive.ObjectID, error) {
@@ -83,20 +85,68 @@ func (f *FollowStorage) IsFollowing(ctx context.Context, followerID, followingID
 	return true, nil
 }
 
+// FollowUser - following again is a no-op, follower_count changes in the same transaction
 func (f *FollowStorage) FollowUser(ctx context.Context, followerID, followingID primitive.ObjectID) error {
-	_, err := f.collection.InsertOne(ctx, bson.M{
-		"_id":         primitive.NewObjectID(),
-		"follower_id": followerID,
-		"followee_id": followingID,
-		"created_at":  time.Now(),
-	})
-	return err
+	client := f.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		filter := bson.M{"follower_id": followerID, "followee_id": followingID}
+		update := bson.M{"$setOnInsert": bson.M{
+			"_id":         primitive.NewObjectID(),
+			"follower_id": followerID,
+			"followee_id": followingID,
+			"created_at":  time.Now(),
+		}}
+
+		result, err := f.collection.UpdateOne(sessCtx, filter, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to follow user: %w", err)
+		}
+
+		if result.UpsertedCount == 1 {
+			return nil, f.incrementFollowerCount(sessCtx, followingID, 1)
+		}
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
 }
 
+// UnfollowUser - unfollowing a user that isn't followed is a no-op, follower_count changes in the same transaction
 func (f *FollowStorage) UnfollowUser(ctx context.Context, followerID, followingID primitive.ObjectID) error {
-	_, err := f.collection.DeleteOne(ctx, bson.M{
-		"follower_id": followerID,
-		"followee_id": followingID,
-	})
-	return err
+	client := f.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := f.collection.DeleteOne(sessCtx, bson.M{
+			"follower_id": followerID,
+			"followee_id": followingID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to unfollow user: %w", err)
+		}
+
+		if result.DeletedCount == 1 {
+			return nil, f.incrementFollowerCount(sessCtx, followingID, -1)
+		}
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+func (f *FollowStorage) incrementFollowerCount(ctx context.Context, followeeID primitive.ObjectID, delta int) error {
+	_, err := f.userCollection.UpdateOne(ctx,
+		bson.M{"_id": followeeID},
+		bson.M{"$inc": bson.M{"follower_count": delta}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update follower count: %w", err)
+	}
+	return nil
 }
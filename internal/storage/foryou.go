@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/security"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	forYouWindow         = 14 * 24 * time.Hour // candidates are published within the window
+	forYouPool           = 500                 // most recent candidates scored per page
+	forYouSessionTTL     = time.Hour           // a scroll session expires after an hour without a page
+	forYouDiscoveryEvery = 5                   // every 5th post comes from outside the viewer's affinities
+	forYouInteractions   = 100                 // recent likes and comments the affinities are learned from
+)
+
+// forYouSession - ranking state kept between pages: scores are computed as of the first page
+// and posts already served are left out, so scrolling neither repeats nor reshuffles.
+// The cursor is the session id
+type forYouSession struct {
+	ID        primitive.ObjectID   `bson:"_id"`
+	UserID    primitive.ObjectID   `bson:"user_id"`
+	AsOf      time.Time            `bson:"as_of"`
+	Seen      []primitive.ObjectID `bson:"seen"`
+	ExpiresAt time.Time            `bson:"expires_at"`
+}
+
+// getForYouSession - sessions of other users and expired sessions are stale cursors
+func (p *PostStorage) getForYouSession(ctx context.Context, userID primitive.ObjectID, cursor string) (forYouSession, error) {
+	var session forYouSession
+
+	sessionID, err := primitive.ObjectIDFromHex(cursor)
+	if err != nil {
+		return session, fmt.Errorf("invalid cursor ID: %w", err)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err = p.forYouCollection.FindOne(ctxTimeout, bson.M{"_id": sessionID, "user_id": userID}).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return session, ErrStaleCursor
+		}
+		return session, fmt.Errorf("failed to get for you session: %w", err)
+	}
+
+	return session, nil
+}
+
+// saveForYouSession - remember the posts of a page, the first page creates the session
+func (p *PostStorage) saveForYouSession(ctx context.Context, session forYouSession, posts []Post) error {
+	served := make([]primitive.ObjectID, 0, len(posts))
+	for _, post := range posts {
+		served = append(served, post.ID)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := p.forYouCollection.UpdateOne(ctxTimeout,
+		bson.M{"_id": session.ID, "user_id": session.UserID},
+		bson.M{
+			"$setOnInsert": bson.M{"as_of": session.AsOf},
+			"$push":        bson.M{"seen": bson.M{"$each": served}},
+			"$set":         bson.M{"expires_at": time.Now().Add(forYouSessionTTL)},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save for you session: %w", err)
+	}
+
+	return nil
+}
+
+// forYouSignals - what the viewer has shown interest in through recent likes and comments
+type forYouSignals struct {
+	likedAuthors []primitive.ObjectID
+	roleAffinity map[security.Role]float64 // share of interactions per author role
+	tagAffinity  []string
+}
+
+// scoredPost - candidate with its ranking, affinity 0 means discovery content
+type scoredPost struct {
+	Post     `bson:",inline"`
+	Affinity float64 `bson:"affinity"`
+	Score    float64 `bson:"score"`
+}
+
+// GetForYou - ranked feed of candidates scored by followees, followed tags, location, role and interest
+// affinities, freshness and engagement, with discovery posts mixed in and at most one post per author per page.
+// The next cursor is empty once candidates run out
+func (p *PostStorage) GetForYou(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, string, error) {
+	state := forYouSession{ID: primitive.NewObjectID(), UserID: user.ID, AsOf: time.Now()}
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		var err error
+		if state, err = p.getForYouSession(ctx, user.ID, cq.Cursor); err != nil {
+			return nil, "", err
+		}
+	}
+
+	signals, err := p.forYouSignals(ctx, user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	candidates, err := p.scoreForYou(ctx, user, cq, signals, state)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var ranked, discovery []scoredPost
+	for _, candidate := range candidates {
+		if candidate.Affinity > 0 {
+			ranked = append(ranked, candidate)
+		} else {
+			discovery = append(discovery, candidate)
+		}
+	}
+
+	byScore := func(list []scoredPost) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].ID.Hex() > list[j].ID.Hex()
+		})
+	}
+	byScore(ranked)
+	byScore(discovery)
+
+	authors := make(map[primitive.ObjectID]bool)
+	posts := make([]Post, 0, cq.Limit)
+	for len(posts) < cq.Limit && (len(ranked) > 0 || len(discovery) > 0) {
+		source := &ranked
+		if (len(posts)+1)%forYouDiscoveryEvery == 0 && len(discovery) > 0 || len(ranked) == 0 {
+			source = &discovery
+		}
+
+		// authors already on the page wait for a later page
+		picked := -1
+		for i, candidate := range *source {
+			if !authors[candidate.UserID] {
+				picked = i
+				break
+			}
+		}
+		if picked == -1 {
+			*source = nil
+			continue
+		}
+
+		candidate := (*source)[picked]
+		*source = append((*source)[:picked], (*source)[picked+1:]...)
+		authors[candidate.UserID] = true
+		posts = append(posts, candidate.Post)
+	}
+
+	var nextCursor string
+	if len(posts) >= cq.Limit {
+		if err := p.saveForYouSession(ctx, state, posts); err != nil {
+			return nil, "", err
+		}
+		nextCursor = state.ID.Hex()
+	}
+
+	withStatus, err := p.withLikeStatus(ctx, user, posts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return withStatus, nextCursor, nil
+}
+
+// scoreForYou - score = (1 + affinity) * (1 + ln(1 + engagement)) / (age in hours + 2) ^ 1.2, age as of the first page
+func (p *PostStorage) scoreForYou(ctx context.Context, user *User, cq CursorQuery, signals forYouSignals, state forYouSession) ([]scoredPost, error) {
+	visible, err := p.visibilityCondition(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	match := bson.M{
+		"status":       PostPublished,
+		"deleted_at":   notDeleted(),
+		"type":         bson.M{"$ne": PostRepost},
+		"user_id":      bson.M{"$ne": user.ID},
+		"published_at": bson.M{"$gte": state.AsOf.Add(-forYouWindow), "$lte": state.AsOf},
+		"$and":         []bson.M{visible},
+	}
+	if len(state.Seen) > 0 {
+		match["_id"] = bson.M{"$nin": state.Seen}
+	}
+	if len(cq.Roles) > 0 {
+		match["user_role"] = bson.M{"$in": cq.Roles}
+	}
+	if len(cq.Types) > 0 {
+		match["type"] = bson.M{"$ne": PostRepost, "$in": cq.Types}
+	}
+	if len(cq.Tags) > 0 {
+		match["tags"] = bson.M{"$in": cq.Tags}
+	}
+	if cq.QuestionStatus != "" {
+		match["$and"] = []bson.M{visible, questionCondition(cq.QuestionStatus)}
+	}
+
+	tags := bson.M{"$ifNull": bson.A{"$tags", bson.A{}}}
+	weight := func(condition interface{}, points float64) bson.M {
+		return bson.M{"$cond": bson.A{condition, points, 0}}
+	}
+
+	roleBranches := bson.A{}
+	for role, share := range signals.roleAffinity {
+		roleBranches = append(roleBranches, bson.M{"case": bson.M{"$eq": bson.A{"$user_role", role}}, "then": 2 * share})
+	}
+	roleAffinity := bson.M{"$literal": 0}
+	if len(roleBranches) > 0 {
+		roleAffinity = bson.M{"$switch": bson.M{"branches": roleBranches, "default": 0}}
+	}
+
+	// authors are only looked up for their location when the viewer has one
+	var sameLocation interface{} = false
+	if user.Profile.Location != "" {
+		sameLocation = bson.M{"$eq": bson.A{bson.M{"$first": "$author.profile.location"}, user.Profile.Location}}
+	}
+
+	affinity := bson.A{
+		weight(bson.M{"$in": bson.A{"$user_id", orEmpty(cq.FolloweeIDs)}}, 3),
+		weight(bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$setIntersection": bson.A{tags, orEmpty(cq.FollowedTags)}}}, 0}}, 2),
+		weight(bson.M{"$in": bson.A{"$user_id", orEmpty(signals.likedAuthors)}}, 2),
+		weight(bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$setIntersection": bson.A{tags, orEmpty(signals.tagAffinity)}}}, 0}}, 1),
+		weight(sameLocation, 1),
+		roleAffinity,
+	}
+
+	engagement := bson.M{"$add": bson.A{
+		1,
+		"$like_count",
+		bson.M{"$multiply": bson.A{2, "$comment_count"}},
+		bson.M{"$multiply": bson.A{3, bson.M{"$ifNull": bson.A{"$share_count", 0}}}},
+	}}
+	ageHours := bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{state.AsOf, "$published_at"}}, float64(time.Hour / time.Millisecond)}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: "published_at", Value: -1}}}},
+		{{Key: "$limit", Value: forYouPool}},
+	}
+	if user.Profile.Location != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$lookup", Value: bson.M{
+			"from":         "user",
+			"localField":   "user_id",
+			"foreignField": "_id",
+			"as":           "author",
+		}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$addFields", Value: bson.M{"affinity": bson.M{"$add": affinity}}}},
+		bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$divide": bson.A{
+			bson.M{"$multiply": bson.A{bson.M{"$add": bson.A{1, "$affinity"}}, bson.M{"$add": bson.A{1, bson.M{"$ln": engagement}}}}},
+			bson.M{"$pow": bson.A{bson.M{"$add": bson.A{bson.M{"$max": bson.A{0, ageHours}}, 2}}, 1.2}},
+		}}}}},
+		bson.D{{Key: "$unset", Value: "author"}},
+	)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	cursor, err := p.collection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to score feed candidates: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	var candidates []scoredPost
+	if err := cursor.All(ctxTimeout, &candidates); err != nil {
+		return nil, fmt.Errorf("failed to decode feed candidates: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// orEmpty - nil slices marshal to null, which $in and $setIntersection reject
+func orEmpty[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
+
+// forYouSignals - authors, author roles and tags of the posts the viewer recently liked or commented on
+func (p *PostStorage) forYouSignals(ctx context.Context, viewerID primitive.ObjectID) (forYouSignals, error) {
+	signals := forYouSignals{roleAffinity: make(map[security.Role]float64)}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	recent := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(forYouInteractions).
+		SetProjection(bson.M{"post_id": 1})
+
+	var postIDs []primitive.ObjectID
+	for _, source := range []struct {
+		collection *mongo.Collection
+		filter     bson.M
+	}{
+		{p.likeCollection, bson.M{"user_id": viewerID}},
+		{p.commentCollection, bson.M{"user_id": viewerID, "deleted_at": notDeleted()}},
+	} {
+		cursor, err := source.collection.Find(ctxTimeout, source.filter, recent)
+		if err != nil {
+			return signals, fmt.Errorf("failed to get recent interactions: %w", err)
+		}
+
+		var interactions []struct {
+			PostID primitive.ObjectID `bson:"post_id"`
+		}
+		if err := cursor.All(ctxTimeout, &interactions); err != nil {
+			return signals, fmt.Errorf("failed to decode recent interactions: %w", err)
+		}
+
+		for _, interaction := range interactions {
+			postIDs = append(postIDs, interaction.PostID)
+		}
+	}
+
+	if len(postIDs) == 0 {
+		return signals, nil
+	}
+
+	opts := options.Find().SetProjection(bson.M{"user_id": 1, "user_role": 1, "tags": 1})
+	cursor, err := p.collection.Find(ctxTimeout, bson.M{"_id": bson.M{"$in": postIDs}, "user_id": bson.M{"$ne": viewerID}}, opts)
+	if err != nil {
+		return signals, fmt.Errorf("failed to get interacted posts: %w", err)
+	}
+
+	var posts []Post
+	if err := cursor.All(ctxTimeout, &posts); err != nil {
+		return signals, fmt.Errorf("failed to decode interacted posts: %w", err)
+	}
+
+	authors := make(map[primitive.ObjectID]bool)
+	tagCounts := make(map[string]int)
+	for _, post := range posts {
+		if !authors[post.UserID] {
+			authors[post.UserID] = true
+			signals.likedAuthors = append(signals.likedAuthors, post.UserID)
+		}
+		signals.roleAffinity[post.UserRole] += 1 / float64(len(posts))
+		for _, tag := range post.Tags {
+			tagCounts[tag]++
+		}
+	}
+
+	// the viewer's 10 most interacted tags
+	for tag := range tagCounts {
+		signals.tagAffinity = append(signals.tagAffinity, tag)
+	}
+	sort.Slice(signals.tagAffinity, func(i, j int) bool {
+		a, b := signals.tagAffinity[i], signals.tagAffinity[j]
+		if tagCounts[a] != tagCounts[b] {
+			return tagCounts[a] > tagCounts[b]
+		}
+		return a < b
+	})
+	if len(signals.tagAffinity) > 10 {
+		signals.tagAffinity = signals.tagAffinity[:10]
+	}
+
+	return signals, nil
+}
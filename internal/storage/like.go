@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Like - one document per user and post, unique on (post_id, user_id)
+type Like struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	PostID    primitive.ObjectID `json:"post_id" bson:"post_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// PostLiker - a user who liked a post, ID is the like id used as the page cursor
+type PostLiker struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	UserID   primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Username string             `json:"username" bson:"username"`
+	LikedAt  time.Time          `json:"liked_at" bson:"created_at"`
+}
+
+// ToggleLike - like or unlike the post, like_count changes in the same transaction.
+// A like is the post's like reaction, so it replaces another reaction of the user on the post
+func (p *PostStorage) ToggleLike(ctx context.Context, userID primitive.ObjectID, post *Post) (bool, error) {
+	client := p.collection.Database().Client()
+
+	var liked bool
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		removed, err := removePostLike(sessCtx, p.collection, p.likeCollection, post.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		liked = !removed
+		if liked {
+			if _, err := removePostReaction(sessCtx, p.collection, p.reactionCollection, post.ID, userID); err != nil {
+				return nil, err
+			}
+			if err := addPostLike(sessCtx, p.collection, p.likeCollection, post.ID, userID); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if err := withTransaction(ctxTimeout, client, txnFunc); err != nil {
+		return false, err
+	}
+
+	return liked, nil
+}
+
+// addPostLike - no-op if the user already likes the post, reaction_counts.like mirrors like_count
+func addPostLike(ctx context.Context, posts, likes *mongo.Collection, postID, userID primitive.ObjectID) error {
+	result, err := likes.UpdateOne(ctx,
+		bson.M{"post_id": postID, "user_id": userID},
+		bson.M{"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "created_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to like post: %w", err)
+	}
+
+	if result.UpsertedCount == 0 {
+		return nil
+	}
+
+	return incrementLikeCount(ctx, posts, postID, 1)
+}
+
+// removePostLike - reports whether the user liked the post
+func removePostLike(ctx context.Context, posts, likes *mongo.Collection, postID, userID primitive.ObjectID) (bool, error) {
+	result, err := likes.DeleteOne(ctx, bson.M{"post_id": postID, "user_id": userID})
+	if err != nil {
+		return false, fmt.Errorf("failed to unlike post: %w", err)
+	}
+
+	if result.DeletedCount == 0 {
+		return false, nil
+	}
+
+	return true, incrementLikeCount(ctx, posts, postID, -1)
+}
+
+func incrementLikeCount(ctx context.Context, posts *mongo.Collection, postID primitive.ObjectID, delta int) error {
+	inc := bson.M{"like_count": delta, "reaction_counts." + string(ReactionLike): delta}
+	if _, err := posts.UpdateByID(ctx, postID, bson.M{"$inc": inc}); err != nil {
+		return fmt.Errorf("failed to update like count: %w", err)
+	}
+	return nil
+}
+
+// GetLikedPostIDs - posts of the page the user has liked, resolved in one query
+func (p *PostStorage) GetLikedPostIDs(ctx context.Context, userID primitive.ObjectID, postIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	liked := make(map[primitive.ObjectID]bool)
+	if len(postIDs) == 0 {
+		return liked, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	ids, err := p.likeCollection.Distinct(ctxTimeout, "post_id", bson.M{
+		"user_id": userID,
+		"post_id": bson.M{"$in": postIDs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get liked posts: %w", err)
+	}
+
+	for _, id := range ids {
+		if objID, ok := id.(primitive.ObjectID); ok {
+			liked[objID] = true
+		}
+	}
+
+	return liked, nil
+}
+
+// withLikeStatus - wrap posts with the viewer's liked status, anonymous viewers have liked nothing
+func (p *PostStorage) withLikeStatus(ctx context.Context, viewer *User, posts []Post) ([]PostWithLikeStatus, error) {
+	liked := make(map[primitive.ObjectID]bool)
+	if viewer != nil {
+		postIDs := make([]primitive.ObjectID, 0, len(posts))
+		for _, post := range posts {
+			postIDs = append(postIDs, post.ID)
+		}
+
+		var err error
+		liked, err = p.GetLikedPostIDs(ctx, viewer.ID, postIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result []PostWithLikeStatus
+	for _, post := range posts {
+		result = append(result, PostWithLikeStatus{Post: post, LikedByUser: liked[post.ID]})
+	}
+
+	return result, nil
+}
+
+// GetLikes - users who liked the post, most recent first
+func (p *PostStorage) GetLikes(ctx context.Context, postID primitive.ObjectID, cq CursorQuery) ([]PostLiker, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	match := bson.M{"post_id": postID}
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		cursorID, err := primitive.ObjectIDFromHex(cq.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor ID: %w", err)
+		}
+		match["_id"] = bson.M{"$lt": cursorID}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.M{"_id": -1}}},
+		{{Key: "$limit", Value: cq.Limit}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "user",
+			"localField":   "user_id",
+			"foreignField": "_id",
+			"as":           "user",
+		}}},
+		{{Key: "$unwind", Value: "$user"}},
+		{{Key: "$project", Value: bson.M{
+			"user_id":    1,
+			"created_at": 1,
+			"username":   "$user.username",
+		}}},
+	}
+
+	cursor, err := p.likeCollection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find post likes: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	likers := make([]PostLiker, 0)
+	if err := cursor.All(ctxTimeout, &likers); err != nil {
+		return nil, fmt.Errorf("failed to decode post likes: %w", err)
+	}
+
+	return likers, nil
+}
+
+// migrateLikeArrays - move likes embedded in the post like_by arrays into the like collection
+// onlyDuplicateKeys - every write of an unordered insert that failed, failed on a duplicate key
+func onlyDuplicateKeys(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
+
+func migrateLikeArrays(ctx context.Context, posts, likes *mongo.Collection) error {
+	opts := options.Find().SetProjection(bson.M{"like_by": 1, "created_at": 1})
+	cursor, err := posts.Find(ctx, bson.M{"like_by.0": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var post struct {
+			ID        primitive.ObjectID   `bson:"_id"`
+			LikeBy    []primitive.ObjectID `bson:"like_by"`
+			CreatedAt time.Time            `bson:"created_at"`
+		}
+		if err := cursor.Decode(&post); err != nil {
+			return err
+		}
+
+		docs := make([]interface{}, 0, len(post.LikeBy))
+		for _, userID := range post.LikeBy {
+			// the original like time is unknown
+			docs = append(docs, Like{ID: primitive.NewObjectID(), PostID: post.ID, UserID: userID, CreatedAt: post.CreatedAt})
+		}
+
+		// likes already moved by an interrupted run are duplicates, any other failure keeps like_by
+		if _, err := likes.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil && !onlyDuplicateKeys(err) {
+			return err
+		}
+
+		count, err := likes.CountDocuments(ctx, bson.M{"post_id": post.ID})
+		if err != nil {
+			return err
+		}
+
+		update := bson.M{"$set": bson.M{"like_count": count}, "$unset": bson.M{"like_by": ""}}
+		if _, err := posts.UpdateByID(ctx, post.ID, update); err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	_, err = posts.UpdateMany(ctx, bson.M{"like_by": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"like_by": ""}})
+	return err
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Mention - a validated @username in post or comment content
+// Start and End are byte offsets of "@username" in the content, End is exclusive
+type Mention struct {
+	Username string             `json:"username" bson:"username"`
+	UserID   primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Start    int                `json:"start" bson:"start"`
+	End      int                `json:"end" bson:"end"`
+}
+
+// mentionedCondition - posts that mention the user, either in the post or in one of its comments
+func (p *PostStorage) mentionedCondition(ctx context.Context, username string) (bson.M, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	postIDs, err := p.commentCollection.Distinct(ctxTimeout, "post_id", bson.M{
+		"mentions":   username,
+		"deleted_at": notDeleted(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find mentioning comments: %w", err)
+	}
+
+	// Check if the array contains the username
+	conditions := []bson.M{{"mentions": username}} // !!! Direct equality check
+	if len(postIDs) > 0 {
+		conditions = append(conditions, bson.M{"_id": bson.M{"$in": postIDs}})
+	}
+
+	return bson.M{"$or": conditions}, nil
+}
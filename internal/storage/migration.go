@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrate - idempotent data migrations, run on startup after EnsureIndexes
+func Migrate(ctx context.Context, c Collection) error {
+	postCollection := c.Post.(*PostStorage).collection
+
+	// posts created before post types existed are standard posts
+	_, err := postCollection.UpdateMany(ctx,
+		bson.M{"type": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"type": PostStandard}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to backfill post types: %w", err)
+	}
+
+	// posts created before drafts existed were published when created
+	_, err = postCollection.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"status":       PostPublished,
+			"published_at": "$created_at",
+		}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to backfill post status: %w", err)
+	}
+
+	// posts created before visibility levels existed were public
+	_, err = postCollection.UpdateMany(ctx,
+		bson.M{"visibility": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"visibility": VisibilityPublic}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to backfill post visibility: %w", err)
+	}
+
+	// likes used to be embedded in the post
+	if err := migrateLikeArrays(ctx, postCollection, c.Post.(*PostStorage).likeCollection); err != nil {
+		return fmt.Errorf("failed to migrate post likes: %w", err)
+	}
+
+	// posts could be liked through reactions too, those become likes
+	if err := migrateLikeReactions(ctx, c.Post.(*PostStorage)); err != nil {
+		return fmt.Errorf("failed to migrate post like reactions: %w", err)
+	}
+
+	// reaction counts of a post used to leave out its likes
+	_, err = postCollection.UpdateMany(ctx,
+		bson.M{"$expr": bson.M{"$ne": bson.A{
+			bson.M{"$ifNull": bson.A{"$reaction_counts." + string(ReactionLike), 0}},
+			"$like_count",
+		}}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"reaction_counts." + string(ReactionLike): "$like_count"}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to count post likes as reactions: %w", err)
+	}
+
+	// tags used to be stored as typed
+	if err := migrateTags(ctx, postCollection, c.Tag.(*TagStorage).collection); err != nil {
+		return fmt.Errorf("failed to normalize post tags: %w", err)
+	}
+
+	// comments created before answers existed have no upvotes
+	_, err = c.Comment.(*CommentStorage).collection.UpdateMany(ctx,
+		bson.M{"vote_count": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"vote_count": 0}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to backfill comment votes: %w", err)
+	}
+
+	// comments created before threads existed need their depth and reply count
+	if err := migrateCommentThreads(ctx, c.Comment.(*CommentStorage).collection); err != nil {
+		return fmt.Errorf("failed to backfill comment threads: %w", err)
+	}
+
+	// deleted replies used to be uncounted even while they still had replies of their own
+	if err := migrateShownReplyCounts(ctx, c.Comment.(*CommentStorage).collection); err != nil {
+		return fmt.Errorf("failed to recount comment replies: %w", err)
+	}
+
+	// users created before typeahead existed have no lowercased username
+	_, err = c.User.(*UserStorage).collection.UpdateMany(ctx,
+		bson.M{"username_lower": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"username_lower": bson.M{"$toLower": "$username"}}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to backfill lowercased usernames: %w", err)
+	}
+
+	// users created before timelines existed have no follower count
+	followStorage := c.Follow.(*FollowStorage)
+	if err := migrateFollowerCounts(ctx, followStorage.userCollection, followStorage.collection); err != nil {
+		return fmt.Errorf("failed to backfill follower counts: %w", err)
+	}
+
+	// follows made before timelines existed need their timelines filled
+	if err := migrateTimelines(ctx, c.Timeline.(*TimelineStorage)); err != nil {
+		return fmt.Errorf("failed to backfill timelines: %w", err)
+	}
+
+	// posts deleted before deletes cleaned up profiles can still be pinned or featured
+	if err := migrateDeletedPins(ctx, c.Post.(*PostStorage)); err != nil {
+		return fmt.Errorf("failed to remove deleted posts from profiles: %w", err)
+	}
+
+	return nil
+}
+
+func migrateLikeReactions(ctx context.Context, p *PostStorage) error {
+	filter := bson.M{"target_type": ReactionOnPost, "type": ReactionLike}
+
+	postIDs, err := p.reactionCollection.Distinct(ctx, "target_id", filter)
+	if err != nil || len(postIDs) == 0 {
+		return err
+	}
+
+	// a user who both liked and reacted with like keeps the earlier like
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$project", Value: bson.M{
+			"_id":        0,
+			"post_id":    "$target_id",
+			"user_id":    1,
+			"created_at": 1,
+		}}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           p.likeCollection.Name(),
+			"on":             bson.A{"post_id", "user_id"},
+			"whenMatched":    "keepExisting",
+			"whenNotMatched": "insert",
+		}}},
+	}
+
+	cursor, err := p.reactionCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	if err := cursor.Close(ctx); err != nil {
+		return err
+	}
+
+	if _, err := p.reactionCollection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+
+	pipeline = mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"post_id": bson.M{"$in": postIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$post_id", "like_count": bson.M{"$sum": 1}}}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           p.collection.Name(),
+			"on":             "_id",
+			"whenMatched":    "merge",
+			"whenNotMatched": "discard",
+		}}},
+	}
+
+	cursor, err = p.likeCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+func migrateDeletedPins(ctx context.Context, p *PostStorage) error {
+	filter := bson.M{"$or": []bson.M{
+		{"pinned_post_ids.0": bson.M{"$exists": true}},
+		{"portfolio.post_ids.0": bson.M{"$exists": true}},
+	}}
+	opts := options.Find().SetProjection(bson.M{"pinned_post_ids": 1, "portfolio": 1})
+
+	cursor, err := p.userCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+
+		postIDs := append([]primitive.ObjectID{}, user.PinnedPostIDs...)
+		for _, section := range user.Portfolio {
+			postIDs = append(postIDs, section.PostIDs...)
+		}
+
+		live, err := p.collection.Distinct(ctx, "_id", bson.M{"_id": bson.M{"$in": postIDs}, "deleted_at": notDeleted()})
+		if err != nil {
+			return err
+		}
+
+		isLive := make(map[primitive.ObjectID]bool, len(live))
+		for _, id := range live {
+			if objID, ok := id.(primitive.ObjectID); ok {
+				isLive[objID] = true
+			}
+		}
+
+		for _, postID := range postIDs {
+			if isLive[postID] {
+				continue
+			}
+			if err := p.removeFromProfile(ctx, user.ID, postID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return cursor.Err()
+}
+
+func migrateFollowerCounts(ctx context.Context, userCollection, followCollection *mongo.Collection) error {
+	missing, err := userCollection.CountDocuments(ctx, bson.M{"follower_count": bson.M{"$exists": false}})
+	if err != nil || missing == 0 {
+		return err
+	}
+
+	// a follower counts once however many follow documents the pair has
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"follower_id": "$follower_id", "followee_id": "$followee_id"}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$_id.followee_id", "follower_count": bson.M{"$sum": 1}}}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           userCollection.Name(),
+			"on":             "_id",
+			"whenMatched":    "merge",
+			"whenNotMatched": "discard",
+		}}},
+	}
+
+	cursor, err := followCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	if err := cursor.Close(ctx); err != nil {
+		return err
+	}
+
+	_, err = userCollection.UpdateMany(ctx,
+		bson.M{"follower_count": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"follower_count": 0}},
+	)
+	return err
+}
+
+// dedupeFollows - keep the first follow of every pair, followees that lost duplicates are recounted
+func dedupeFollows(ctx context.Context, f *FollowStorage) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"follower_id": "$follower_id", "followee_id": "$followee_id"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+
+	cursor, err := f.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var duplicateIDs []primitive.ObjectID
+	followeeIDs := make(map[primitive.ObjectID]bool)
+	for cursor.Next(ctx) {
+		var pair struct {
+			ID struct {
+				FolloweeID primitive.ObjectID `bson:"followee_id"`
+			} `bson:"_id"`
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&pair); err != nil {
+			return err
+		}
+		duplicateIDs = append(duplicateIDs, pair.IDs[1:]...)
+		followeeIDs[pair.ID.FolloweeID] = true
+	}
+	if err := cursor.Err(); err != nil || len(duplicateIDs) == 0 {
+		return err
+	}
+
+	if _, err := f.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": duplicateIDs}}); err != nil {
+		return err
+	}
+
+	for followeeID := range followeeIDs {
+		count, err := f.collection.CountDocuments(ctx, bson.M{"followee_id": followeeID})
+		if err != nil {
+			return err
+		}
+		if _, err := f.userCollection.UpdateByID(ctx, followeeID, bson.M{"$set": bson.M{"follower_count": count}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func migrateTimelines(ctx context.Context, t *TimelineStorage) error {
+	entries, err := t.collection.EstimatedDocumentCount(ctx)
+	if err != nil || entries > 0 {
+		return err
+	}
+
+	cursor, err := t.followCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var follow Follow
+		if err := cursor.Decode(&follow); err != nil {
+			return err
+		}
+		if err := t.Backfill(ctx, follow.FollowerID, follow.FolloweeID); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// migrateShownReplyCounts - recount the threads of posts with deleted replies, only counts that differ are written
+func migrateShownReplyCounts(ctx context.Context, collection *mongo.Collection) error {
+	postIDs, err := collection.Distinct(ctx, "post_id", bson.M{"parent_id": bson.M{"$exists": true}, "deleted_at": bson.M{"$exists": true}})
+	if err != nil || len(postIDs) == 0 {
+		return err
+	}
+
+	opts := options.Find().SetProjection(bson.M{"parent_id": 1, "deleted_at": 1, "reply_count": 1})
+	cursor, err := collection.Find(ctx, bson.M{"post_id": bson.M{"$in": postIDs}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []Comment
+	if err := cursor.All(ctx, &comments); err != nil {
+		return err
+	}
+
+	replyCounts := shownReplyCounts(comments)
+
+	var models []mongo.WriteModel
+	for _, comment := range comments {
+		if comment.ReplyCount != replyCounts[comment.ID] {
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": comment.ID}).
+				SetUpdate(bson.M{"$set": bson.M{"reply_count": replyCounts[comment.ID]}}))
+		}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	_, err = collection.BulkWrite(ctx, models)
+	return err
+}
+
+func migrateCommentThreads(ctx context.Context, collection *mongo.Collection) error {
+	missing, err := collection.CountDocuments(ctx, bson.M{"depth": bson.M{"$exists": false}})
+	if err != nil || missing == 0 {
+		return err
+	}
+
+	opts := options.Find().SetProjection(bson.M{"parent_id": 1, "deleted_at": 1})
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []Comment
+	if err := cursor.All(ctx, &comments); err != nil {
+		return err
+	}
+
+	parents := make(map[primitive.ObjectID]*primitive.ObjectID, len(comments))
+	for _, comment := range comments {
+		parents[comment.ID] = comment.ParentID
+	}
+	replyCounts := shownReplyCounts(comments)
+
+	models := make([]mongo.WriteModel, 0, len(comments))
+	for _, comment := range comments {
+		depth := 0
+		for parentID := comment.ParentID; parentID != nil && depth < len(comments); parentID = parents[*parentID] {
+			depth++
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": comment.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"depth": depth, "reply_count": replyCounts[comment.ID]}}))
+	}
+
+	_, err = collection.BulkWrite(ctx, models)
+	return err
+}
@@ -18,6 +18,17 @@ type CursorQuery struct {
 	ShowMentioned bool                 `json:"show_mentioned,omitempty"`
 	Roles         []security.Role      `json:"roles,omitempty" validate:"valid_roles_slice"`
 	Search        string               `json:"search,omitempty"`
+	Types         []PostType           `json:"types,omitempty" validate:"dive,oneof=standard showcase repost quote"`
+	CommentSort   CommentSort          `json:"comment_sort,omitempty" validate:"omitempty,oneof=newest oldest top votes"`
+	Tags          []string             `json:"tags,omitempty"`
+	FollowedTags  []string             `json:"followed_tags"`
+	// questions with or without an accepted answer
+	QuestionStatus QuestionStatus `json:"question_status,omitempty" validate:"omitempty,oneof=answered unanswered"`
+	// trending posts only
+	Window TrendingWindow `json:"window,omitempty" validate:"omitempty,oneof=24h 7d 30d"`
+	// search only, stemming language of the query, "none" matches words as typed
+	SearchSort SearchSort `json:"search_sort,omitempty" validate:"omitempty,oneof=relevance recent"`
+	Language   string     `json:"language,omitempty" validate:"omitempty,oneof=none english spanish french german italian portuguese"`
 }
 
 func (cq *CursorQuery) Parse(r *http.Request) error {
@@ -31,9 +42,14 @@ func (cq *CursorQuery) Parse(r *http.Request) error {
 		cq.Cursor = cursor
 	}
 
-	sort := q.Get("sort")
-	if sort == "asc" {
+	// posts sort by asc/desc, comment threads by newest/oldest/top, search by relevance/recent
+	switch sort := q.Get("sort"); sort {
+	case "asc":
 		cq.Sort = sort
+	case string(CommentNewest), string(CommentOldest), string(CommentTop), string(CommentVotes):
+		cq.CommentSort = CommentSort(sort)
+	case string(SearchRelevance), string(SearchRecent):
+		cq.SearchSort = SearchSort(sort)
 	}
 
 	cq.ShowFollowing = q.Get("following") == "true"
@@ -50,5 +66,27 @@ func (cq *CursorQuery) Parse(r *http.Request) error {
 		cq.Search = search
 	}
 
+	if typesStr := q.Get("types"); typesStr != "" && typesStr != "undefined" {
+		for _, typeStr := range strings.Split(typesStr, ",") {
+			cq.Types = append(cq.Types, PostType(typeStr))
+		}
+	}
+
+	if tagsStr := q.Get("tags"); tagsStr != "" && tagsStr != "undefined" {
+		cq.Tags = NormalizeTags(strings.Split(tagsStr, ","))
+	}
+
+	if status := q.Get("question"); status != "" && status != "undefined" {
+		cq.QuestionStatus = QuestionStatus(status)
+	}
+
+	if window := q.Get("window"); window != "" && window != "undefined" {
+		cq.Window = TrendingWindow(window)
+	}
+
+	if language := q.Get("lang"); language != "" && language != "undefined" {
+		cq.Language = strings.ToLower(language)
+	}
+
 	return nil
 }
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrPollNotFound    = errors.New("post has no poll")
+	ErrPollClosed      = errors.New("poll is closed")
+	ErrAlreadyVoted    = errors.New("already voted in this poll")
+	ErrPollVoteMissing = errors.New("no vote in this poll")
+)
+
+// Poll - tallies are kept on the post, votes in the poll_vote collection
+type Poll struct {
+	Options        []PollOption `json:"options" bson:"options"`
+	MultipleChoice bool         `json:"multiple_choice" bson:"multiple_choice"`
+	ClosesAt       *time.Time   `json:"closes_at,omitempty" bson:"closes_at,omitempty"`
+	VoterCount     int64        `json:"voter_count" bson:"voter_count"`
+	// viewer specific, not stored
+	ViewerVote    []int `json:"viewer_vote,omitempty" bson:"-"`
+	ResultsHidden bool  `json:"results_hidden,omitempty" bson:"-"`
+}
+
+type PollOption struct {
+	Text      string `json:"text" bson:"text"`
+	Image     string `json:"image,omitempty" bson:"image,omitempty"` // s3 object key
+	VoteCount int64  `json:"vote_count" bson:"vote_count"`
+}
+
+// PollVote - one per user and poll, a multiple choice vote holds every chosen option
+type PollVote struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	PostID    primitive.ObjectID `json:"post_id" bson:"post_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Options   []int              `json:"options" bson:"options"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Closed - polls without a closing time stay open
+func (p *Poll) Closed(now time.Time) bool {
+	return p.ClosesAt != nil && !now.Before(*p.ClosesAt)
+}
+
+// openPollCondition - posts whose poll still accepts votes
+func openPollCondition(now time.Time) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"poll.closes_at": bson.M{"$exists": false}},
+		{"poll.closes_at": bson.M{"$gt": now}},
+	}}
+}
+
+// Vote - record the vote and update the tallies in the same transaction
+func (p *PostStorage) Vote(ctx context.Context, postID, userID primitive.ObjectID, options []int) error {
+	client := p.collection.Database().Client()
+	now := time.Now()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		vote := PollVote{ID: primitive.NewObjectID(), PostID: postID, UserID: userID, Options: options, CreatedAt: now}
+		if _, err := p.pollVoteCollection.InsertOne(sessCtx, vote); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, ErrAlreadyVoted
+			}
+			return nil, fmt.Errorf("failed to vote: %w", err)
+		}
+
+		inc := bson.M{"poll.voter_count": 1}
+		for _, option := range options {
+			inc[fmt.Sprintf("poll.options.%d.vote_count", option)] = 1
+		}
+
+		filter := bson.M{"_id": postID, "poll": bson.M{"$exists": true}, "deleted_at": notDeleted(), "$and": []bson.M{openPollCondition(now)}}
+		result, err := p.collection.UpdateOne(sessCtx, filter, bson.M{"$inc": inc})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update poll tallies: %w", err)
+		}
+
+		if result.MatchedCount == 0 {
+			return nil, ErrPollClosed
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// Unvote - votes can only be taken back while the poll is open
+func (p *PostStorage) Unvote(ctx context.Context, postID, userID primitive.ObjectID) error {
+	client := p.collection.Database().Client()
+	now := time.Now()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var vote PollVote
+		err := p.pollVoteCollection.FindOneAndDelete(sessCtx, bson.M{"post_id": postID, "user_id": userID}).Decode(&vote)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrPollVoteMissing
+			}
+			return nil, fmt.Errorf("failed to remove vote: %w", err)
+		}
+
+		inc := bson.M{"poll.voter_count": -1}
+		for _, option := range vote.Options {
+			inc[fmt.Sprintf("poll.options.%d.vote_count", option)] = -1
+		}
+
+		filter := bson.M{"_id": postID, "deleted_at": notDeleted(), "$and": []bson.M{openPollCondition(now)}}
+		result, err := p.collection.UpdateOne(sessCtx, filter, bson.M{"$inc": inc})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update poll tallies: %w", err)
+		}
+
+		if result.MatchedCount == 0 {
+			return nil, ErrPollClosed
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// GetPollVotes - the user's chosen options by post, resolved for a page of posts in one query
+func (p *PostStorage) GetPollVotes(ctx context.Context, userID primitive.ObjectID, postIDs []primitive.ObjectID) (map[primitive.ObjectID][]int, error) {
+	votes := make(map[primitive.ObjectID][]int)
+	if len(postIDs) == 0 {
+		return votes, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	cursor, err := p.pollVoteCollection.Find(ctxTimeout, bson.M{"user_id": userID, "post_id": bson.M{"$in": postIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find poll votes: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	for cursor.Next(ctxTimeout) {
+		var vote PollVote
+		if err := cursor.Decode(&vote); err != nil {
+			return nil, fmt.Errorf("failed to decode poll vote: %w", err)
+		}
+		votes[vote.PostID] = vote.Options
+	}
+
+	return votes, cursor.Err()
+}
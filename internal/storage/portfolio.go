@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrPinLimit = errors.New("pinned post limit reached")
+)
+
+const MaxPinnedPosts = 3
+
+// PortfolioSection - named, ordered group of the user's posts on their profile
+type PortfolioSection struct {
+	ID      primitive.ObjectID   `json:"id" bson:"_id"`
+	Title   string               `json:"title" bson:"title"`
+	PostIDs []primitive.ObjectID `json:"post_ids" bson:"post_ids"`
+}
+
+// PinPost - most recently pinned first, pinning a pinned post is a no-op
+func (u *UserStorage) PinPost(ctx context.Context, userID, postID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	// the limit is part of the filter so concurrent pins can't exceed it
+	filter := bson.M{
+		"_id":             userID,
+		"pinned_post_ids": bson.M{"$ne": postID},
+		fmt.Sprintf("pinned_post_ids.%d", MaxPinnedPosts-1): bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$push": bson.M{"pinned_post_ids": bson.M{"$each": []primitive.ObjectID{postID}, "$position": 0}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := u.collection.UpdateOne(ctxTimeout, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to pin post: %w", err)
+	}
+
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	pinned, err := u.collection.CountDocuments(ctxTimeout, bson.M{"_id": userID, "pinned_post_ids": postID})
+	if err != nil {
+		return fmt.Errorf("failed to check pinned post: %w", err)
+	}
+
+	if pinned == 0 {
+		return ErrPinLimit
+	}
+	return nil
+}
+
+func (u *UserStorage) UnpinPost(ctx context.Context, userID, postID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := u.collection.UpdateOne(ctxTimeout,
+		bson.M{"_id": userID},
+		bson.M{
+			"$pull": bson.M{"pinned_post_ids": postID},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unpin post: %w", err)
+	}
+
+	return nil
+}
+
+// SetPortfolio - replace the featured portfolio, sections and posts keep the given order
+func (u *UserStorage) SetPortfolio(ctx context.Context, userID primitive.ObjectID, sections []PortfolioSection) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := u.collection.UpdateOne(ctxTimeout,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"portfolio": sections, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update portfolio: %w", err)
+	}
+
+	return nil
+}
+
+// removeFromProfile - a deleted post is no longer pinned or featured in the portfolio
+func (p *PostStorage) removeFromProfile(ctx context.Context, userID, postID primitive.ObjectID) error {
+	_, err := p.userCollection.UpdateOne(ctx,
+		bson.M{"_id": userID, "pinned_post_ids": postID},
+		bson.M{"$pull": bson.M{"pinned_post_ids": postID}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unpin deleted post: %w", err)
+	}
+
+	// matching a section first, $[] fails on users without a portfolio
+	_, err = p.userCollection.UpdateOne(ctx,
+		bson.M{"_id": userID, "portfolio.post_ids": postID},
+		bson.M{"$pull": bson.M{"portfolio.$[].post_ids": postID}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove deleted post from portfolio: %w", err)
+	}
+
+	return nil
+}
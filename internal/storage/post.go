@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/hnzhou16/project-cocraft-server/internal/security"
@@ -19,36 +18,92 @@ var (
 )
 
 type Post struct {
-	ID           primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
-	UserID       primitive.ObjectID   `json:"user_id" bson:"user_id"`
-	UserRole     security.Role        `json:"user_role" bson:"user_role"`
-	Title        string               `json:"title" bson:"title"`
-	Content      string               `json:"content" bson:"content"`
-	Tags         []string             `json:"tags,omitempty" bson:"tags,omitempty"`
-	Mentions     []string             `json:"mentions,omitempty" bson:"mentions,omitempty"`
-	Images       []string             `json:"images,omitempty" bson:"images,omitempty"`
-	LikeBy       []primitive.ObjectID `json:"-" bson:"like_by"`
-	LikeCount    int64                `json:"like_count" bson:"like_count"`
-	CommentCount int64                `json:"comment_count" bson:"comment_count"`
-	Version      int64                `json:"version" bson:"version"`
-	CreatedAt    time.Time            `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt    time.Time            `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ID                  primitive.ObjectID         `json:"id,omitempty" bson:"_id,omitempty"`
+	UserID              primitive.ObjectID         `json:"user_id" bson:"user_id"`
+	UserRole            security.Role              `json:"user_role" bson:"user_role"`
+	Type                PostType                   `json:"type" bson:"type"`
+	Status              PostStatus                 `json:"status" bson:"status"`
+	Visibility          PostVisibility             `json:"visibility" bson:"visibility"`
+	ShareToken          string                     `json:"-" bson:"share_token,omitempty"` // sha256 of the unlisted share token
+	PublishAt           *time.Time                 `json:"publish_at,omitempty" bson:"publish_at,omitempty"`
+	PublishedAt         *time.Time                 `json:"published_at,omitempty" bson:"published_at,omitempty"`
+	Title               string                     `json:"title" bson:"title"`
+	Content             string                     `json:"content" bson:"content"`
+	Tags                []string                   `json:"tags,omitempty" bson:"tags,omitempty"`
+	Mentions            []string                   `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	MentionEntities     []Mention                  `json:"mention_entities,omitempty" bson:"mention_entities,omitempty"`
+	Images              []string                   `json:"images,omitempty" bson:"images,omitempty"`
+	Hotspots            []Hotspot                  `json:"hotspots,omitempty" bson:"hotspots,omitempty"`
+	Showcase            *Showcase                  `json:"showcase,omitempty" bson:"showcase,omitempty"`
+	RepostOf            *primitive.ObjectID        `json:"repost_of,omitempty" bson:"repost_of,omitempty"` // original of a repost or quote
+	Poll                *Poll                      `json:"poll,omitempty" bson:"poll,omitempty"`
+	Question            bool                       `json:"question,omitempty" bson:"question,omitempty"` // comments on a question are answers
+	AcceptedAnswerID    *primitive.ObjectID        `json:"accepted_answer_id,omitempty" bson:"accepted_answer_id,omitempty"`
+	ShareCount          int64                      `json:"share_count" bson:"share_count"`
+	TrendingScores      map[TrendingWindow]float64 `json:"-" bson:"trending_scores,omitempty"`       // precomputed per window
+	TrendingRefreshedAt *time.Time                 `json:"-" bson:"trending_refreshed_at,omitempty"` // refresh the scores belong to
+	SearchScore         float64                    `json:"-" bson:"search_score,omitempty"`          // text score, search results only
+	LikeCount           int64                      `json:"like_count" bson:"like_count"`
+	CommentCount        int64                      `json:"comment_count" bson:"comment_count"`
+	ReactionCounts      ReactionCounts             `json:"reaction_counts,omitempty" bson:"reaction_counts,omitempty"`
+	Version             int64                      `json:"version" bson:"version"`
+	EditedAt            *time.Time                 `json:"edited_at,omitempty" bson:"edited_at,omitempty"` // set when a published post is edited
+	DeletedAt           *time.Time                 `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	CreatedAt           time.Time                  `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt           time.Time                  `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// Hotspot - annotated region of a post image, x/y are normalized to [0, 1] from the top-left corner
+type Hotspot struct {
+	ImageIndex int                 `json:"image_index" bson:"image_index"`
+	X          float64             `json:"x" bson:"x"`
+	Y          float64             `json:"y" bson:"y"`
+	Label      string              `json:"label" bson:"label"`
+	UserID     *primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	ProductID  *primitive.ObjectID `json:"product_id,omitempty" bson:"product_id,omitempty"`
+	ProductURL string              `json:"product_url,omitempty" bson:"product_url,omitempty"`
+	ImageURL   string              `json:"image_url,omitempty" bson:"-"` // presigned url of the annotated image, not stored
 }
 
 type PostWithLikeStatus struct {
-	Post        Post   `json:"post"`
-	Username    string `json:"username"`
-	LikedByUser bool   `json:"liked_by_user"`
+	Post           Post              `json:"post"`
+	Username       string            `json:"username"`
+	LikedByUser    bool              `json:"liked_by_user"`
+	SavedByUser    bool              `json:"saved_by_user"`
+	ViewerReaction ReactionType      `json:"viewer_reaction,omitempty"`
+	Original       *SharedPost       `json:"original,omitempty"`   // reposts and quotes only
+	Highlights     []SearchHighlight `json:"highlights,omitempty"` // search results only
 }
 
 type PostStorage struct {
-	collection *mongo.Collection
+	collection           *mongo.Collection
+	revisionCollection   *mongo.Collection
+	commentCollection    *mongo.Collection
+	reactionCollection   *mongo.Collection
+	likeCollection       *mongo.Collection
+	followCollection     *mongo.Collection
+	pollVoteCollection   *mongo.Collection
+	answerVoteCollection *mongo.Collection
+	timelineCollection   *mongo.Collection
+	userCollection       *mongo.Collection
+	forYouCollection     *mongo.Collection
 }
 
 func (p *PostStorage) Create(ctx context.Context, post *Post) error {
 	now := time.Now()
 	post.ID = primitive.NewObjectID()
-	post.LikeBy = []primitive.ObjectID{}
+	if post.Type == "" {
+		post.Type = PostStandard
+	}
+	if post.Status == "" {
+		post.Status = PostPublished
+	}
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+	if post.Status == PostPublished && post.PublishedAt == nil {
+		post.PublishedAt = &now
+	}
 	post.CreatedAt = now
 	post.UpdatedAt = now
 
@@ -66,43 +121,72 @@ func (p *PostStorage) Create(ctx context.Context, post *Post) error {
 }
 
 func (p *PostStorage) GetFeed(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, error) {
-	filter := bson.M{}
+	filter := bson.M{"status": PostPublished, "deleted_at": notDeleted()}
 
 	sort := -1
 	if cq.Sort == "asc" {
 		sort = 1
 	}
 
+	visible, err := p.visibilityCondition(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	andConditions := []bson.M{visible}
 	if user != nil {
+		// posts of followed users or with followed tags
+		var following []bson.M
 		if cq.ShowFollowing && len(cq.FolloweeIDs) > 0 {
-			filter["user_id"] = bson.M{"$in": cq.FolloweeIDs}
+			following = append(following, bson.M{"user_id": bson.M{"$in": cq.FolloweeIDs}})
+		}
+		if cq.ShowFollowing && len(cq.FollowedTags) > 0 {
+			following = append(following, bson.M{"tags": bson.M{"$in": cq.FollowedTags}})
+		}
+		if len(following) > 0 {
+			andConditions = append(andConditions, bson.M{"$or": following})
 		}
 
 		if cq.ShowMentioned {
-			// Check if the array contains the username
-			filter["mentions"] = user.Username // !!! Direct equality check
+			mentioned, err := p.mentionedCondition(ctx, user.Username)
+			if err != nil {
+				return nil, err
+			}
+			andConditions = append(andConditions, mentioned)
 		}
 	}
 
+	filter["$and"] = andConditions
+
+	if len(cq.Tags) > 0 {
+		filter["tags"] = bson.M{"$in": cq.Tags}
+	}
+
 	if len(cq.Roles) > 0 {
 		filter["user_role"] = bson.M{"$in": cq.Roles}
 	}
 
-	// cursor query based on post id
+	if len(cq.Types) > 0 {
+		filter["type"] = typeCondition(cq.Types)
+	}
+
+	if cq.QuestionStatus != "" {
+		andConditions = append(andConditions, questionCondition(cq.QuestionStatus))
+		filter["$and"] = andConditions
+	}
+
+	// cursor query based on publish time, ties broken by post id
 	if cq.Cursor != "" && cq.Cursor != "undefined" {
-		cursorID, err := primitive.ObjectIDFromHex(cq.Cursor)
+		cursor, err := publishedCursorCondition(cq.Cursor, sort)
 		if err != nil {
-			return nil, fmt.Errorf("invalid cursor ID: %w", err)
-		}
-		if sort == -1 {
-			filter["_id"] = bson.M{"$lt": cursorID}
-		} else {
-			filter["_id"] = bson.M{"$gt": cursorID}
+			return nil, err
 		}
+		andConditions = append(andConditions, cursor)
+		filter["$and"] = andConditions
 	}
 
 	opts := options.Find().
-		SetSort(bson.M{"created_at": sort}).
+		SetSort(bson.D{{Key: "published_at", Value: sort}, {Key: "_id", Value: sort}}).
 		SetLimit(int64(cq.Limit))
 
 	cursor, err := p.collection.Find(ctx, filter, opts)
@@ -117,91 +201,50 @@ func (p *PostStorage) GetFeed(ctx context.Context, user *User, cq CursorQuery) (
 		return nil, fmt.Errorf("failed to decode posts: %w", err)
 	}
 
-	var result []PostWithLikeStatus
-	if user == nil {
-		for _, post := range posts {
-			result = append(result, PostWithLikeStatus{Post: post, LikedByUser: false})
-		}
-		return result, nil
-	}
-
-	for _, post := range posts {
-		liked := false
-		for _, id := range post.LikeBy {
-			if user.ID == id {
-				liked = true
-				break
-			}
-		}
-		result = append(result, PostWithLikeStatus{Post: post, LikedByUser: liked})
-	}
+	return p.withLikeStatus(ctx, user, posts)
+}
 
-	return result, nil
+// PostCursor - feed pages continue after the publish time and id of the last post
+func PostCursor(post Post) string {
+	return postTimelineKey(post).String()
 }
 
-func (p *PostStorage) GetTrending(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, error) {
-	pipeline := mongo.Pipeline{
-		// Optional: only show past 48hr posts
-		//{{Key: "$match", Value: bson.M{
-		//	"created_at": bson.M{"$gte": time.Now().Add(-48 * time.Hour)},
-		//}}},
-		{{Key: "$addFields", Value: bson.M{
-			"engagement_score": bson.M{"$add": bson.A{"$like_count", "$comment_count"}},
-		}}},
-		{{Key: "$sort", Value: bson.D{
-			{Key: "engagement_score", Value: -1},
-			{Key: "created_at", Value: -1}, // tie-breaker
-		}}},
-		{{Key: "$limit", Value: cq.Limit}},
-	}
-
-	cursor, err := p.collection.Aggregate(ctx, pipeline)
+// publishedCursorCondition - descending pages continue with older posts, ascending with newer ones
+func publishedCursorCondition(cursor string, sort int) (bson.M, error) {
+	key, err := parseTimelineKey(cursor)
 	if err != nil {
-		return nil, fmt.Errorf("failed to aggregate trending posts: %w", err)
+		return nil, err
 	}
-	defer cursor.Close(ctx)
 
-	var posts []Post
-
-	if err := cursor.All(ctx, &posts); err != nil {
-		return nil, fmt.Errorf("failed to decode posts: %w", err)
-	}
-
-	var result []PostWithLikeStatus
-	if user == nil {
-		for _, post := range posts {
-			result = append(result, PostWithLikeStatus{Post: post, LikedByUser: false})
-		}
-		return result, nil
+	op := "$lt"
+	if sort == 1 {
+		op = "$gt"
 	}
-
-	for _, post := range posts {
-		liked := false
-		for _, id := range post.LikeBy {
-			if user.ID == id {
-				liked = true
-				break
-			}
-		}
-		result = append(result, PostWithLikeStatus{Post: post, LikedByUser: liked})
-	}
-
-	return result, nil
+	return bson.M{"$or": []bson.M{
+		{"published_at": bson.M{op: key.publishedAt}},
+		{"published_at": key.publishedAt, "_id": bson.M{op: key.id}},
+	}}, nil
 }
 
-func (p *PostStorage) GetByID(ctx context.Context, postID string) (*Post, error) {
+// GetByID - posts the viewer can't see are not found
+func (p *PostStorage) GetByID(ctx context.Context, postID string, viewer *User) (*Post, error) {
 	// ObjectID in MongoDB is a 12-byte binary value represented as a 24-character hexadecimal string
 	objID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert postID to ObjectID: %w", err)
 	}
 
+	visible, err := p.visibilityCondition(ctx, viewer)
+	if err != nil {
+		return nil, err
+	}
+
 	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	var post Post
 	// Decode is MongoDB method to deserialize result in BSON of a query into Go struct
-	err = p.collection.FindOne(ctxTimeout, bson.M{"_id": objID}).Decode(&post)
+	err = p.collection.FindOne(ctxTimeout, bson.M{"_id": objID, "deleted_at": notDeleted(), "$and": []bson.M{visible}}).Decode(&post)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, ErrPostNotFound
@@ -212,222 +255,155 @@ func (p *PostStorage) GetByID(ctx context.Context, postID string) (*Post, error)
 	return &post, nil
 }
 
-func (p *PostStorage) GetByUserID(ctx context.Context, userID primitive.ObjectID, cq CursorQuery) ([]PostWithLikeStatus, error) {
-	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
-	defer cancel()
-
-	sort := -1
-	if cq.Sort == "asc" {
-		sort = 1
+// GetByIDs - posts in no particular order, missing ids and posts the viewer can't see are skipped
+func (p *PostStorage) GetByIDs(ctx context.Context, postIDs []primitive.ObjectID, viewer *User) ([]Post, error) {
+	posts := make([]Post, 0, len(postIDs))
+	if len(postIDs) == 0 {
+		return posts, nil
 	}
 
-	filter := bson.M{"user_id": userID}
-
-	// cursor query based on post id
-	if cq.Cursor != "" && cq.Cursor != "undefined" {
-		cursorID, err := primitive.ObjectIDFromHex(cq.Cursor)
-		if err != nil {
-			return nil, fmt.Errorf("invalid cursor ID: %w", err)
-		}
-		if sort == -1 {
-			filter["_id"] = bson.M{"$lt": cursorID}
-		} else {
-			filter["_id"] = bson.M{"$gt": cursorID}
-		}
+	visible, err := p.visibilityCondition(ctx, viewer)
+	if err != nil {
+		return nil, err
 	}
 
-	opts := options.Find().
-		SetSort(bson.M{"created_at": sort}).
-		SetLimit(int64(cq.Limit))
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
 
-	cursor, err := p.collection.Find(ctx, filter, opts)
+	cursor, err := p.collection.Find(ctxTimeout, bson.M{"_id": bson.M{"$in": postIDs}, "deleted_at": notDeleted(), "$and": []bson.M{visible}})
 	if err != nil {
 		return nil, fmt.Errorf("failed to find posts: %w", err)
 	}
-	defer cursor.Close(ctx)
-
-	var posts []Post
+	defer cursor.Close(ctxTimeout)
 
 	if err := cursor.All(ctxTimeout, &posts); err != nil {
 		return nil, fmt.Errorf("failed to decode posts: %w", err)
 	}
 
-	var result []PostWithLikeStatus
-	for _, post := range posts {
-		liked := false
-		for _, id := range post.LikeBy {
-			if userID == id {
-				liked = true
-				break
-			}
-		}
-		result = append(result, PostWithLikeStatus{Post: post, LikedByUser: liked})
-	}
-
-	return result, nil
+	return posts, nil
 }
 
-func (p *PostStorage) GetCountByUserID(ctx context.Context, userID primitive.ObjectID) (int, error) {
+// GetByUserID - published posts of the user, liked status is for the viewer
+func (p *PostStorage) GetByUserID(ctx context.Context, userID primitive.ObjectID, viewer *User, cq CursorQuery) ([]PostWithLikeStatus, error) {
 	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	count, err := p.collection.CountDocuments(ctxTimeout, bson.M{"user_id": userID})
-	if err != nil {
-		return 0, fmt.Errorf("failed to count posts: %w", err)
-	}
-
-	return int(count), nil
-}
-
-func (p *PostStorage) Search(ctx context.Context, user *User, query string, cq CursorQuery) ([]PostWithLikeStatus, error) {
-	log.Println(cq)
-
-	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
-	defer cancel()
-
-	regex := bson.M{"$regex": primitive.Regex{Pattern: query, Options: "i"}} // case-insensitive
-
-	filter := bson.M{}
-
-	orConditions := []bson.M{
-		{"title": regex},
-		{"content": regex},
-		{"tags": regex},
-	}
-
-	andConditions := []bson.M{
-		{"$or": orConditions},
-	}
-
 	sort := -1
 	if cq.Sort == "asc" {
 		sort = 1
 	}
 
-	if user != nil {
-		if cq.ShowFollowing && len(cq.FolloweeIDs) > 0 {
-			andConditions = append(andConditions, bson.M{"user_id": bson.M{"$in": cq.FolloweeIDs}})
-		}
+	visible, err := p.visibilityCondition(ctx, viewer)
+	if err != nil {
+		return nil, err
+	}
 
-		if cq.ShowMentioned {
-			// Check if the array contains the username
-			andConditions = append(andConditions, bson.M{"mentions": user.Username}) // !!! Direct equality check
-		}
+	filter := bson.M{"user_id": userID, "status": PostPublished, "deleted_at": notDeleted(), "$and": []bson.M{visible}}
 
-		if len(cq.Roles) > 0 {
-			andConditions = append(andConditions, bson.M{"user_role": bson.M{"$in": cq.Roles}})
-		}
+	if len(cq.Types) > 0 {
+		filter["type"] = typeCondition(cq.Types)
 	}
 
-	// cursor query based on post id
+	// cursor query based on publish time, ties broken by post id
 	if cq.Cursor != "" && cq.Cursor != "undefined" {
-		cursorID, err := primitive.ObjectIDFromHex(cq.Cursor)
+		cursor, err := publishedCursorCondition(cq.Cursor, sort)
 		if err != nil {
-			return nil, fmt.Errorf("invalid cursor ID: %w", err)
-		}
-		if sort == -1 {
-			andConditions = append(andConditions, bson.M{"_id": bson.M{"$lt": cursorID}})
-		} else {
-			andConditions = append(andConditions, bson.M{"_id": bson.M{"$gt": cursorID}})
+			return nil, err
 		}
+		filter["$and"] = []bson.M{visible, cursor}
 	}
 
-	filter["$and"] = andConditions
-
 	opts := options.Find().
-		SetSort(bson.M{"_id": sort}).
+		SetSort(bson.D{{Key: "published_at", Value: sort}, {Key: "_id", Value: sort}}).
 		SetLimit(int64(cq.Limit))
 
-	cursor, err := p.collection.Find(ctxTimeout, filter, opts)
+	cursor, err := p.collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find posts: %w", err)
 	}
 	defer cursor.Close(ctx)
 
 	var posts []Post
+
 	if err := cursor.All(ctxTimeout, &posts); err != nil {
 		return nil, fmt.Errorf("failed to decode posts: %w", err)
 	}
 
-	var result []PostWithLikeStatus
-	for _, post := range posts {
-		liked := false
-		if user != nil {
-			for _, id := range post.LikeBy {
-				if user.ID == id {
-					liked = true
-					break
-				}
-			}
-		}
-		result = append(result, PostWithLikeStatus{
-			Post:        post,
-			LikedByUser: liked,
-		})
-	}
-
-	return result, nil
+	return p.withLikeStatus(ctx, viewer, posts)
 }
 
-func (p *PostStorage) Update(ctx context.Context, post *Post) error {
-	var objID = post.ID
-	now := time.Now()
-
-	update := bson.M{
-		"$set": bson.M{
-			"title":      post.Title,
-			"content":    post.Content,
-			"tags":       post.Tags,
-			"mentions":   post.Mentions,
-			"images":     post.Images,
-			"version":    post.Version,
-			"updated_at": now,
-		},
+// GetCountByUserID - published posts of the user the viewer can see
+func (p *PostStorage) GetCountByUserID(ctx context.Context, userID primitive.ObjectID, viewer *User) (int, error) {
+	visible, err := p.visibilityCondition(ctx, viewer)
+	if err != nil {
+		return 0, err
 	}
 
 	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	_, err := p.collection.UpdateOne(ctxTimeout, bson.M{"_id": objID}, update)
+	filter := bson.M{"user_id": userID, "status": PostPublished, "deleted_at": notDeleted(), "$and": []bson.M{visible}}
+	count, err := p.collection.CountDocuments(ctxTimeout, filter)
 	if err != nil {
-		return fmt.Errorf("failed to update post: %w", err)
+		return 0, fmt.Errorf("failed to count posts: %w", err)
 	}
 
-	return nil
+	return int(count), nil
 }
 
-func (p *PostStorage) ToggleLike(ctx context.Context, userID primitive.ObjectID, post *Post) (bool, error) {
-	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
-	defer cancel()
+// Update - only applies if the stored version still equals post.Version, the replaced version is kept as a revision
+func (p *PostStorage) Update(ctx context.Context, post *Post) error {
+	client := p.collection.Database().Client()
+	now := time.Now()
 
-	liked := false
-	for _, id := range post.LikeBy {
-		if id == userID {
-			liked = true
-			break
-		}
+	filter := bson.M{"_id": post.ID, "version": post.Version, "deleted_at": notDeleted()}
+	update := bson.M{
+		"$set": bson.M{
+			"title":            post.Title,
+			"content":          post.Content,
+			"tags":             post.Tags,
+			"mentions":         post.Mentions,
+			"mention_entities": post.MentionEntities,
+			"images":           post.Images,
+			"hotspots":         post.Hotspots,
+			"showcase":         post.Showcase,
+			"status":           post.Status,
+			"visibility":       post.Visibility,
+			"publish_at":       post.PublishAt,
+			"published_at":     post.PublishedAt,
+			"edited_at":        post.EditedAt,
+			"updated_at":       now,
+		},
+		"$inc": bson.M{"version": 1},
 	}
 
-	// "$xx" make sure updating 'like_by' and 'like_count' is atomic
-	var update = bson.M{}
-	if liked {
-		update = bson.M{
-			"$pull": bson.M{"like_by": userID},
-			"$inc":  bson.M{"like_count": -1},
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var previous Post
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+		if err := p.collection.FindOneAndUpdate(sessCtx, filter, update, opts).Decode(&previous); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrVersionConflict
+			}
+			return nil, fmt.Errorf("failed to update post: %w", err)
 		}
-	} else {
-		update = bson.M{
-			"$addToSet": bson.M{"like_by": userID},
-			"$inc":      bson.M{"like_count": 1},
+
+		if _, err := p.revisionCollection.InsertOne(sessCtx, newRevision(&previous, now)); err != nil {
+			return nil, fmt.Errorf("failed to create post revision: %w", err)
 		}
+
+		return nil, nil
 	}
 
-	_, err := p.collection.UpdateByID(ctxTimeout, post.ID, update)
-	if err != nil {
-		return liked, fmt.Errorf("failed to update post: %w", err)
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if err := withTransaction(ctxTimeout, client, txnFunc); err != nil {
+		return err
 	}
 
-	return !liked, nil
+	post.Version++
+	post.UpdatedAt = now
+	return nil
 }
 
 func (p *PostStorage) IncrementCommentCount(ctx context.Context, postID primitive.ObjectID) error {
@@ -438,22 +414,38 @@ func (p *PostStorage) IncrementCommentCount(ctx context.Context, postID primitiv
 	return err
 }
 
+// Delete - soft delete, the post stays restorable until it is purged after TrashRetention
 func (p *PostStorage) Delete(ctx context.Context, postID string) error {
 	objID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
 		return fmt.Errorf("failed to convert postID to ObjectID: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
-	defer cancel()
+	client := p.collection.Database().Client()
 
-	_, err = p.collection.DeleteOne(ctx, bson.M{"_id": objID})
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return fmt.Errorf("post not found")
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var deleted Post
+		err := p.collection.FindOneAndUpdate(sessCtx,
+			bson.M{"_id": objID, "deleted_at": notDeleted()},
+			bson.M{"$set": bson.M{"deleted_at": time.Now()}},
+		).Decode(&deleted)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrPostNotFound
+			}
+			return nil, fmt.Errorf("failed to delete post: %w", err)
+		}
+
+		if err := p.removeFromProfile(sessCtx, deleted.UserID, deleted.ID); err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("failed to delete post: %w", err)
+
+		// a deleted quote no longer counts as a share of the original
+		return nil, p.incrementShareCount(sessCtx, deleted.RepostOf, -1)
 	}
 
-	return nil
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
 }
@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrDupSKU          = errors.New("a product with this sku already exists")
+)
+
+type Product struct {
+	ID             primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	ManufacturerID primitive.ObjectID `json:"manufacturer_id" bson:"manufacturer_id"`
+	SKU            string             `json:"sku" bson:"sku"`
+	Name           string             `json:"name" bson:"name"`
+	Description    string             `json:"description,omitempty" bson:"description,omitempty"`
+	Category       string             `json:"category" bson:"category"`
+	Materials      []string           `json:"materials,omitempty" bson:"materials,omitempty"`
+	Dimensions     Dimensions         `json:"dimensions,omitempty" bson:"dimensions,omitempty"`
+	Finishes       []string           `json:"finishes,omitempty" bson:"finishes,omitempty"`
+	PriceRange     PriceRange         `json:"price_range" bson:"price_range"`
+	Images         []string           `json:"images,omitempty" bson:"images,omitempty"`           // s3 object keys
+	SpecSheets     []string           `json:"spec_sheets,omitempty" bson:"spec_sheets,omitempty"` // s3 object keys of pdf files
+	CreatedAt      time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt      time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+type Dimensions struct {
+	Width  float64 `json:"width,omitempty" bson:"width,omitempty"`
+	Height float64 `json:"height,omitempty" bson:"height,omitempty"`
+	Depth  float64 `json:"depth,omitempty" bson:"depth,omitempty"`
+	Unit   string  `json:"unit,omitempty" bson:"unit,omitempty"`
+}
+
+type PriceRange struct {
+	Min      float64 `json:"min" bson:"min"`
+	Max      float64 `json:"max" bson:"max"`
+	Currency string  `json:"currency" bson:"currency"`
+}
+
+// FacetCount - number of products matching the current filters for a single facet value
+type FacetCount struct {
+	Value string `json:"value" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+type ProductFacets struct {
+	Categories []FacetCount `json:"categories" bson:"categories"`
+	Materials  []FacetCount `json:"materials" bson:"materials"`
+	Finishes   []FacetCount `json:"finishes" bson:"finishes"`
+}
+
+// ProductQuery - filters for browsing the catalog, facets are matched case-insensitively
+type ProductQuery struct {
+	Limit          int                 `json:"limit,omitempty" validate:"gte=1,lte=20"`
+	Cursor         string              `json:"cursor,omitempty"`
+	Search         string              `json:"search,omitempty" validate:"max=100"`
+	Category       string              `json:"category,omitempty"`
+	Materials      []string            `json:"materials,omitempty"`
+	Finishes       []string            `json:"finishes,omitempty"`
+	MinPrice       *float64            `json:"min_price,omitempty" validate:"omitempty,gte=0"`
+	MaxPrice       *float64            `json:"max_price,omitempty" validate:"omitempty,gte=0"`
+	ManufacturerID *primitive.ObjectID `json:"manufacturer_id,omitempty"`
+}
+
+func (pq *ProductQuery) Parse(r *http.Request) error {
+	q := r.URL.Query()
+
+	if limit, err := strconv.Atoi(q.Get("limit")); err == nil && limit > 0 {
+		pq.Limit = limit
+	}
+
+	if cursor := q.Get("cursor"); cursor != "" && cursor != "undefined" {
+		pq.Cursor = cursor
+	}
+
+	if search := q.Get("search"); search != "" && search != "undefined" {
+		pq.Search = search
+	}
+
+	if category := q.Get("category"); category != "" && category != "undefined" {
+		pq.Category = category
+	}
+
+	if materials := q.Get("materials"); materials != "" && materials != "undefined" {
+		pq.Materials = strings.Split(materials, ",")
+	}
+
+	if finishes := q.Get("finishes"); finishes != "" && finishes != "undefined" {
+		pq.Finishes = strings.Split(finishes, ",")
+	}
+
+	if minPrice := q.Get("min_price"); minPrice != "" {
+		v, err := strconv.ParseFloat(minPrice, 64)
+		if err != nil {
+			return fmt.Errorf("invalid min_price: %w", err)
+		}
+		pq.MinPrice = &v
+	}
+
+	if maxPrice := q.Get("max_price"); maxPrice != "" {
+		v, err := strconv.ParseFloat(maxPrice, 64)
+		if err != nil {
+			return fmt.Errorf("invalid max_price: %w", err)
+		}
+		pq.MaxPrice = &v
+	}
+
+	return nil
+}
+
+// exactMatch - case-insensitive regex matching the whole value, user input is escaped
+func exactMatch(value string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.TrimSpace(value)) + "$", Options: "i"}
+}
+
+// filter - build the mongo filter shared by browsing and facet counting, cursor is excluded
+func (pq *ProductQuery) filter() bson.M {
+	filter := bson.M{}
+
+	if pq.ManufacturerID != nil {
+		filter["manufacturer_id"] = *pq.ManufacturerID
+	}
+
+	if pq.Category != "" {
+		filter["category"] = exactMatch(pq.Category)
+	}
+
+	if len(pq.Materials) > 0 {
+		materials := make(bson.A, 0, len(pq.Materials))
+		for _, m := range pq.Materials {
+			materials = append(materials, exactMatch(m))
+		}
+		filter["materials"] = bson.M{"$in": materials}
+	}
+
+	if len(pq.Finishes) > 0 {
+		finishes := make(bson.A, 0, len(pq.Finishes))
+		for _, f := range pq.Finishes {
+			finishes = append(finishes, exactMatch(f))
+		}
+		filter["finishes"] = bson.M{"$in": finishes}
+	}
+
+	// a product matches when its price range overlaps the requested one
+	if pq.MinPrice != nil {
+		filter["price_range.max"] = bson.M{"$gte": *pq.MinPrice}
+	}
+	if pq.MaxPrice != nil {
+		filter["price_range.min"] = bson.M{"$lte": *pq.MaxPrice}
+	}
+
+	if pq.Search != "" {
+		regex := bson.M{"$regex": primitive.Regex{Pattern: regexp.QuoteMeta(pq.Search), Options: "i"}}
+		filter["$or"] = []bson.M{
+			{"name": regex},
+			{"sku": regex},
+			{"description": regex},
+		}
+	}
+
+	return filter
+}
+
+type ProductStorage struct {
+	collection *mongo.Collection
+}
+
+func (p *ProductStorage) Create(ctx context.Context, product *Product) error {
+	now := time.Now()
+	product.ID = primitive.NewObjectID()
+	product.CreatedAt = now
+	product.UpdatedAt = now
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := p.collection.InsertOne(ctxTimeout, product)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDupSKU
+		}
+		return fmt.Errorf("failed to create product: %w", err)
+	}
+
+	return nil
+}
+
+func (p *ProductStorage) GetByID(ctx context.Context, productID string) (*Product, error) {
+	objID, err := primitive.ObjectIDFromHex(productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert productID to ObjectID: %w", err)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var product Product
+	err = p.collection.FindOne(ctxTimeout, bson.M{"_id": objID}).Decode(&product)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrProductNotFound
+		}
+		return nil, fmt.Errorf("product query failed: %w", err)
+	}
+
+	return &product, nil
+}
+
+func (p *ProductStorage) Browse(ctx context.Context, pq ProductQuery) ([]Product, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := pq.filter()
+
+	// cursor query based on product id, newest first
+	if pq.Cursor != "" {
+		cursorID, err := primitive.ObjectIDFromHex(pq.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor ID: %w", err)
+		}
+		filter["_id"] = bson.M{"$lt": cursorID}
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"_id": -1}).
+		SetLimit(int64(pq.Limit))
+
+	cursor, err := p.collection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find products: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	products := make([]Product, 0)
+	if err := cursor.All(ctxTimeout, &products); err != nil {
+		return nil, fmt.Errorf("failed to decode products: %w", err)
+	}
+
+	return products, nil
+}
+
+// GetFacets - count category/material/finish values across all products matching the filters
+func (p *ProductStorage) GetFacets(ctx context.Context, pq ProductQuery) (*ProductFacets, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	facetOf := func(field string, unwind bool) mongo.Pipeline {
+		var stages mongo.Pipeline
+		if unwind {
+			stages = append(stages, bson.D{{Key: "$unwind", Value: "$" + field}})
+		}
+		return append(stages,
+			bson.D{{Key: "$group", Value: bson.M{"_id": bson.M{"$toLower": "$" + field}, "count": bson.M{"$sum": 1}}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: pq.filter()}},
+		{{Key: "$facet", Value: bson.M{
+			"categories": facetOf("category", false),
+			"materials":  facetOf("materials", true),
+			"finishes":   facetOf("finishes", true),
+		}}},
+	}
+
+	cursor, err := p.collection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate product facets: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	var facets []ProductFacets
+	if err := cursor.All(ctxTimeout, &facets); err != nil {
+		return nil, fmt.Errorf("failed to decode product facets: %w", err)
+	}
+
+	if len(facets) == 0 {
+		return &ProductFacets{}, nil
+	}
+
+	return &facets[0], nil
+}
+
+func (p *ProductStorage) Update(ctx context.Context, product *Product) error {
+	product.UpdatedAt = time.Now()
+
+	update := bson.M{
+		"$set": bson.M{
+			"sku":         product.SKU,
+			"name":        product.Name,
+			"description": product.Description,
+			"category":    product.Category,
+			"materials":   product.Materials,
+			"dimensions":  product.Dimensions,
+			"finishes":    product.Finishes,
+			"price_range": product.PriceRange,
+			"images":      product.Images,
+			"spec_sheets": product.SpecSheets,
+			"updated_at":  product.UpdatedAt,
+		},
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := p.collection.UpdateOne(ctxTimeout, bson.M{"_id": product.ID}, update)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDupSKU
+		}
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	return nil
+}
+
+func (p *ProductStorage) Delete(ctx context.Context, productID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := p.collection.DeleteOne(ctxTimeout, bson.M{"_id": productID})
+	if err != nil {
+		return fmt.Errorf("failed to delete product: %w", err)
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrProductNotFound
+	}
+
+	return nil
+}
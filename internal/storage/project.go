@@ -0,0 +1,641 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/security"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrProjectNotFound         = errors.New("project not found")
+	ErrProjectTaskNotFound     = errors.New("project task not found")
+	ErrProjectDocumentNotFound = errors.New("project document not found")
+	ErrAlreadyMember           = errors.New("user is already a project member")
+	ErrNotMember               = errors.New("user is not a project member")
+)
+
+// ProjectRole - access level of a member inside a project workspace
+type ProjectRole string
+
+const (
+	ProjectRoleOwner   ProjectRole = "owner"
+	ProjectRoleManager ProjectRole = "manager"
+	ProjectRoleMember  ProjectRole = "member"
+	ProjectRoleViewer  ProjectRole = "viewer"
+)
+
+var projectRoleRank = map[ProjectRole]int{
+	ProjectRoleViewer:  1,
+	ProjectRoleMember:  2,
+	ProjectRoleManager: 3,
+	ProjectRoleOwner:   4,
+}
+
+// AtLeast - whether the role grants at least the access of the required role
+func (r ProjectRole) AtLeast(required ProjectRole) bool {
+	return projectRoleRank[r] >= projectRoleRank[required]
+}
+
+// activity actions recorded in the project activity log
+const (
+	ActivityProjectCreated  = "project_created"
+	ActivityProjectUpdated  = "project_updated"
+	ActivityMemberAdded     = "member_added"
+	ActivityMemberUpdated   = "member_updated"
+	ActivityMemberRemoved   = "member_removed"
+	ActivityUpdatePosted    = "update_posted"
+	ActivityTaskCreated     = "task_created"
+	ActivityTaskUpdated     = "task_updated"
+	ActivityTaskCompleted   = "task_completed"
+	ActivityTaskDeleted     = "task_deleted"
+	ActivityDocumentAdded   = "document_added"
+	ActivityDocumentRemoved = "document_removed"
+)
+
+type Project struct {
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	OwnerID     primitive.ObjectID `json:"owner_id" bson:"owner_id"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	Members     []ProjectMember    `json:"members" bson:"members"`
+	CreatedAt   time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt   time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// ProjectMember - Trade is the platform role of the member (designer, contractor...) at the time they joined
+type ProjectMember struct {
+	UserID   primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Role     ProjectRole        `json:"role" bson:"role"`
+	Trade    security.Role      `json:"trade" bson:"trade"`
+	JoinedAt time.Time          `json:"joined_at" bson:"joined_at"`
+}
+
+// ProjectUpdate - timeline entry, images are s3 object keys
+type ProjectUpdate struct {
+	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	ProjectID primitive.ObjectID `json:"project_id" bson:"project_id"`
+	AuthorID  primitive.ObjectID `json:"author_id" bson:"author_id"`
+	Content   string             `json:"content" bson:"content"`
+	Images    []string           `json:"images,omitempty" bson:"images,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type ProjectTask struct {
+	ID          primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+	ProjectID   primitive.ObjectID  `json:"project_id" bson:"project_id"`
+	CreatedBy   primitive.ObjectID  `json:"created_by" bson:"created_by"`
+	Title       string              `json:"title" bson:"title"`
+	Description string              `json:"description,omitempty" bson:"description,omitempty"`
+	AssigneeID  *primitive.ObjectID `json:"assignee_id,omitempty" bson:"assignee_id,omitempty"`
+	DueDate     *time.Time          `json:"due_date,omitempty" bson:"due_date,omitempty"`
+	Completed   bool                `json:"completed" bson:"completed"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at" bson:"updated_at"`
+}
+
+// ProjectDocument - shared file, key is the s3 object key
+type ProjectDocument struct {
+	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	ProjectID  primitive.ObjectID `json:"project_id" bson:"project_id"`
+	UploadedBy primitive.ObjectID `json:"uploaded_by" bson:"uploaded_by"`
+	Name       string             `json:"name" bson:"name"`
+	Key        string             `json:"key" bson:"key"`
+	URL        string             `json:"url,omitempty" bson:"-"` // presigned url, not stored
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type ProjectActivity struct {
+	ID        primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+	ProjectID primitive.ObjectID  `json:"project_id" bson:"project_id"`
+	ActorID   primitive.ObjectID  `json:"actor_id" bson:"actor_id"`
+	Action    string              `json:"action" bson:"action"`
+	TargetID  *primitive.ObjectID `json:"target_id,omitempty" bson:"target_id,omitempty"`
+	CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// Member - nil if the user is not a member of the project
+func (p *Project) Member(userID primitive.ObjectID) *ProjectMember {
+	for i := range p.Members {
+		if p.Members[i].UserID == userID {
+			return &p.Members[i]
+		}
+	}
+	return nil
+}
+
+type ProjectStorage struct {
+	collection         *mongo.Collection
+	updateCollection   *mongo.Collection
+	taskCollection     *mongo.Collection
+	documentCollection *mongo.Collection
+	activityCollection *mongo.Collection
+}
+
+// logActivity - called inside the transaction of the change it records
+func (p *ProjectStorage) logActivity(ctx context.Context, projectID, actorID primitive.ObjectID, action string, targetID *primitive.ObjectID) error {
+	_, err := p.activityCollection.InsertOne(ctx, ProjectActivity{
+		ID:        primitive.NewObjectID(),
+		ProjectID: projectID,
+		ActorID:   actorID,
+		Action:    action,
+		TargetID:  targetID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to log project activity: %w", err)
+	}
+	return nil
+}
+
+func (p *ProjectStorage) Create(ctx context.Context, project *Project, owner *User) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		now := time.Now()
+		project.ID = primitive.NewObjectID()
+		project.OwnerID = owner.ID
+		project.Members = []ProjectMember{{
+			UserID:   owner.ID,
+			Role:     ProjectRoleOwner,
+			Trade:    owner.Role,
+			JoinedAt: now,
+		}}
+		project.CreatedAt = now
+		project.UpdatedAt = now
+
+		if _, err := p.collection.InsertOne(sessCtx, project); err != nil {
+			return nil, fmt.Errorf("failed to create project: %w", err)
+		}
+
+		return nil, p.logActivity(sessCtx, project.ID, owner.ID, ActivityProjectCreated, nil)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+func (p *ProjectStorage) GetByID(ctx context.Context, projectID string) (*Project, error) {
+	objID, err := primitive.ObjectIDFromHex(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert projectID to ObjectID: %w", err)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var project Project
+	err = p.collection.FindOne(ctxTimeout, bson.M{"_id": objID}).Decode(&project)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("project query failed: %w", err)
+	}
+
+	return &project, nil
+}
+
+// GetByMemberID - projects the user is a member of, most recently active first
+func (p *ProjectStorage) GetByMemberID(ctx context.Context, userID primitive.ObjectID) ([]Project, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	cursor, err := p.collection.Find(ctxTimeout,
+		bson.M{"members.user_id": userID},
+		options.Find().SetSort(bson.M{"updated_at": -1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find projects: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	projects := make([]Project, 0)
+	if err := cursor.All(ctxTimeout, &projects); err != nil {
+		return nil, fmt.Errorf("failed to decode projects: %w", err)
+	}
+
+	return projects, nil
+}
+
+func (p *ProjectStorage) Update(ctx context.Context, project *Project, actorID primitive.ObjectID) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		project.UpdatedAt = time.Now()
+
+		_, err := p.collection.UpdateByID(sessCtx, project.ID, bson.M{"$set": bson.M{
+			"name":        project.Name,
+			"description": project.Description,
+			"updated_at":  project.UpdatedAt,
+		}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update project: %w", err)
+		}
+
+		return nil, p.logActivity(sessCtx, project.ID, actorID, ActivityProjectUpdated, nil)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+// Delete - removes the project together with its timeline, tasks, documents and activity
+func (p *ProjectStorage) Delete(ctx context.Context, projectID primitive.ObjectID) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := p.collection.DeleteOne(sessCtx, bson.M{"_id": projectID}); err != nil {
+			return nil, fmt.Errorf("failed to delete project: %w", err)
+		}
+
+		for _, c := range []*mongo.Collection{p.updateCollection, p.taskCollection, p.documentCollection, p.activityCollection} {
+			if _, err := c.DeleteMany(sessCtx, bson.M{"project_id": projectID}); err != nil {
+				return nil, fmt.Errorf("failed to delete project %s: %w", c.Name(), err)
+			}
+		}
+
+		return nil, nil
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+func (p *ProjectStorage) AddMember(ctx context.Context, projectID, actorID primitive.ObjectID, member ProjectMember) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		member.JoinedAt = time.Now()
+
+		result, err := p.collection.UpdateOne(sessCtx,
+			bson.M{"_id": projectID, "members.user_id": bson.M{"$ne": member.UserID}},
+			bson.M{
+				"$push": bson.M{"members": member},
+				"$set":  bson.M{"updated_at": member.JoinedAt},
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add project member: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			return nil, ErrAlreadyMember
+		}
+
+		return nil, p.logActivity(sessCtx, projectID, actorID, ActivityMemberAdded, &member.UserID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+func (p *ProjectStorage) UpdateMemberRole(ctx context.Context, projectID, actorID, userID primitive.ObjectID, role ProjectRole) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := p.collection.UpdateOne(sessCtx,
+			bson.M{"_id": projectID, "members.user_id": userID},
+			bson.M{"$set": bson.M{"members.$.role": role, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update project member: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			return nil, ErrNotMember
+		}
+
+		return nil, p.logActivity(sessCtx, projectID, actorID, ActivityMemberUpdated, &userID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+// RemoveMember - open tasks assigned to the member become unassigned
+func (p *ProjectStorage) RemoveMember(ctx context.Context, projectID, actorID, userID primitive.ObjectID) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := p.collection.UpdateByID(sessCtx, projectID, bson.M{
+			"$pull": bson.M{"members": bson.M{"user_id": userID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove project member: %w", err)
+		}
+		if result.ModifiedCount == 0 {
+			return nil, ErrNotMember
+		}
+
+		_, err = p.taskCollection.UpdateMany(sessCtx,
+			bson.M{"project_id": projectID, "assignee_id": userID, "completed": false},
+			bson.M{"$unset": bson.M{"assignee_id": ""}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unassign tasks: %w", err)
+		}
+
+		return nil, p.logActivity(sessCtx, projectID, actorID, ActivityMemberRemoved, &userID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+// touch - bump the project so the most active projects are listed first
+func (p *ProjectStorage) touch(ctx context.Context, projectID primitive.ObjectID) error {
+	_, err := p.collection.UpdateByID(ctx, projectID, bson.M{"$set": bson.M{"updated_at": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to update project: %w", err)
+	}
+	return nil
+}
+
+func (p *ProjectStorage) CreateUpdate(ctx context.Context, update *ProjectUpdate) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		update.ID = primitive.NewObjectID()
+		update.CreatedAt = time.Now()
+
+		if _, err := p.updateCollection.InsertOne(sessCtx, update); err != nil {
+			return nil, fmt.Errorf("failed to create project update: %w", err)
+		}
+
+		if err := p.touch(sessCtx, update.ProjectID); err != nil {
+			return nil, err
+		}
+
+		return nil, p.logActivity(sessCtx, update.ProjectID, update.AuthorID, ActivityUpdatePosted, &update.ID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+// pageQuery - newest first page of a project sub-collection, cursor is the id of the last item on the previous page
+func pageQuery(projectID primitive.ObjectID, cq CursorQuery) (bson.M, *options.FindOptions, error) {
+	filter := bson.M{"project_id": projectID}
+	if cq.Cursor != "" {
+		cursorID, err := primitive.ObjectIDFromHex(cq.Cursor)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid cursor ID: %w", err)
+		}
+		filter["_id"] = bson.M{"$lt": cursorID}
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"_id": -1}).
+		SetLimit(int64(cq.Limit))
+
+	return filter, opts, nil
+}
+
+func (p *ProjectStorage) GetUpdates(ctx context.Context, projectID primitive.ObjectID, cq CursorQuery) ([]ProjectUpdate, error) {
+	filter, opts, err := pageQuery(projectID, cq)
+	if err != nil {
+		return nil, err
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	cursor, err := p.updateCollection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project updates: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	updates := make([]ProjectUpdate, 0)
+	if err := cursor.All(ctxTimeout, &updates); err != nil {
+		return nil, fmt.Errorf("failed to decode project updates: %w", err)
+	}
+
+	return updates, nil
+}
+
+func (p *ProjectStorage) GetActivity(ctx context.Context, projectID primitive.ObjectID, cq CursorQuery) ([]ProjectActivity, error) {
+	filter, opts, err := pageQuery(projectID, cq)
+	if err != nil {
+		return nil, err
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	cursor, err := p.activityCollection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project activity: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	activity := make([]ProjectActivity, 0)
+	if err := cursor.All(ctxTimeout, &activity); err != nil {
+		return nil, fmt.Errorf("failed to decode project activity: %w", err)
+	}
+
+	return activity, nil
+}
+
+func (p *ProjectStorage) CreateTask(ctx context.Context, task *ProjectTask) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		now := time.Now()
+		task.ID = primitive.NewObjectID()
+		task.Completed = false
+		task.CreatedAt = now
+		task.UpdatedAt = now
+
+		if _, err := p.taskCollection.InsertOne(sessCtx, task); err != nil {
+			return nil, fmt.Errorf("failed to create project task: %w", err)
+		}
+
+		if err := p.touch(sessCtx, task.ProjectID); err != nil {
+			return nil, err
+		}
+
+		return nil, p.logActivity(sessCtx, task.ProjectID, task.CreatedBy, ActivityTaskCreated, &task.ID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+func (p *ProjectStorage) GetTask(ctx context.Context, projectID primitive.ObjectID, taskID string) (*ProjectTask, error) {
+	objID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert taskID to ObjectID: %w", err)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var task ProjectTask
+	err = p.taskCollection.FindOne(ctxTimeout, bson.M{"_id": objID, "project_id": projectID}).Decode(&task)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrProjectTaskNotFound
+		}
+		return nil, fmt.Errorf("project task query failed: %w", err)
+	}
+
+	return &task, nil
+}
+
+// GetTasks - open tasks first, ordered by due date, tasks without due date last
+func (p *ProjectStorage) GetTasks(ctx context.Context, projectID primitive.ObjectID) ([]ProjectTask, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"project_id": projectID}}},
+		{{Key: "$addFields", Value: bson.M{
+			"has_due_date": bson.M{"$cond": bson.A{bson.M{"$ifNull": bson.A{"$due_date", false}}, 0, 1}},
+		}}},
+		{{Key: "$sort", Value: bson.D{
+			{Key: "completed", Value: 1},
+			{Key: "has_due_date", Value: 1},
+			{Key: "due_date", Value: 1},
+			{Key: "_id", Value: 1},
+		}}},
+	}
+
+	cursor, err := p.taskCollection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project tasks: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	tasks := make([]ProjectTask, 0)
+	if err := cursor.All(ctxTimeout, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to decode project tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// UpdateTask - the activity logged depends on whether the task was just completed
+func (p *ProjectStorage) UpdateTask(ctx context.Context, task *ProjectTask, actorID primitive.ObjectID, justCompleted bool) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		task.UpdatedAt = time.Now()
+
+		set := bson.M{
+			"title":        task.Title,
+			"description":  task.Description,
+			"assignee_id":  task.AssigneeID,
+			"due_date":     task.DueDate,
+			"completed":    task.Completed,
+			"completed_at": task.CompletedAt,
+			"updated_at":   task.UpdatedAt,
+		}
+
+		if _, err := p.taskCollection.UpdateByID(sessCtx, task.ID, bson.M{"$set": set}); err != nil {
+			return nil, fmt.Errorf("failed to update project task: %w", err)
+		}
+
+		if err := p.touch(sessCtx, task.ProjectID); err != nil {
+			return nil, err
+		}
+
+		action := ActivityTaskUpdated
+		if justCompleted {
+			action = ActivityTaskCompleted
+		}
+		return nil, p.logActivity(sessCtx, task.ProjectID, actorID, action, &task.ID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+func (p *ProjectStorage) DeleteTask(ctx context.Context, task *ProjectTask, actorID primitive.ObjectID) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := p.taskCollection.DeleteOne(sessCtx, bson.M{"_id": task.ID}); err != nil {
+			return nil, fmt.Errorf("failed to delete project task: %w", err)
+		}
+
+		return nil, p.logActivity(sessCtx, task.ProjectID, actorID, ActivityTaskDeleted, &task.ID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+func (p *ProjectStorage) AddDocument(ctx context.Context, document *ProjectDocument) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		document.ID = primitive.NewObjectID()
+		document.CreatedAt = time.Now()
+
+		if _, err := p.documentCollection.InsertOne(sessCtx, document); err != nil {
+			return nil, fmt.Errorf("failed to add project document: %w", err)
+		}
+
+		if err := p.touch(sessCtx, document.ProjectID); err != nil {
+			return nil, err
+		}
+
+		return nil, p.logActivity(sessCtx, document.ProjectID, document.UploadedBy, ActivityDocumentAdded, &document.ID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
+
+func (p *ProjectStorage) GetDocument(ctx context.Context, projectID primitive.ObjectID, documentID string) (*ProjectDocument, error) {
+	objID, err := primitive.ObjectIDFromHex(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert documentID to ObjectID: %w", err)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var document ProjectDocument
+	err = p.documentCollection.FindOne(ctxTimeout, bson.M{"_id": objID, "project_id": projectID}).Decode(&document)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrProjectDocumentNotFound
+		}
+		return nil, fmt.Errorf("project document query failed: %w", err)
+	}
+
+	return &document, nil
+}
+
+func (p *ProjectStorage) GetDocuments(ctx context.Context, projectID primitive.ObjectID) ([]ProjectDocument, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	cursor, err := p.documentCollection.Find(ctxTimeout,
+		bson.M{"project_id": projectID},
+		options.Find().SetSort(bson.M{"created_at": -1}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project documents: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	documents := make([]ProjectDocument, 0)
+	if err := cursor.All(ctxTimeout, &documents); err != nil {
+		return nil, fmt.Errorf("failed to decode project documents: %w", err)
+	}
+
+	return documents, nil
+}
+
+func (p *ProjectStorage) RemoveDocument(ctx context.Context, document *ProjectDocument, actorID primitive.ObjectID) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := p.documentCollection.DeleteOne(sessCtx, bson.M{"_id": document.ID}); err != nil {
+			return nil, fmt.Errorf("failed to remove project document: %w", err)
+		}
+
+		return nil, p.logActivity(sessCtx, document.ProjectID, actorID, ActivityDocumentRemoved, &document.ID)
+	}
+
+	return withTransaction(ctx, client, txnFunc)
+}
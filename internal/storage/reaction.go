@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrReactionNotFound = errors.New("reaction not found")
+)
+
+type ReactionType string
+
+// ReactionLike on a post is the post's like, it is kept with the likes and counted in like_count
+const (
+	ReactionLike    ReactionType = "like"
+	ReactionLove    ReactionType = "love"
+	ReactionHelpful ReactionType = "helpful"
+	ReactionWow     ReactionType = "wow"
+)
+
+// ReactionTarget - kind of content a reaction is on
+type ReactionTarget string
+
+const (
+	ReactionOnPost    ReactionTarget = "post"
+	ReactionOnComment ReactionTarget = "comment"
+)
+
+// ReactionCounts - number of reactions per type, kept on the post or comment
+type ReactionCounts map[ReactionType]int64
+
+// Reaction - a user has at most one reaction per post or comment, reacting again replaces the type
+type Reaction struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TargetType ReactionTarget     `json:"target_type" bson:"target_type"`
+	TargetID   primitive.ObjectID `json:"target_id" bson:"target_id"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Type       ReactionType       `json:"type" bson:"type"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type ReactionStorage struct {
+	collection        *mongo.Collection
+	postCollection    *mongo.Collection
+	commentCollection *mongo.Collection
+	likeCollection    *mongo.Collection
+}
+
+func (rs *ReactionStorage) targetCollection(target ReactionTarget) *mongo.Collection {
+	if target == ReactionOnComment {
+		return rs.commentCollection
+	}
+	return rs.postCollection
+}
+
+// getCounts - current reaction counts of the target
+func (rs *ReactionStorage) getCounts(ctx context.Context, target ReactionTarget, targetID primitive.ObjectID) (ReactionCounts, error) {
+	var doc struct {
+		ReactionCounts ReactionCounts `bson:"reaction_counts"`
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"reaction_counts": 1})
+	if err := rs.targetCollection(target).FindOne(ctx, bson.M{"_id": targetID}, opts).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to get reaction counts: %w", err)
+	}
+
+	if doc.ReactionCounts == nil {
+		doc.ReactionCounts = ReactionCounts{}
+	}
+	return doc.ReactionCounts, nil
+}
+
+// React - set the user's reaction on the target, returns the updated counts
+func (rs *ReactionStorage) React(ctx context.Context, target ReactionTarget, targetID, userID primitive.ObjectID, reactionType ReactionType) (ReactionCounts, error) {
+	client := rs.collection.Database().Client()
+
+	var counts ReactionCounts
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if target == ReactionOnPost {
+			if err := rs.replacePostLike(sessCtx, targetID, userID, reactionType); err != nil {
+				return nil, err
+			}
+			if reactionType == ReactionLike {
+				var err error
+				counts, err = rs.getCounts(sessCtx, target, targetID)
+				return nil, err
+			}
+		}
+
+		filter := bson.M{"target_type": target, "target_id": targetID, "user_id": userID}
+		update := bson.M{
+			"$set":         bson.M{"type": reactionType},
+			"$setOnInsert": bson.M{"created_at": time.Now()},
+		}
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+		inc := bson.M{"reaction_counts." + string(reactionType): 1}
+
+		var previous Reaction
+		err := rs.collection.FindOneAndUpdate(sessCtx, filter, update, opts).Decode(&previous)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			// new reaction
+		case err != nil:
+			return nil, fmt.Errorf("failed to react: %w", err)
+		case previous.Type == reactionType:
+			inc = nil
+		default:
+			inc["reaction_counts."+string(previous.Type)] = -1
+		}
+
+		if inc != nil {
+			if _, err := rs.targetCollection(target).UpdateByID(sessCtx, targetID, bson.M{"$inc": inc}); err != nil {
+				return nil, fmt.Errorf("failed to update reaction counts: %w", err)
+			}
+		}
+
+		counts, err = rs.getCounts(sessCtx, target, targetID)
+		return nil, err
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := withTransaction(ctxTimeout, client, txnFunc)
+	return counts, err
+}
+
+// Unreact - remove the user's reaction on the target, returns the updated counts
+func (rs *ReactionStorage) Unreact(ctx context.Context, target ReactionTarget, targetID, userID primitive.ObjectID) (ReactionCounts, error) {
+	client := rs.collection.Database().Client()
+
+	var counts ReactionCounts
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		filter := bson.M{"target_type": target, "target_id": targetID, "user_id": userID}
+
+		var previous Reaction
+		err := rs.collection.FindOneAndDelete(sessCtx, filter).Decode(&previous)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			// the like reaction of a post is the post's like
+			removed := false
+			if target == ReactionOnPost {
+				if removed, err = removePostLike(sessCtx, rs.postCollection, rs.likeCollection, targetID, userID); err != nil {
+					return nil, err
+				}
+			}
+			if !removed {
+				return nil, ErrReactionNotFound
+			}
+		case err != nil:
+			return nil, fmt.Errorf("failed to remove reaction: %w", err)
+		default:
+			inc := bson.M{"reaction_counts." + string(previous.Type): -1}
+			if _, err := rs.targetCollection(target).UpdateByID(sessCtx, targetID, bson.M{"$inc": inc}); err != nil {
+				return nil, fmt.Errorf("failed to update reaction counts: %w", err)
+			}
+		}
+
+		counts, err = rs.getCounts(sessCtx, target, targetID)
+		return nil, err
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := withTransaction(ctxTimeout, client, txnFunc)
+	return counts, err
+}
+
+// GetUserReactions - the user's reaction type per target for a page of posts or comments in one query
+func (rs *ReactionStorage) GetUserReactions(ctx context.Context, target ReactionTarget, userID primitive.ObjectID, targetIDs []primitive.ObjectID) (map[primitive.ObjectID]ReactionType, error) {
+	reactions := make(map[primitive.ObjectID]ReactionType, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return reactions, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"target_type": target,
+		"target_id":   bson.M{"$in": targetIDs},
+		"user_id":     userID,
+	}
+
+	cursor, err := rs.collection.Find(ctxTimeout, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find reactions: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	var found []Reaction
+	if err := cursor.All(ctxTimeout, &found); err != nil {
+		return nil, fmt.Errorf("failed to decode reactions: %w", err)
+	}
+
+	for _, reaction := range found {
+		reactions[reaction.TargetID] = reaction.Type
+	}
+
+	if target == ReactionOnPost {
+		liked, err := rs.likeCollection.Distinct(ctxTimeout, "post_id", bson.M{"user_id": userID, "post_id": bson.M{"$in": targetIDs}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find likes: %w", err)
+		}
+
+		for _, id := range liked {
+			if postID, ok := id.(primitive.ObjectID); ok {
+				if _, reacted := reactions[postID]; !reacted {
+					reactions[postID] = ReactionLike
+				}
+			}
+		}
+	}
+
+	return reactions, nil
+}
+
+// replacePostLike - a post reaction replaces the user's like and the like replaces any other reaction
+func (rs *ReactionStorage) replacePostLike(ctx context.Context, postID, userID primitive.ObjectID, reactionType ReactionType) error {
+	if reactionType != ReactionLike {
+		_, err := removePostLike(ctx, rs.postCollection, rs.likeCollection, postID, userID)
+		return err
+	}
+
+	if _, err := removePostReaction(ctx, rs.postCollection, rs.collection, postID, userID); err != nil {
+		return err
+	}
+	return addPostLike(ctx, rs.postCollection, rs.likeCollection, postID, userID)
+}
+
+// removePostReaction - remove the user's reaction on the post, the removed type is empty if there was none
+func removePostReaction(ctx context.Context, posts, reactions *mongo.Collection, postID, userID primitive.ObjectID) (ReactionType, error) {
+	var previous Reaction
+	err := reactions.FindOneAndDelete(ctx, bson.M{"target_type": ReactionOnPost, "target_id": postID, "user_id": userID}).Decode(&previous)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to remove reaction: %w", err)
+	}
+
+	inc := bson.M{"reaction_counts." + string(previous.Type): -1}
+	if _, err := posts.UpdateByID(ctx, postID, bson.M{"$inc": inc}); err != nil {
+		return "", fmt.Errorf("failed to update reaction counts: %w", err)
+	}
+
+	return previous.Type, nil
+}
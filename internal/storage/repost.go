@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrAlreadyReposted = errors.New("post already reposted")
+	ErrNotShareable    = errors.New("only public posts can be shared")
+)
+
+const (
+	PostRepost PostType = "repost" // share of another post without content
+	PostQuote  PostType = "quote"  // share of another post with commentary
+)
+
+// SharedPost - original of a repost or quote, Post is nil once the original is no longer available
+type SharedPost struct {
+	Post        *Post  `json:"post,omitempty"`
+	Username    string `json:"username,omitempty"`
+	LikedByUser bool   `json:"liked_by_user"`
+	Unavailable bool   `json:"unavailable,omitempty"`
+}
+
+// Share - create a repost or quote of post.RepostOf and count it on the original
+// a user can only repost a post once, quotes are not limited
+func (p *PostStorage) Share(ctx context.Context, post *Post) error {
+	client := p.collection.Database().Client()
+
+	now := time.Now()
+	post.ID = primitive.NewObjectID()
+	post.Status = PostPublished
+	post.Visibility = VisibilityPublic
+	post.PublishedAt = &now
+	post.CreatedAt = now
+	post.UpdatedAt = now
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := p.collection.UpdateOne(sessCtx,
+			bson.M{"_id": post.RepostOf, "status": PostPublished, "visibility": VisibilityPublic, "deleted_at": notDeleted()},
+			bson.M{"$inc": bson.M{"share_count": 1}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update share count: %w", err)
+		}
+
+		if result.MatchedCount == 0 {
+			return nil, ErrNotShareable
+		}
+
+		if _, err := p.collection.InsertOne(sessCtx, post); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, ErrAlreadyReposted
+			}
+			return nil, fmt.Errorf("failed to share post: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// Unrepost - reposts have no content of their own, so they are removed instead of going to the trash,
+// returns the ID of the removed repost
+func (p *PostStorage) Unrepost(ctx context.Context, userID, originalID primitive.ObjectID) (primitive.ObjectID, error) {
+	client := p.collection.Database().Client()
+
+	var repost Post
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		err := p.collection.FindOneAndDelete(sessCtx, bson.M{"user_id": userID, "repost_of": originalID, "type": PostRepost}).Decode(&repost)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrPostNotFound
+			}
+			return nil, fmt.Errorf("failed to remove repost: %w", err)
+		}
+
+		if err := p.incrementShareCount(sessCtx, &originalID, -1); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if err := withTransaction(ctxTimeout, client, txnFunc); err != nil {
+		return primitive.NilObjectID, err
+	}
+	return repost.ID, nil
+}
+
+// incrementShareCount - no-op for posts that don't share another post
+func (p *PostStorage) incrementShareCount(ctx context.Context, originalID *primitive.ObjectID, delta int) error {
+	if originalID == nil {
+		return nil
+	}
+
+	if _, err := p.collection.UpdateByID(ctx, *originalID, bson.M{"$inc": bson.M{"share_count": delta}}); err != nil {
+		return fmt.Errorf("failed to update share count: %w", err)
+	}
+	return nil
+}
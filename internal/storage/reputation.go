@@ -0,0 +1,511 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/security"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReputationEvent - what a ledger entry awards points for
+type ReputationEvent string
+
+const (
+	RepLikeReceived     ReputationEvent = "like_received"
+	RepCommentReceived  ReputationEvent = "comment_received"
+	RepReviewReceived   ReputationEvent = "review_received"
+	RepFollowerGained   ReputationEvent = "follower_gained"
+	RepProfileCompleted ReputationEvent = "profile_completed"
+	RepAnswerAccepted   ReputationEvent = "answer_accepted" // professionals only
+)
+
+// ReputationPoints - points per event, entries keep the points they were recorded with
+var ReputationPoints = map[ReputationEvent]int64{
+	RepLikeReceived:     1,
+	RepCommentReceived:  2,
+	RepReviewReceived:   5,
+	RepFollowerGained:   3,
+	RepProfileCompleted: 10,
+	RepAnswerAccepted:   15,
+}
+
+// ReputationEntry - one per source event, unique on key so recording the same event again is a no-op
+type ReputationEntry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Event     ReputationEvent    `json:"event" bson:"event"`
+	Key       string             `json:"-" bson:"key"`
+	Points    int64              `json:"points" bson:"points"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	SyncedAt  time.Time          `json:"-" bson:"synced_at"` // last time the source event was seen
+}
+
+// Badge - awarded by the recompute job, AwardedAt is kept while the user still qualifies
+type Badge struct {
+	ID        string    `json:"id" bson:"id"`
+	Label     string    `json:"label" bson:"label"`
+	AwardedAt time.Time `json:"awarded_at" bson:"awarded_at"`
+}
+
+// countBadge - awarded once the user has enough entries of the event
+type countBadge struct {
+	id        string
+	label     string
+	event     ReputationEvent
+	threshold int64
+}
+
+var countBadges = []countBadge{
+	{id: "likes_1000", label: "1,000 likes received", event: RepLikeReceived, threshold: 1000},
+	{id: "followers_100", label: "100 followers", event: RepFollowerGained, threshold: 100},
+	{id: "accepted_answers_10", label: "10 accepted answers", event: RepAnswerAccepted, threshold: 10},
+}
+
+// LikeKey, CommentKey, ReviewKey, FollowKey, ProfileKey, AnswerKey - ledger keys of the source events,
+// the recompute job builds the same keys so live and recomputed entries never double count
+func LikeKey(postID, likerID primitive.ObjectID) string {
+	return fmt.Sprintf("like:%s:%s", postID.Hex(), likerID.Hex())
+}
+
+func CommentKey(commentID primitive.ObjectID) string {
+	return fmt.Sprintf("comment:%s", commentID.Hex())
+}
+
+func ReviewKey(reviewID primitive.ObjectID) string {
+	return fmt.Sprintf("review:%s", reviewID.Hex())
+}
+
+func FollowKey(followerID, followeeID primitive.ObjectID) string {
+	return fmt.Sprintf("follow:%s:%s", followerID.Hex(), followeeID.Hex())
+}
+
+func ProfileKey(userID primitive.ObjectID) string {
+	return fmt.Sprintf("profile:%s", userID.Hex())
+}
+
+func AnswerKey(commentID primitive.ObjectID) string {
+	return fmt.Sprintf("answer:%s", commentID.Hex())
+}
+
+// ProfileCompleted - bio, location and a way to be contacted, the recompute job matches the same fields
+func ProfileCompleted(profile Profile) bool {
+	return profile.Bio != "" && profile.Location != "" && (profile.Contact.Email != "" || profile.Contact.Phone != "")
+}
+
+type ReputationStorage struct {
+	collection        *mongo.Collection
+	userCollection    *mongo.Collection
+	postCollection    *mongo.Collection
+	likeCollection    *mongo.Collection
+	commentCollection *mongo.Collection
+	reviewCollection  *mongo.Collection
+	followCollection  *mongo.Collection
+}
+
+// Record - add the entry and its points to the user, no-op if the event was already recorded
+func (r *ReputationStorage) Record(ctx context.Context, userID primitive.ObjectID, event ReputationEvent, key string) error {
+	client := r.collection.Database().Client()
+	points := ReputationPoints[event]
+	now := time.Now()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if event == RepAnswerAccepted {
+			count, err := r.userCollection.CountDocuments(sessCtx, bson.M{"_id": userID, "role": bson.M{"$in": professionalRoles}})
+			if err != nil {
+				return nil, fmt.Errorf("failed to check user role: %w", err)
+			}
+			if count == 0 {
+				return nil, nil
+			}
+		}
+
+		entry := ReputationEntry{ID: primitive.NewObjectID(), UserID: userID, Event: event, Key: key, Points: points, CreatedAt: now, SyncedAt: now}
+		result, err := r.collection.UpdateOne(sessCtx,
+			bson.M{"key": key},
+			bson.M{"$setOnInsert": entry},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record reputation: %w", err)
+		}
+
+		if result.UpsertedCount == 0 {
+			return nil, nil
+		}
+
+		if _, err := r.userCollection.UpdateByID(sessCtx, userID, bson.M{"$inc": bson.M{"reputation": points}}); err != nil {
+			return nil, fmt.Errorf("failed to update reputation: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// Revoke - remove the entry and take back its points, no-op if it doesn't exist
+func (r *ReputationStorage) Revoke(ctx context.Context, key string) error {
+	client := r.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var entry ReputationEntry
+		if err := r.collection.FindOneAndDelete(sessCtx, bson.M{"key": key}).Decode(&entry); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to revoke reputation: %w", err)
+		}
+
+		if _, err := r.userCollection.UpdateByID(sessCtx, entry.UserID, bson.M{"$inc": bson.M{"reputation": -entry.Points}}); err != nil {
+			return nil, fmt.Errorf("failed to update reputation: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// GetEntries - a page of the user's ledger, most recent first
+func (r *ReputationStorage) GetEntries(ctx context.Context, userID primitive.ObjectID, cq CursorQuery) ([]ReputationEntry, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		cursorID, err := primitive.ObjectIDFromHex(cq.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor ID: %w", err)
+		}
+		filter["_id"] = bson.M{"$lt": cursorID}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(cq.Limit))
+
+	cursor, err := r.collection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find reputation entries: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	entries := make([]ReputationEntry, 0)
+	if err := cursor.All(ctxTimeout, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode reputation entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Recompute - rebuild the ledger from its source events, then the reputation and badges of every user.
+// Entries whose source is gone (unliked, unfollowed, deleted) are dropped with their points
+func (r *ReputationStorage) Recompute(ctx context.Context) error {
+	syncedAt := time.Now()
+
+	sources := []struct {
+		event      ReputationEvent
+		collection *mongo.Collection
+		pipeline   mongo.Pipeline
+	}{
+		{RepLikeReceived, r.likeCollection, r.receivedOnPostPipeline(
+			bson.M{},
+			bson.M{"$concat": bson.A{"like:", bson.M{"$toString": "$post_id"}, ":", bson.M{"$toString": "$user_id"}}},
+		)},
+		{RepCommentReceived, r.commentCollection, r.receivedOnPostPipeline(
+			bson.M{"deleted_at": notDeleted()},
+			bson.M{"$concat": bson.A{"comment:", bson.M{"$toString": "$_id"}}},
+		)},
+		{RepReviewReceived, r.reviewCollection, mongo.Pipeline{
+			{{Key: "$project", Value: bson.M{
+				"user_id":    "$rated_user_id",
+				"key":        bson.M{"$concat": bson.A{"review:", bson.M{"$toString": "$_id"}}},
+				"created_at": 1,
+			}}},
+		}},
+		{RepFollowerGained, r.followCollection, mongo.Pipeline{
+			{{Key: "$project", Value: bson.M{
+				"user_id":    "$followee_id",
+				"key":        bson.M{"$concat": bson.A{"follow:", bson.M{"$toString": "$follower_id"}, ":", bson.M{"$toString": "$followee_id"}}},
+				"created_at": 1,
+			}}},
+		}},
+		{RepProfileCompleted, r.userCollection, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{
+				"profile.bio":      bson.M{"$nin": bson.A{"", nil}},
+				"profile.location": bson.M{"$nin": bson.A{"", nil}},
+				"$or": []bson.M{
+					{"profile.contact.email": bson.M{"$nin": bson.A{"", nil}}},
+					{"profile.contact.phone": bson.M{"$nin": bson.A{"", nil}}},
+				},
+			}}},
+			{{Key: "$project", Value: bson.M{
+				"user_id":    "$_id",
+				"key":        bson.M{"$concat": bson.A{"profile:", bson.M{"$toString": "$_id"}}},
+				"created_at": 1,
+			}}},
+		}},
+		{RepAnswerAccepted, r.commentCollection, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"accepted": true, "deleted_at": notDeleted()}}},
+			{{Key: "$lookup", Value: bson.M{"from": r.userCollection.Name(), "localField": "user_id", "foreignField": "_id", "as": "author"}}},
+			{{Key: "$match", Value: bson.M{"author.role": bson.M{"$in": professionalRoles}}}},
+			{{Key: "$project", Value: bson.M{
+				"user_id":    1,
+				"key":        bson.M{"$concat": bson.A{"answer:", bson.M{"$toString": "$_id"}}},
+				"created_at": 1,
+			}}},
+		}},
+	}
+
+	for _, source := range sources {
+		if err := r.mergeEntries(ctx, source.collection, source.pipeline, source.event, syncedAt); err != nil {
+			return fmt.Errorf("failed to rebuild %s entries: %w", source.event, err)
+		}
+	}
+
+	if _, err := r.collection.DeleteMany(ctx, bson.M{"synced_at": bson.M{"$lt": syncedAt}}); err != nil {
+		return fmt.Errorf("failed to drop stale reputation entries: %w", err)
+	}
+
+	if err := r.sumReputation(ctx, syncedAt); err != nil {
+		return err
+	}
+
+	return r.awardBadges(ctx, syncedAt)
+}
+
+// receivedOnPostPipeline - entries for the post author of likes and comments, interactions with your own posts
+// and with deleted posts earn nothing
+func (r *ReputationStorage) receivedOnPostPipeline(match bson.M, key bson.M) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$lookup", Value: bson.M{"from": r.postCollection.Name(), "localField": "post_id", "foreignField": "_id", "as": "post"}}},
+		{{Key: "$unwind", Value: "$post"}},
+		{{Key: "$match", Value: bson.M{
+			"post.deleted_at": notDeleted(),
+			"$expr":           bson.M{"$ne": bson.A{"$post.user_id", "$user_id"}},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"user_id":    "$post.user_id",
+			"key":        key,
+			"created_at": 1,
+		}}},
+	}
+}
+
+// mergeEntries - upsert the entries produced by the pipeline on key, existing entries keep their id and points
+func (r *ReputationStorage) mergeEntries(ctx context.Context, source *mongo.Collection, pipeline mongo.Pipeline, event ReputationEvent, syncedAt time.Time) error {
+	pipeline = append(pipeline,
+		bson.D{{Key: "$project", Value: bson.M{
+			"_id":        0,
+			"user_id":    1,
+			"key":        1,
+			"event":      bson.M{"$literal": event},
+			"points":     bson.M{"$literal": ReputationPoints[event]},
+			"created_at": bson.M{"$ifNull": bson.A{"$created_at", syncedAt}},
+			"synced_at":  syncedAt,
+		}}},
+		bson.D{{Key: "$merge", Value: bson.M{
+			"into":           r.collection.Name(),
+			"on":             "key",
+			"whenMatched":    mongo.Pipeline{{{Key: "$set", Value: bson.M{"synced_at": "$$new.synced_at"}}}},
+			"whenNotMatched": "insert",
+		}}},
+	)
+
+	cursor, err := source.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// sumReputation - reputation is the sum of the user's entries, users without entries go back to 0
+func (r *ReputationStorage) sumReputation(ctx context.Context, syncedAt time.Time) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "reputation": bson.M{"$sum": "$points"}}}},
+		{{Key: "$set", Value: bson.M{"reputation_synced_at": syncedAt}}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           r.userCollection.Name(),
+			"on":             "_id",
+			"whenMatched":    mongo.Pipeline{{{Key: "$set", Value: bson.M{"reputation": "$$new.reputation", "reputation_synced_at": "$$new.reputation_synced_at"}}}},
+			"whenNotMatched": "discard",
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to sum reputation: %w", err)
+	}
+	cursor.Close(ctx)
+
+	_, err = r.userCollection.UpdateMany(ctx,
+		bson.M{"$or": []bson.M{
+			{"reputation_synced_at": bson.M{"$lt": syncedAt}},
+			{"reputation_synced_at": bson.M{"$exists": false}},
+		}},
+		bson.M{"$set": bson.M{"reputation": 0, "reputation_synced_at": syncedAt}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reset reputation: %w", err)
+	}
+
+	return nil
+}
+
+// awardBadges - evaluate every badge rule, users keep the award time of badges they already had
+func (r *ReputationStorage) awardBadges(ctx context.Context, awardedAt time.Time) error {
+	badges := make(map[primitive.ObjectID][]Badge)
+
+	events := make([]ReputationEvent, 0, len(countBadges))
+	for _, rule := range countBadges {
+		events = append(events, rule.event)
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"event": bson.M{"$in": events}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "event": "$event"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count reputation events: %w", err)
+	}
+
+	var counts []struct {
+		ID struct {
+			UserID primitive.ObjectID `bson:"user_id"`
+			Event  ReputationEvent    `bson:"event"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return fmt.Errorf("failed to decode reputation event counts: %w", err)
+	}
+
+	for _, count := range counts {
+		for _, rule := range countBadges {
+			if rule.event == count.ID.Event && count.Count >= rule.threshold {
+				badges[count.ID.UserID] = append(badges[count.ID.UserID], Badge{ID: rule.id, Label: rule.label})
+			}
+		}
+	}
+
+	// the professional with the highest reputation per role and location
+	cursor, err = r.userCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"role":             bson.M{"$in": professionalRoles},
+			"profile.location": bson.M{"$nin": bson.A{"", nil}},
+			"reputation":       bson.M{"$gt": 0},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "reputation", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     bson.M{"role": "$role", "location": "$profile.location"},
+			"user_id": bson.M{"$first": "$_id"},
+		}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rank professionals: %w", err)
+	}
+
+	var tops []struct {
+		ID struct {
+			Role     security.Role `bson:"role"`
+			Location string        `bson:"location"`
+		} `bson:"_id"`
+		UserID primitive.ObjectID `bson:"user_id"`
+	}
+	if err := cursor.All(ctx, &tops); err != nil {
+		return fmt.Errorf("failed to decode top professionals: %w", err)
+	}
+
+	for _, top := range tops {
+		role := string(top.ID.Role)
+		badges[top.UserID] = append(badges[top.UserID], Badge{
+			ID:    "top_" + role,
+			Label: fmt.Sprintf("Top %s in %s", strings.ToUpper(role[:1])+role[1:], top.ID.Location),
+		})
+	}
+
+	return r.saveBadges(ctx, badges, awardedAt)
+}
+
+// saveBadges - replace the badges of every user, users not in the map lose theirs
+func (r *ReputationStorage) saveBadges(ctx context.Context, badges map[primitive.ObjectID][]Badge, awardedAt time.Time) error {
+	userIDs := make([]primitive.ObjectID, 0, len(badges))
+	for userID := range badges {
+		userIDs = append(userIDs, userID)
+	}
+
+	_, err := r.userCollection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$nin": userIDs}, "badges": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"badges": ""}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clear badges: %w", err)
+	}
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	cursor, err := r.userCollection.Find(ctx,
+		bson.M{"_id": bson.M{"$in": userIDs}, "badges": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"badges": 1}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get badges: %w", err)
+	}
+
+	previous := make(map[primitive.ObjectID]map[string]time.Time)
+	for cursor.Next(ctx) {
+		var user struct {
+			ID     primitive.ObjectID `bson:"_id"`
+			Badges []Badge            `bson:"badges"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			cursor.Close(ctx)
+			return fmt.Errorf("failed to decode badges: %w", err)
+		}
+
+		previous[user.ID] = make(map[string]time.Time, len(user.Badges))
+		for _, badge := range user.Badges {
+			previous[user.ID][badge.ID+badge.Label] = badge.AwardedAt
+		}
+	}
+	cursor.Close(ctx)
+
+	models := make([]mongo.WriteModel, 0, len(badges))
+	for userID, userBadges := range badges {
+		for i, badge := range userBadges {
+			userBadges[i].AwardedAt = awardedAt
+			if at, ok := previous[userID][badge.ID+badge.Label]; ok {
+				userBadges[i].AwardedAt = at
+			}
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": userID}).
+			SetUpdate(bson.M{"$set": bson.M{"badges": userBadges}}))
+	}
+
+	if _, err := r.userCollection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to save badges: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrVersionConflict  = errors.New("post was modified by another request")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+// PostRevision - immutable snapshot of a post version that has been replaced by an edit
+type PostRevision struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	PostID          primitive.ObjectID `json:"post_id" bson:"post_id"`
+	Version         int64              `json:"version" bson:"version"`
+	Title           string             `json:"title" bson:"title"`
+	Content         string             `json:"content" bson:"content"`
+	Tags            []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	Mentions        []string           `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	MentionEntities []Mention          `json:"mention_entities,omitempty" bson:"mention_entities,omitempty"`
+	Images          []string           `json:"images,omitempty" bson:"images,omitempty"`
+	Hotspots        []Hotspot          `json:"hotspots,omitempty" bson:"hotspots,omitempty"`
+	Showcase        *Showcase          `json:"showcase,omitempty" bson:"showcase,omitempty"`
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at"`   // when this version was written
+	ReplacedAt      time.Time          `json:"replaced_at" bson:"replaced_at"` // when the next version replaced it
+}
+
+func newRevision(post *Post, replacedAt time.Time) *PostRevision {
+	return &PostRevision{
+		ID:              primitive.NewObjectID(),
+		PostID:          post.ID,
+		Version:         post.Version,
+		Title:           post.Title,
+		Content:         post.Content,
+		Tags:            post.Tags,
+		Mentions:        post.Mentions,
+		MentionEntities: post.MentionEntities,
+		Images:          post.Images,
+		Hotspots:        post.Hotspots,
+		Showcase:        post.Showcase,
+		CreatedAt:       post.UpdatedAt,
+		ReplacedAt:      replacedAt,
+	}
+}
+
+// GetRevisions - previous versions of a post, newest first
+func (p *PostStorage) GetRevisions(ctx context.Context, postID primitive.ObjectID, cq CursorQuery) ([]PostRevision, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{"post_id": postID}
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		cursorID, err := primitive.ObjectIDFromHex(cq.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor ID: %w", err)
+		}
+		filter["_id"] = bson.M{"$lt": cursorID}
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"_id": -1}).
+		SetLimit(int64(cq.Limit))
+
+	cursor, err := p.revisionCollection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find post revisions: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	revisions := make([]PostRevision, 0)
+	if err := cursor.All(ctxTimeout, &revisions); err != nil {
+		return nil, fmt.Errorf("failed to decode post revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+func (p *PostStorage) GetRevision(ctx context.Context, postID primitive.ObjectID, version int64) (*PostRevision, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var revision PostRevision
+	err := p.revisionCollection.FindOne(ctxTimeout, bson.M{"post_id": postID, "version": version}).Decode(&revision)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, fmt.Errorf("revision query failed: %w", err)
+	}
+
+	return &revision, nil
+}
@@ -0,0 +1,426 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/security"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SearchSort - order of search results
+type SearchSort string
+
+const (
+	SearchRelevance SearchSort = "relevance" // best text score first
+	SearchRecent    SearchSort = "recent"    // newest first
+)
+
+const (
+	// searchSnippetRadius - characters of content kept around the first match
+	searchSnippetRadius = 80
+	searchMarkOpen      = "<mark>"
+	searchMarkClose     = "</mark>"
+)
+
+// SearchHighlight - html escaped excerpt of a post field with matched terms wrapped in <mark>
+type SearchHighlight struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+// Search - posts matching the text query, which supports "exact phrases" and -excluded words.
+// Title matches weigh most, then tags, then content
+func (p *PostStorage) Search(ctx context.Context, user *User, query string, cq CursorQuery) ([]PostWithLikeStatus, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	text := bson.M{"$search": query}
+	if cq.Language != "" {
+		text["$language"] = cq.Language
+	}
+
+	visible, err := p.visibilityCondition(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	andConditions := []bson.M{
+		{"status": PostPublished},
+		{"deleted_at": notDeleted()},
+		visible,
+	}
+
+	if user != nil {
+		if cq.ShowFollowing && len(cq.FolloweeIDs) > 0 {
+			andConditions = append(andConditions, bson.M{"user_id": bson.M{"$in": cq.FolloweeIDs}})
+		}
+
+		if cq.ShowMentioned {
+			mentioned, err := p.mentionedCondition(ctx, user.Username)
+			if err != nil {
+				return nil, err
+			}
+			andConditions = append(andConditions, mentioned)
+		}
+
+		if len(cq.Roles) > 0 {
+			andConditions = append(andConditions, bson.M{"user_role": bson.M{"$in": cq.Roles}})
+		}
+	}
+
+	if len(cq.Types) > 0 {
+		andConditions = append(andConditions, bson.M{"type": typeCondition(cq.Types)})
+	}
+
+	if cq.QuestionStatus != "" {
+		andConditions = append(andConditions, questionCondition(cq.QuestionStatus))
+	}
+
+	sort := bson.D{{Key: "search_score", Value: -1}, {Key: "_id", Value: -1}}
+	var cursorCondition bson.M
+
+	if cq.SearchSort == SearchRecent {
+		order := -1
+		if cq.Sort == "asc" {
+			order = 1
+		}
+		sort = bson.D{{Key: "published_at", Value: order}, {Key: "_id", Value: order}}
+
+		// cursor query based on publish time, ties broken by post id
+		if cq.Cursor != "" && cq.Cursor != "undefined" {
+			cursor, err := publishedCursorCondition(cq.Cursor, order)
+			if err != nil {
+				return nil, err
+			}
+			andConditions = append(andConditions, cursor)
+		}
+	} else if cq.Cursor != "" && cq.Cursor != "undefined" {
+		// the text score only exists after the $text match, so the cursor applies in a later stage
+		cursorCondition, err = trendingCursorCondition(cq.Cursor, "search_score")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// $text has to be in the first stage
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$text": text, "$and": andConditions}}},
+		{{Key: "$addFields", Value: bson.M{"search_score": bson.M{"$meta": "textScore"}}}},
+	}
+	if cursorCondition != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: cursorCondition}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: sort}},
+		bson.D{{Key: "$limit", Value: cq.Limit}},
+	)
+
+	cursor, err := p.collection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	var posts []Post
+	if err := cursor.All(ctxTimeout, &posts); err != nil {
+		return nil, fmt.Errorf("failed to decode posts: %w", err)
+	}
+
+	postsWithStatus, err := p.withLikeStatus(ctx, user, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	highlighter := newSearchHighlighter(query)
+	for i := range postsWithStatus {
+		postsWithStatus[i].Highlights = highlighter.highlight(&postsWithStatus[i].Post)
+	}
+
+	return postsWithStatus, nil
+}
+
+// SearchCursor - relevance pages continue after the score of the last post, recent pages after its publish time and id
+func SearchCursor(post Post, sort SearchSort) string {
+	if sort == SearchRecent {
+		return PostCursor(post)
+	}
+	return fmt.Sprintf("%s_%s", strconv.FormatFloat(post.SearchScore, 'g', -1, 64), post.ID.Hex())
+}
+
+// searchHighlighter - marks the phrases and words of a query, a word also marks longer forms of its stem
+// so results found through stemming are highlighted too
+type searchHighlighter struct {
+	pattern *regexp.Regexp
+}
+
+var (
+	searchPhrase = regexp.MustCompile(`"([^"]*)"`)
+	searchSuffix = regexp.MustCompile(`(ing|ed|es|s)$`)
+)
+
+func newSearchHighlighter(query string) *searchHighlighter {
+	var alternatives []string
+
+	for _, match := range searchPhrase.FindAllStringSubmatch(query, -1) {
+		if phrase := strings.TrimSpace(match[1]); phrase != "" {
+			alternatives = append(alternatives, regexp.QuoteMeta(phrase))
+		}
+	}
+
+	for _, word := range strings.Fields(searchPhrase.ReplaceAllString(query, " ")) {
+		// excluded words never appear in results
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+
+		word = strings.ToLower(strings.Trim(word, `.,;:!?'"()`))
+		if stem := searchSuffix.ReplaceAllString(word, ""); utf8.RuneCountInString(stem) >= 3 {
+			word = stem
+		}
+		if word != "" {
+			alternatives = append(alternatives, `\b`+regexp.QuoteMeta(word)+`\w*`)
+		}
+	}
+
+	if len(alternatives) == 0 {
+		return &searchHighlighter{}
+	}
+
+	return &searchHighlighter{pattern: regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))}
+}
+
+func (h *searchHighlighter) highlight(post *Post) []SearchHighlight {
+	if h.pattern == nil {
+		return nil
+	}
+
+	var highlights []SearchHighlight
+	if snippet, ok := h.mark(post.Title, 0, len(post.Title)); ok {
+		highlights = append(highlights, SearchHighlight{Field: "title", Snippet: snippet})
+	}
+
+	if loc := h.pattern.FindStringIndex(post.Content); loc != nil {
+		start, end := snippetBounds(post.Content, loc[0], loc[1])
+		if snippet, ok := h.mark(post.Content, start, end); ok {
+			highlights = append(highlights, SearchHighlight{Field: "content", Snippet: snippet})
+		}
+	}
+
+	for _, tag := range post.Tags {
+		if snippet, ok := h.mark(tag, 0, len(tag)); ok {
+			highlights = append(highlights, SearchHighlight{Field: "tags", Snippet: snippet})
+		}
+	}
+
+	return highlights
+}
+
+// mark - escape text[start:end] and wrap its matches, reports whether anything matched
+func (h *searchHighlighter) mark(text string, start, end int) (string, bool) {
+	excerpt := text[start:end]
+	matches := h.pattern.FindAllStringIndex(excerpt, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	last := 0
+	for _, match := range matches {
+		b.WriteString(html.EscapeString(excerpt[last:match[0]]))
+		b.WriteString(searchMarkOpen)
+		b.WriteString(html.EscapeString(excerpt[match[0]:match[1]]))
+		b.WriteString(searchMarkClose)
+		last = match[1]
+	}
+	b.WriteString(html.EscapeString(excerpt[last:]))
+
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+// snippetBounds - byte range of about searchSnippetRadius characters around a match, cut at whitespace
+func snippetBounds(text string, matchStart, matchEnd int) (int, int) {
+	start := matchStart
+	for i := 0; i < searchSnippetRadius && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	if start > 0 {
+		if space := strings.IndexAny(text[start:matchStart], " \n\t"); space >= 0 {
+			start += space + 1
+		}
+	}
+
+	end := matchEnd
+	for i := 0; i < searchSnippetRadius && end < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	if end < len(text) {
+		if space := strings.LastIndexAny(text[matchEnd:end], " \n\t"); space >= 0 {
+			end = matchEnd + space
+		}
+	}
+
+	return start, end
+}
+
+// UserSummary - public fields of a user in search and typeahead results
+type UserSummary struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id"`
+	Username      string             `json:"username" bson:"username"`
+	Role          security.Role      `json:"role" bson:"role"`
+	Bio           string             `json:"bio,omitempty" bson:"bio,omitempty"`
+	Location      string             `json:"location,omitempty" bson:"location,omitempty"`
+	FollowerCount int64              `json:"follower_count" bson:"follower_count"`
+	SearchScore   float64            `json:"-" bson:"search_score,omitempty"` // text score, search results only
+}
+
+var userSummaryProjection = bson.M{
+	"username":       1,
+	"role":           1,
+	"bio":            "$profile.bio",
+	"location":       "$profile.location",
+	"follower_count": 1,
+	"search_score":   1,
+}
+
+// Search - active users whose username, role, location or bio match the text query, best match first
+func (u *UserStorage) Search(ctx context.Context, query string, cq CursorQuery) ([]UserSummary, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	match := bson.M{"$text": bson.M{"$search": query}, "is_active": true}
+	if len(cq.Roles) > 0 {
+		match["role"] = bson.M{"$in": cq.Roles}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{"search_score": bson.M{"$meta": "textScore"}}}},
+	}
+
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		cursorCondition, err := trendingCursorCondition(cq.Cursor, "search_score")
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: cursorCondition}})
+	}
+
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "search_score", Value: -1}, {Key: "_id", Value: -1}}}},
+		bson.D{{Key: "$limit", Value: cq.Limit}},
+		bson.D{{Key: "$project", Value: userSummaryProjection}},
+	)
+
+	cursor, err := u.collection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	users := make([]UserSummary, 0)
+	if err := cursor.All(ctxTimeout, &users); err != nil {
+		return nil, fmt.Errorf("failed to decode users: %w", err)
+	}
+
+	return users, nil
+}
+
+// UserSearchCursor - people pages continue after the score of the last user
+func UserSearchCursor(user UserSummary) string {
+	return fmt.Sprintf("%s_%s", strconv.FormatFloat(user.SearchScore, 'g', -1, 64), user.ID.Hex())
+}
+
+// Autocomplete - active users whose username starts with the prefix, most followed first
+func (u *UserStorage) Autocomplete(ctx context.Context, prefix string, limit int) ([]UserSummary, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	// anchored prefix regex on the lowercased username can use its index
+	filter := bson.M{
+		"username_lower": bson.M{"$regex": "^" + regexp.QuoteMeta(strings.ToLower(prefix))},
+		"is_active":      true,
+	}
+	opts := options.Find().
+		SetProjection(userSummaryProjection).
+		SetSort(bson.D{{Key: "follower_count", Value: -1}, {Key: "username", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := u.collection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find users: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	users := make([]UserSummary, 0)
+	if err := cursor.All(ctxTimeout, &users); err != nil {
+		return nil, fmt.Errorf("failed to decode users: %w", err)
+	}
+
+	return users, nil
+}
+
+// Search - tags containing the normalized query, most used first
+func (t *TagStorage) Search(ctx context.Context, query string, cq CursorQuery) ([]Tag, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	conditions := []bson.M{{"name": bson.M{"$regex": regexp.QuoteMeta(NormalizeTag(query))}}}
+
+	// cursor query based on post count, then name
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		value, name, ok := strings.Cut(cq.Cursor, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor: %s", cq.Cursor)
+		}
+
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"post_count": bson.M{"$lt": count}},
+			{"post_count": count, "name": bson.M{"$gt": name}},
+		}})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "post_count", Value: -1}, {Key: "name", Value: 1}}).
+		SetLimit(int64(cq.Limit))
+
+	cursor, err := t.collection.Find(ctxTimeout, bson.M{"$and": conditions}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tags: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	tags := make([]Tag, 0)
+	if err := cursor.All(ctxTimeout, &tags); err != nil {
+		return nil, fmt.Errorf("failed to decode tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// TagSearchCursor - tag pages continue after the post count and name of the last tag
+func TagSearchCursor(tag Tag) string {
+	return fmt.Sprintf("%d_%s", tag.PostCount, tag.Name)
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSearchHighlighterMark(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		text   string
+		want   string
+		wantOK bool
+	}{
+		{"word", "kitchen", "Kitchen remodel", "<mark>Kitchen</mark> remodel", true},
+		{"every match", "tile", "tile and more tile", "<mark>tile</mark> and more <mark>tile</mark>", true},
+		{"stem marks longer forms", "remodeling", "We remodeled it", "We <mark>remodeled</mark> it", true},
+		{"short stem keeps the word", "bus", "buses and bus", "<mark>buses</mark> and <mark>bus</mark>", true},
+		{"word start only", "tile", "textile", "", false},
+		{"phrase", `"tile floor" cheap`, "new tile floor, cheap", "new <mark>tile floor</mark>, <mark>cheap</mark>", true},
+		{"phrase words apart", `"tile floor"`, "floor and tile", "", false},
+		{"excluded word", "tile -wood", "tile and wood", "<mark>tile</mark> and wood", true},
+		{"punctuation trimmed", "(tile),", "tile", "<mark>tile</mark>", true},
+		{"regexp characters", "c++", "c++ code", "<mark>c++</mark> code", true},
+		{"multi-byte word", "café", "Le café ouvert", "Le <mark>café</mark> ouvert", true},
+		{"escapes around the mark", "tile", `<b>tile</b> & "x"`, "&lt;b&gt;<mark>tile</mark>&lt;/b&gt; &amp; &#34;x&#34;", true},
+		{"escapes inside the mark", `"a<b"`, "x a<b y", "x <mark>a&lt;b</mark> y", true},
+		{"no match", "tile", "wood floor", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newSearchHighlighter(tt.query)
+			if h.pattern == nil {
+				t.Fatalf("newSearchHighlighter(%q) has no pattern", tt.query)
+			}
+
+			got, ok := h.mark(tt.text, 0, len(tt.text))
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("mark() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSearchHighlighterNothingToMark(t *testing.T) {
+	for _, query := range []string{"", "   ", "-wood", `""`, "-wood -tile"} {
+		h := newSearchHighlighter(query)
+		if h.pattern != nil {
+			t.Errorf("newSearchHighlighter(%q) pattern = %s, want none", query, h.pattern)
+		}
+		if got := h.highlight(&Post{Title: "wood tile"}); got != nil {
+			t.Errorf("highlight() with query %q = %v, want nil", query, got)
+		}
+	}
+}
+
+func TestSearchHighlighterMarkExcerpt(t *testing.T) {
+	h := newSearchHighlighter("tile")
+	text := "日本 tile 日本"
+	start := strings.Index(text, "tile") - 1
+	end := start + len(" tile ")
+
+	got, ok := h.mark(text, start, end)
+	if want := "… <mark>tile</mark> …"; !ok || got != want {
+		t.Errorf("mark() = %q, %v, want %q, true", got, ok, want)
+	}
+}
+
+func TestSnippetBounds(t *testing.T) {
+	radius := strings.Repeat("x", searchSnippetRadius)
+
+	tests := []struct {
+		name       string
+		text       string
+		whitespace bool // the excerpt is cut at whitespace on a side that was cut
+	}{
+		{"short text", "a tile floor", false},
+		{"ascii", strings.Repeat("word ", 40) + "tile" + strings.Repeat(" word", 40), true},
+		{"two byte runes", strings.Repeat("éé ", 60) + "tile" + strings.Repeat(" éé", 60), true},
+		{"three byte runes without spaces", strings.Repeat("日", 100) + "tile" + strings.Repeat("日", 100), false},
+		{"four byte runes", strings.Repeat("🙂 ", 60) + "tile" + strings.Repeat(" 🙂", 60), true},
+		{"match at the edges", "tile" + radius + "tile", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matchStart := strings.Index(tt.text, "tile")
+			matchEnd := matchStart + len("tile")
+
+			start, end := snippetBounds(tt.text, matchStart, matchEnd)
+
+			if start < 0 || start > matchStart || end < matchEnd || end > len(tt.text) {
+				t.Fatalf("snippetBounds() = %d, %d, outside of the text or cutting the match %d, %d", start, end, matchStart, matchEnd)
+			}
+			if !utf8.ValidString(tt.text[start:end]) {
+				t.Errorf("snippetBounds() = %d, %d, cuts a rune: %q", start, end, tt.text[start:end])
+			}
+			if before := utf8.RuneCountInString(tt.text[start:matchStart]); before > searchSnippetRadius {
+				t.Errorf("%d runes before the match, want at most %d", before, searchSnippetRadius)
+			}
+			if after := utf8.RuneCountInString(tt.text[matchEnd:end]); after > searchSnippetRadius {
+				t.Errorf("%d runes after the match, want at most %d", after, searchSnippetRadius)
+			}
+
+			if tt.whitespace {
+				if start > 0 && !strings.ContainsAny(tt.text[start-1:start], " \n\t") {
+					t.Errorf("snippet starts inside a word: %q", tt.text[start:matchStart])
+				}
+				if end < len(tt.text) && !strings.ContainsAny(tt.text[end:end+1], " \n\t") {
+					t.Errorf("snippet ends inside a word: %q", tt.text[matchEnd:end])
+				}
+			}
+
+			snippet, ok := newSearchHighlighter("tile").mark(tt.text, start, end)
+			if !ok || !utf8.ValidString(snippet) {
+				t.Errorf("mark() = %q, %v, want a valid snippet", snippet, ok)
+			}
+			if strings.HasPrefix(snippet, "…") != (start > 0) || strings.HasSuffix(snippet, "…") != (end < len(tt.text)) {
+				t.Errorf("mark() = %q, ellipses don't match the bounds %d, %d of %d bytes", snippet, start, end, len(tt.text))
+			}
+		})
+	}
+}
+
+func TestSearchHighlight(t *testing.T) {
+	post := &Post{
+		Title:   "Tile & grout",
+		Content: strings.Repeat("intro ", 30) + "new tiles here" + strings.Repeat(" outro", 30),
+		Tags:    []string{"wood", "tiles"},
+	}
+
+	highlights := newSearchHighlighter("tile").highlight(post)
+
+	fields := make([]string, len(highlights))
+	for i, highlight := range highlights {
+		fields[i] = highlight.Field
+	}
+	if got := strings.Join(fields, ","); got != "title,content,tags" {
+		t.Fatalf("highlighted fields = %s, want title,content,tags", got)
+	}
+
+	if want := "<mark>Tile</mark> &amp; grout"; highlights[0].Snippet != want {
+		t.Errorf("title snippet = %q, want %q", highlights[0].Snippet, want)
+	}
+	if content := highlights[1].Snippet; !strings.HasPrefix(content, "…") || !strings.HasSuffix(content, "…") ||
+		!strings.Contains(content, "new <mark>tiles</mark> here") {
+		t.Errorf("content snippet = %q, want an excerpt around the match", content)
+	}
+	if want := "<mark>tiles</mark>"; highlights[2].Snippet != want {
+		t.Errorf("tag snippet = %q, want %q", highlights[2].Snippet, want)
+	}
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotCollaborator = errors.New("user is not tagged as a collaborator of this post")
+)
+
+// PostType - discriminator between free-form posts and structured post types
+type PostType string
+
+const (
+	PostStandard PostType = "standard"
+	PostShowcase PostType = "showcase"
+)
+
+type BudgetBand string
+
+const (
+	BudgetUnder10K    BudgetBand = "under_10k"
+	Budget10KTo25K    BudgetBand = "10k_25k"
+	Budget25KTo50K    BudgetBand = "25k_50k"
+	Budget50KTo100K   BudgetBand = "50k_100k"
+	BudgetOver100K    BudgetBand = "over_100k"
+	BudgetUndisclosed BudgetBand = "undisclosed"
+)
+
+// Showcase - before/after transformation, images are s3 object keys
+type Showcase struct {
+	BeforeImages  []string               `json:"before_images" bson:"before_images"`
+	AfterImages   []string               `json:"after_images" bson:"after_images"`
+	RoomType      string                 `json:"room_type" bson:"room_type"`
+	Style         string                 `json:"style,omitempty" bson:"style,omitempty"`
+	BudgetBand    BudgetBand             `json:"budget_band" bson:"budget_band"`
+	DurationWeeks int                    `json:"duration_weeks,omitempty" bson:"duration_weeks,omitempty"`
+	Materials     []string               `json:"materials,omitempty" bson:"materials,omitempty"`
+	Collaborators []ShowcaseCollaborator `json:"collaborators,omitempty" bson:"collaborators,omitempty"`
+}
+
+// ShowcaseCollaborator - tagged users are only credited once they confirm
+type ShowcaseCollaborator struct {
+	UserID      primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Confirmed   bool               `json:"confirmed" bson:"confirmed"`
+	ConfirmedAt *time.Time         `json:"confirmed_at,omitempty" bson:"confirmed_at,omitempty"`
+}
+
+// typeCondition - match any of the post types
+func typeCondition(types []PostType) bson.M {
+	return bson.M{"$in": types}
+}
+
+// RespondCollaboration - a confirmed collaborator is credited, a declined one is untagged
+func (p *PostStorage) RespondCollaboration(ctx context.Context, postID, userID primitive.ObjectID, confirm bool) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": postID, "showcase.collaborators.user_id": userID, "deleted_at": notDeleted()}
+
+	update := bson.M{"$pull": bson.M{"showcase.collaborators": bson.M{"user_id": userID}}}
+	if confirm {
+		update = bson.M{"$set": bson.M{
+			"showcase.collaborators.$.confirmed":    true,
+			"showcase.collaborators.$.confirmed_at": time.Now(),
+		}}
+	}
+
+	result, err := p.collection.UpdateOne(ctxTimeout, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to respond to collaboration: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotCollaborator
+	}
+
+	return nil
+}
+
+// GetPendingCollaborations - showcase posts the user is tagged on but hasn't confirmed yet
+func (p *PostStorage) GetPendingCollaborations(ctx context.Context, userID primitive.ObjectID) ([]Post, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"status":     PostPublished,
+		"deleted_at": notDeleted(),
+		"showcase.collaborators": bson.M{"$elemMatch": bson.M{
+			"user_id":   userID,
+			"confirmed": false,
+		}},
+	}
+
+	cursor, err := p.collection.Find(ctxTimeout, filter, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pending collaborations: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	posts := make([]Post, 0)
+	if err := cursor.All(ctxTimeout, &posts); err != nil {
+		return nil, fmt.Errorf("failed to decode posts: %w", err)
+	}
+
+	return posts, nil
+}
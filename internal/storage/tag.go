@@ -0,0 +1,383 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+// tagSynonyms - alternative spellings stored under one canonical tag
+var tagSynonyms = map[string]string{
+	"reno":             "renovation",
+	"remodel":          "renovation",
+	"remodeling":       "renovation",
+	"midcenturymodern": "midcentury",
+	"mcm":              "midcentury",
+	"diy":              "doityourself",
+	"interiors":        "interiordesign",
+	"interior":         "interiordesign",
+	"bath":             "bathroom",
+	"kitchens":         "kitchen",
+}
+
+// tagSeparators - "Open Kitchen", "open-kitchen" and "#OpenKitchen" are the same tag
+var tagSeparators = regexp.MustCompile(`[\s\-]+`)
+
+// NormalizeTag - lower case without '#', whitespace or hyphens, synonyms resolve to the canonical tag
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	tag = strings.TrimLeft(tag, "#")
+	tag = tagSeparators.ReplaceAllString(tag, "")
+
+	if canonical, ok := tagSynonyms[tag]; ok {
+		return canonical
+	}
+	return tag
+}
+
+// NormalizeTags - normalized tags without empty or duplicate tags, in their original order
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// Tag - post_count is the number of published public posts using the tag, refreshed by a background job
+type Tag struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name          string             `json:"name" bson:"name"`
+	PostCount     int64              `json:"post_count" bson:"post_count"`
+	FollowerCount int64              `json:"follower_count" bson:"follower_count"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+	LastUsedAt    time.Time          `json:"last_used_at" bson:"last_used_at"`
+}
+
+// TagWithStatus - tag page header
+type TagWithStatus struct {
+	Tag
+	FollowedByUser bool `json:"followed_by_user"`
+}
+
+// TrendingTag - posts published with the tag within the trending window
+type TrendingTag struct {
+	Name      string `json:"name" bson:"_id"`
+	PostCount int64  `json:"post_count" bson:"post_count"`
+}
+
+type TagFollow struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Tag       string             `json:"tag" bson:"tag"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type TagStorage struct {
+	collection       *mongo.Collection
+	followCollection *mongo.Collection
+	postCollection   *mongo.Collection
+}
+
+// Use - make sure the tags exist so they show up in autocomplete right away
+func (t *TagStorage) Use(ctx context.Context, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, 0, len(tags))
+	for _, tag := range tags {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"name": tag}).
+			SetUpdate(bson.M{
+				"$set":         bson.M{"last_used_at": now},
+				"$setOnInsert": bson.M{"post_count": 0, "follower_count": 0, "created_at": now},
+			}).
+			SetUpsert(true))
+	}
+
+	if _, err := t.collection.BulkWrite(ctxTimeout, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to save tags: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TagStorage) GetByName(ctx context.Context, name string) (*Tag, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var tag Tag
+	if err := t.collection.FindOne(ctxTimeout, bson.M{"name": name}).Decode(&tag); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrTagNotFound
+		}
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+
+	return &tag, nil
+}
+
+// Autocomplete - tags starting with the prefix, most used first
+func (t *TagStorage) Autocomplete(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	// anchored prefix regex can use the name index
+	filter := bson.M{"name": bson.M{"$regex": "^" + regexp.QuoteMeta(NormalizeTag(prefix))}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "post_count", Value: -1}, {Key: "name", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := t.collection.Find(ctxTimeout, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tags: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	tags := make([]Tag, 0)
+	if err := cursor.All(ctxTimeout, &tags); err != nil {
+		return nil, fmt.Errorf("failed to decode tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// GetTrending - tags used by the most posts published since the given time
+func (t *TagStorage) GetTrending(ctx context.Context, since time.Time, limit int) ([]TrendingTag, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"status":       PostPublished,
+			"visibility":   VisibilityPublic,
+			"deleted_at":   notDeleted(),
+			"published_at": bson.M{"$gte": since},
+			"tags.0":       bson.M{"$exists": true},
+		}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "post_count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "post_count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := t.postCollection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trending tags: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	tags := make([]TrendingTag, 0)
+	if err := cursor.All(ctxTimeout, &tags); err != nil {
+		return nil, fmt.Errorf("failed to decode trending tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// RefreshCounts - recount published posts per tag, tags no longer used drop to 0
+func (t *TagStorage) RefreshCounts(ctx context.Context) error {
+	refreshedAt := time.Now()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"status":     PostPublished,
+			"visibility": VisibilityPublic,
+			"deleted_at": notDeleted(),
+			"tags.0":     bson.M{"$exists": true},
+		}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          "$tags",
+			"post_count":   bson.M{"$sum": 1},
+			"last_used_at": bson.M{"$max": "$published_at"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":            0,
+			"name":           "$_id",
+			"post_count":     1,
+			"last_used_at":   1,
+			"follower_count": bson.M{"$literal": 0},
+			"created_at":     refreshedAt,
+			"refreshed_at":   refreshedAt,
+		}}},
+		{{Key: "$merge", Value: bson.M{
+			"into": t.collection.Name(),
+			"on":   "name",
+			// keep follower_count and created_at of existing tags
+			"whenMatched": mongo.Pipeline{{{Key: "$set", Value: bson.M{
+				"post_count":   "$$new.post_count",
+				"last_used_at": "$$new.last_used_at",
+				"refreshed_at": "$$new.refreshed_at",
+			}}}},
+			"whenNotMatched": "insert",
+		}}},
+	}
+
+	cursor, err := t.postCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to refresh tag counts: %w", err)
+	}
+	cursor.Close(ctx)
+
+	_, err = t.collection.UpdateMany(ctx,
+		bson.M{"$or": []bson.M{
+			{"refreshed_at": bson.M{"$lt": refreshedAt}},
+			{"refreshed_at": bson.M{"$exists": false}},
+		}},
+		bson.M{"$set": bson.M{"post_count": 0, "refreshed_at": refreshedAt}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reset unused tags: %w", err)
+	}
+
+	return nil
+}
+
+// Follow - following a tag twice is a no-op
+func (t *TagStorage) Follow(ctx context.Context, userID primitive.ObjectID, name string) error {
+	client := t.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		follow := TagFollow{ID: primitive.NewObjectID(), UserID: userID, Tag: name, CreatedAt: time.Now()}
+		if _, err := t.followCollection.InsertOne(sessCtx, follow); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to follow tag: %w", err)
+		}
+
+		if _, err := t.collection.UpdateOne(sessCtx, bson.M{"name": name}, bson.M{"$inc": bson.M{"follower_count": 1}}); err != nil {
+			return nil, fmt.Errorf("failed to update tag follower count: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+func (t *TagStorage) Unfollow(ctx context.Context, userID primitive.ObjectID, name string) error {
+	client := t.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := t.followCollection.DeleteOne(sessCtx, bson.M{"user_id": userID, "tag": name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to unfollow tag: %w", err)
+		}
+
+		if result.DeletedCount == 0 {
+			return nil, nil
+		}
+
+		if _, err := t.collection.UpdateOne(sessCtx, bson.M{"name": name}, bson.M{"$inc": bson.M{"follower_count": -1}}); err != nil {
+			return nil, fmt.Errorf("failed to update tag follower count: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+func (t *TagStorage) IsFollowing(ctx context.Context, userID primitive.ObjectID, name string) (bool, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	count, err := t.followCollection.CountDocuments(ctxTimeout, bson.M{"user_id": userID, "tag": name})
+	if err != nil {
+		return false, fmt.Errorf("failed to check tag follow: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// GetFollowed - tags the user follows, most recently followed first
+func (t *TagStorage) GetFollowed(ctx context.Context, userID primitive.ObjectID) ([]string, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"_id": -1})
+	cursor, err := t.followCollection.Find(ctxTimeout, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find followed tags: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	tags := make([]string, 0)
+	for cursor.Next(ctxTimeout) {
+		var follow TagFollow
+		if err := cursor.Decode(&follow); err != nil {
+			return nil, fmt.Errorf("failed to decode followed tag: %w", err)
+		}
+		tags = append(tags, follow.Tag)
+	}
+
+	return tags, cursor.Err()
+}
+
+// migrateTags - normalize tags of posts created before tags were normalized
+func migrateTags(ctx context.Context, posts, tags *mongo.Collection) error {
+	// tags are only missing on the first run after tags became first-class
+	existing, err := tags.CountDocuments(ctx, bson.M{})
+	if err != nil || existing > 0 {
+		return err
+	}
+
+	opts := options.Find().SetProjection(bson.M{"tags": 1})
+	cursor, err := posts.Find(ctx, bson.M{"tags.0": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var post struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Tags []string           `bson:"tags"`
+		}
+		if err := cursor.Decode(&post); err != nil {
+			return err
+		}
+
+		normalized := NormalizeTags(post.Tags)
+		if strings.Join(normalized, ",") == strings.Join(post.Tags, ",") {
+			continue
+		}
+
+		if _, err := posts.UpdateByID(ctx, post.ID, bson.M{"$set": bson.M{"tags": normalized}}); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CommentSort - order of comments in a thread
+type CommentSort string
+
+const (
+	CommentNewest CommentSort = "newest"
+	CommentOldest CommentSort = "oldest"
+	CommentTop    CommentSort = "top"   // most replies first
+	CommentVotes  CommentSort = "votes" // most upvoted answers first
+)
+
+// countField - field ranked sorts order by before the comment id
+func countField(sort CommentSort) string {
+	if sort == CommentVotes {
+		return "vote_count"
+	}
+	return "reply_count"
+}
+
+// addShownReply - a reply of the parent is shown in the thread again. A deleted parent without shown replies
+// was hidden, it comes back as a tombstone so its own parent gains a shown reply too
+func (c *CommentStorage) addShownReply(ctx context.Context, parentID *primitive.ObjectID) error {
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"parent_id": 1, "deleted_at": 1, "reply_count": 1})
+
+	for parentID != nil {
+		var parent Comment
+		err := c.collection.FindOneAndUpdate(ctx, bson.M{"_id": *parentID}, bson.M{"$inc": bson.M{"reply_count": 1}}, opts).Decode(&parent)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil
+			}
+			return fmt.Errorf("failed to update reply count: %w", err)
+		}
+
+		if parent.DeletedAt == nil || parent.ReplyCount > 0 {
+			return nil
+		}
+		parentID = parent.ParentID
+	}
+
+	return nil
+}
+
+// removeShownReply - a reply of the parent is no longer shown in the thread. A deleted parent left without
+// shown replies is hidden as well, so its own parent loses a shown reply too
+func (c *CommentStorage) removeShownReply(ctx context.Context, parentID *primitive.ObjectID) error {
+	opts := options.FindOneAndUpdate().
+		SetProjection(bson.M{"parent_id": 1, "deleted_at": 1, "reply_count": 1}).
+		SetReturnDocument(options.After)
+
+	for parentID != nil {
+		var parent Comment
+		err := c.collection.FindOneAndUpdate(ctx, bson.M{"_id": *parentID}, bson.M{"$inc": bson.M{"reply_count": -1}}, opts).Decode(&parent)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil
+			}
+			return fmt.Errorf("failed to update reply count: %w", err)
+		}
+
+		if parent.DeletedAt == nil || parent.ReplyCount > 0 {
+			return nil
+		}
+		parentID = parent.ParentID
+	}
+
+	return nil
+}
+
+// shownReplyCounts - reply count of every parent among the comments, a comment is shown while it is live
+// or while any of its replies is shown
+func shownReplyCounts(comments []Comment) map[primitive.ObjectID]int64 {
+	replies := make(map[primitive.ObjectID][]Comment)
+	for _, comment := range comments {
+		if comment.ParentID != nil {
+			replies[*comment.ParentID] = append(replies[*comment.ParentID], comment)
+		}
+	}
+
+	shown := make(map[primitive.ObjectID]bool, len(comments))
+	var isShown func(comment Comment) bool
+	isShown = func(comment Comment) bool {
+		if value, ok := shown[comment.ID]; ok {
+			return value
+		}
+
+		// set before visiting the replies, so a broken parent chain can't loop
+		shown[comment.ID] = comment.DeletedAt == nil
+		for _, reply := range replies[comment.ID] {
+			if shown[comment.ID] {
+				break
+			}
+			shown[comment.ID] = isShown(reply)
+		}
+		return shown[comment.ID]
+	}
+
+	counts := make(map[primitive.ObjectID]int64)
+	for _, comment := range comments {
+		if comment.ParentID != nil && isShown(comment) {
+			counts[*comment.ParentID]++
+		}
+	}
+
+	return counts
+}
+
+// GetTopLevel - a page of comments on the post that aren't replies
+func (c *CommentStorage) GetTopLevel(ctx context.Context, postID primitive.ObjectID, cq CursorQuery) ([]CommentWithParentAndUser, error) {
+	return c.getThread(ctx, bson.M{"post_id": postID, "parent_id": bson.M{"$exists": false}}, cq)
+}
+
+// GetReplies - a page of direct replies to a comment of the post
+func (c *CommentStorage) GetReplies(ctx context.Context, postID, parentID primitive.ObjectID, cq CursorQuery) ([]CommentWithParentAndUser, error) {
+	return c.getThread(ctx, bson.M{"post_id": postID, "parent_id": parentID}, cq)
+}
+
+// getThread - deleted comments are only included as tombstones while they still have replies
+func (c *CommentStorage) getThread(ctx context.Context, filter bson.M, cq CursorQuery) ([]CommentWithParentAndUser, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	andConditions := []bson.M{
+		filter,
+		{"$or": []bson.M{
+			{"deleted_at": notDeleted()},
+			{"reply_count": bson.M{"$gt": 0}},
+		}},
+	}
+
+	sort := bson.D{{Key: "_id", Value: -1}}
+	switch cq.CommentSort {
+	case CommentOldest:
+		sort = bson.D{{Key: "_id", Value: 1}}
+	case CommentTop, CommentVotes:
+		sort = bson.D{{Key: countField(cq.CommentSort), Value: -1}, {Key: "_id", Value: -1}}
+	}
+
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		condition, err := threadCursorCondition(cq.Cursor, cq.CommentSort)
+		if err != nil {
+			return nil, err
+		}
+		andConditions = append(andConditions, condition)
+	}
+
+	opts := options.Find().
+		SetSort(sort).
+		SetLimit(int64(cq.Limit))
+
+	cursor, err := c.collection.Find(ctxTimeout, bson.M{"$and": andConditions}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find comments: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	comments := make([]Comment, 0)
+	if err := cursor.All(ctxTimeout, &comments); err != nil {
+		return nil, fmt.Errorf("failed to decode comments: %w", err)
+	}
+
+	userIDs := make([]primitive.ObjectID, 0, len(comments))
+	for _, comment := range comments {
+		if comment.DeletedAt == nil {
+			userIDs = append(userIDs, comment.UserID)
+		}
+	}
+
+	userMap, err := c.userStorage.getUsernames(ctxTimeout, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CommentWithParentAndUser, 0, len(comments))
+	for _, comment := range comments {
+		item := CommentWithParentAndUser{
+			ID:              comment.ID,
+			UserID:          comment.UserID,
+			Username:        userMap[comment.UserID],
+			PostID:          comment.PostID,
+			Depth:           comment.Depth,
+			ReplyCount:      comment.ReplyCount,
+			ReactionCounts:  comment.ReactionCounts,
+			VoteCount:       comment.VoteCount,
+			Accepted:        comment.Accepted,
+			Content:         comment.Content,
+			MentionEntities: comment.MentionEntities,
+			CreatedAt:       comment.CreatedAt,
+			EditedAt:        comment.EditedAt,
+		}
+		if comment.DeletedAt != nil {
+			item = tombstone(item)
+			item.ReactionCounts = nil
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// ThreadCursor - next cursor of a comment page, top and votes sorted pages also carry the count they rank by
+func ThreadCursor(comment CommentWithParentAndUser, sort CommentSort) string {
+	switch sort {
+	case CommentTop:
+		return fmt.Sprintf("%d_%s", comment.ReplyCount, comment.ID.Hex())
+	case CommentVotes:
+		return fmt.Sprintf("%d_%s", comment.VoteCount, comment.ID.Hex())
+	}
+	return comment.ID.Hex()
+}
+
+func threadCursorCondition(cursor string, sort CommentSort) (bson.M, error) {
+	if sort != CommentTop && sort != CommentVotes {
+		cursorID, err := primitive.ObjectIDFromHex(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor ID: %w", err)
+		}
+		if sort == CommentOldest {
+			return bson.M{"_id": bson.M{"$gt": cursorID}}, nil
+		}
+		return bson.M{"_id": bson.M{"$lt": cursorID}}, nil
+	}
+
+	count, id, ok := strings.Cut(cursor, "_")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+
+	rank, err := strconv.ParseInt(count, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	cursorID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor ID: %w", err)
+	}
+
+	field := countField(sort)
+	return bson.M{"$or": []bson.M{
+		{field: bson.M{"$lt": rank}},
+		{field: rank, "_id": bson.M{"$lt": cursorID}},
+	}}, nil
+}
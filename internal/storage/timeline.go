@@ -0,0 +1,415 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// FanOutLimit - posts of authors with at least this many followers are not copied to every follower's timeline,
+	// followers read them from the posts when the timeline is loaded
+	FanOutLimit = 10000
+	// timelineBackfill - most recent posts of a followee added to the timeline on follow
+	timelineBackfill = 100
+)
+
+// fanOutVisibility - posts every follower can read, other visibilities never reach a timeline
+var fanOutVisibility = []PostVisibility{VisibilityPublic, VisibilityFollowers}
+
+// TimelineEntry - a post of a followee in the home timeline of a user
+type TimelineEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      primitive.ObjectID `bson:"user_id"` // owner of the timeline
+	PostID      primitive.ObjectID `bson:"post_id"`
+	AuthorID    primitive.ObjectID `bson:"author_id"`
+	PublishedAt time.Time          `bson:"published_at"`
+}
+
+type TimelineStorage struct {
+	collection       *mongo.Collection
+	userCollection   *mongo.Collection
+	followCollection *mongo.Collection
+	postStorage      *PostStorage
+}
+
+// timelineKey - position of a post in a timeline, newest published first
+type timelineKey struct {
+	publishedAt time.Time
+	id          primitive.ObjectID
+}
+
+func postTimelineKey(post Post) timelineKey {
+	publishedAt := post.CreatedAt
+	if post.PublishedAt != nil {
+		publishedAt = *post.PublishedAt
+	}
+	return timelineKey{publishedAt: publishedAt, id: post.ID}
+}
+
+// before - k comes after other in the timeline
+func (k timelineKey) before(other timelineKey) bool {
+	if !k.publishedAt.Equal(other.publishedAt) {
+		return k.publishedAt.Before(other.publishedAt)
+	}
+	return k.id.Hex() < other.id.Hex()
+}
+
+func (k timelineKey) String() string {
+	return fmt.Sprintf("%d_%s", k.publishedAt.UnixMilli(), k.id.Hex())
+}
+
+func parseTimelineKey(cursor string) (timelineKey, error) {
+	value, id, ok := strings.Cut(cursor, "_")
+	if !ok {
+		return timelineKey{}, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return timelineKey{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	cursorID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return timelineKey{}, fmt.Errorf("invalid cursor ID: %w", err)
+	}
+
+	return timelineKey{publishedAt: time.UnixMilli(millis), id: cursorID}, nil
+}
+
+func timelineCursorCondition(cursor, timeField, idField string) (bson.M, error) {
+	key, err := parseTimelineKey(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{"$or": []bson.M{
+		{timeField: bson.M{"$lt": key.publishedAt}},
+		{timeField: key.publishedAt, idField: bson.M{"$lt": key.id}},
+	}}, nil
+}
+
+// fansOutOnWrite - authors below FanOutLimit have their posts copied to their followers' timelines
+func (t *TimelineStorage) fansOutOnWrite(ctx context.Context, authorID primitive.ObjectID) (bool, error) {
+	var author struct {
+		FollowerCount int64 `bson:"follower_count"`
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"follower_count": 1})
+	if err := t.userCollection.FindOne(ctx, bson.M{"_id": authorID}, opts).Decode(&author); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get follower count: %w", err)
+	}
+
+	return author.FollowerCount < FanOutLimit, nil
+}
+
+// mergeIntoTimelines - entries already in a timeline are kept as is
+func (t *TimelineStorage) mergeIntoTimelines() bson.D {
+	return bson.D{{Key: "$merge", Value: bson.M{
+		"into":           t.collection.Name(),
+		"on":             bson.A{"user_id", "post_id"},
+		"whenMatched":    "keepExisting",
+		"whenNotMatched": "insert",
+	}}}
+}
+
+// FanOut - add a published post to the timeline of every follower of its author,
+// posts that aren't published or visible to followers are skipped
+func (t *TimelineStorage) FanOut(ctx context.Context, postID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":        postID,
+		"status":     PostPublished,
+		"visibility": bson.M{"$in": fanOutVisibility},
+		"deleted_at": notDeleted(),
+	}
+
+	var post Post
+	if err := t.postStorage.collection.FindOne(ctxTimeout, filter).Decode(&post); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return fmt.Errorf("failed to find post: %w", err)
+	}
+
+	fanOut, err := t.fansOutOnWrite(ctxTimeout, post.UserID)
+	if err != nil || !fanOut {
+		return err
+	}
+
+	key := postTimelineKey(post)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"followee_id": post.UserID}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":          0,
+			"user_id":      "$follower_id",
+			"post_id":      bson.M{"$literal": post.ID},
+			"author_id":    bson.M{"$literal": post.UserID},
+			"published_at": bson.M{"$literal": key.publishedAt},
+		}}},
+		t.mergeIntoTimelines(),
+	}
+
+	cursor, err := t.followCollection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to fan out post: %w", err)
+	}
+	return cursor.Close(ctxTimeout)
+}
+
+// Remove - take a deleted post out of every timeline
+func (t *TimelineStorage) Remove(ctx context.Context, postID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if _, err := t.collection.DeleteMany(ctxTimeout, bson.M{"post_id": postID}); err != nil {
+		return fmt.Errorf("failed to remove post from timelines: %w", err)
+	}
+	return nil
+}
+
+// Backfill - add the most recent posts of a new followee to the follower's timeline
+func (t *TimelineStorage) Backfill(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	fanOut, err := t.fansOutOnWrite(ctxTimeout, followeeID)
+	if err != nil || !fanOut {
+		return err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"user_id":    followeeID,
+			"status":     PostPublished,
+			"visibility": bson.M{"$in": fanOutVisibility},
+			"deleted_at": notDeleted(),
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "published_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$limit", Value: timelineBackfill}},
+		{{Key: "$project", Value: bson.M{
+			"_id":          0,
+			"user_id":      bson.M{"$literal": followerID},
+			"post_id":      "$_id",
+			"author_id":    "$user_id",
+			"published_at": bson.M{"$ifNull": bson.A{"$published_at", "$created_at"}},
+		}}},
+		t.mergeIntoTimelines(),
+	}
+
+	cursor, err := t.postStorage.collection.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to backfill timeline: %w", err)
+	}
+	return cursor.Close(ctxTimeout)
+}
+
+// RemoveAuthor - take the posts of an unfollowed user out of the follower's timeline
+func (t *TimelineStorage) RemoveAuthor(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	if _, err := t.collection.DeleteMany(ctxTimeout, bson.M{"user_id": followerID, "author_id": followeeID}); err != nil {
+		return fmt.Errorf("failed to remove followee posts from timeline: %w", err)
+	}
+	return nil
+}
+
+// largeFollowees - followees above FanOutLimit, their posts are read at request time
+func (t *TimelineStorage) largeFollowees(ctx context.Context, userID primitive.ObjectID) ([]interface{}, error) {
+	large, err := t.userCollection.Distinct(ctx, "_id", bson.M{"follower_count": bson.M{"$gte": FanOutLimit}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get large accounts: %w", err)
+	}
+	if len(large) == 0 {
+		return nil, nil
+	}
+
+	followed, err := t.followCollection.Distinct(ctx, "followee_id", bson.M{"follower_id": userID, "followee_id": bson.M{"$in": large}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followed large accounts: %w", err)
+	}
+	return followed, nil
+}
+
+// Get - a page of the user's home timeline merged with the posts of large followees and followed tags,
+// returns the cursor of the next page, empty once every source is exhausted
+func (t *TimelineStorage) Get(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, string, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	conditions := []bson.M{{"status": PostPublished, "deleted_at": notDeleted()}}
+	if len(cq.Tags) > 0 {
+		conditions = append(conditions, bson.M{"tags": bson.M{"$in": cq.Tags}})
+	}
+	if len(cq.Roles) > 0 {
+		conditions = append(conditions, bson.M{"user_role": bson.M{"$in": cq.Roles}})
+	}
+	if len(cq.Types) > 0 {
+		conditions = append(conditions, bson.M{"type": typeCondition(cq.Types)})
+	}
+	if cq.QuestionStatus != "" {
+		conditions = append(conditions, questionCondition(cq.QuestionStatus))
+	}
+	if cq.ShowMentioned {
+		mentioned, err := t.postStorage.mentionedCondition(ctxTimeout, user.Username)
+		if err != nil {
+			return nil, "", err
+		}
+		conditions = append(conditions, mentioned)
+	}
+
+	entryFilter := bson.M{"user_id": user.ID}
+	var postCursor bson.M
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		entryCursor, err := timelineCursorCondition(cq.Cursor, "published_at", "post_id")
+		if err != nil {
+			return nil, "", err
+		}
+		entryFilter = bson.M{"$and": []bson.M{entryFilter, entryCursor}}
+
+		postCursor, err = timelineCursorCondition(cq.Cursor, "published_at", "_id")
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	// 1. fanned out posts
+	opts := options.Find().
+		SetSort(bson.D{{Key: "published_at", Value: -1}, {Key: "post_id", Value: -1}}).
+		SetLimit(int64(cq.Limit))
+
+	cursor, err := t.collection.Find(ctxTimeout, entryFilter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get timeline: %w", err)
+	}
+
+	var entries []TimelineEntry
+	if err := cursor.All(ctxTimeout, &entries); err != nil {
+		return nil, "", fmt.Errorf("failed to decode timeline: %w", err)
+	}
+
+	var posts []Post
+	if len(entries) > 0 {
+		postIDs := make([]primitive.ObjectID, len(entries))
+		for i, entry := range entries {
+			postIDs[i] = entry.PostID
+		}
+
+		// visibility may have changed since the fan out
+		filter := bson.M{"$and": append([]bson.M{
+			{"_id": bson.M{"$in": postIDs}, "visibility": bson.M{"$in": fanOutVisibility}},
+		}, conditions...)}
+
+		cursor, err := t.postStorage.collection.Find(ctxTimeout, filter)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to find timeline posts: %w", err)
+		}
+		if err := cursor.All(ctxTimeout, &posts); err != nil {
+			return nil, "", fmt.Errorf("failed to decode timeline posts: %w", err)
+		}
+	}
+
+	// 2. posts read at request time
+	largeFollowees, err := t.largeFollowees(ctxTimeout, user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var sources []bson.M
+	if len(largeFollowees) > 0 {
+		sources = append(sources, bson.M{"user_id": bson.M{"$in": largeFollowees}, "visibility": bson.M{"$in": fanOutVisibility}})
+	}
+	if len(cq.FollowedTags) > 0 {
+		sources = append(sources, bson.M{"tags": bson.M{"$in": cq.FollowedTags}, "$or": []bson.M{
+			{"visibility": VisibilityPublic},
+			{"user_id": user.ID},
+			{"visibility": VisibilityMentioned, "mentions": user.Username},
+		}})
+	}
+
+	var pulled []Post
+	if len(sources) > 0 {
+		pulledConditions := append([]bson.M{{"$or": sources}}, conditions...)
+		if postCursor != nil {
+			pulledConditions = append(pulledConditions, postCursor)
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "published_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetLimit(int64(cq.Limit))
+
+		cursor, err := t.postStorage.collection.Find(ctxTimeout, bson.M{"$and": pulledConditions}, opts)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to find posts: %w", err)
+		}
+		if err := cursor.All(ctxTimeout, &pulled); err != nil {
+			return nil, "", fmt.Errorf("failed to decode posts: %w", err)
+		}
+	}
+
+	// 3. merge
+	merged, next := mergeTimelinePage(entries, posts, pulled, cq.Limit)
+
+	postsWithStatus, err := t.postStorage.withLikeStatus(ctx, user, merged)
+	if err != nil {
+		return nil, "", err
+	}
+	return postsWithStatus, next, nil
+}
+
+// mergeTimelinePage - merge the fanned out posts of the entries with the pulled posts, newest first.
+// A full source may have more posts right after its last one, so the page stops there
+func mergeTimelinePage(entries []TimelineEntry, posts, pulled []Post, limit int) ([]Post, string) {
+	var boundary *timelineKey
+	if len(entries) >= limit {
+		last := entries[len(entries)-1]
+		boundary = &timelineKey{publishedAt: last.PublishedAt, id: last.PostID}
+	}
+	if len(pulled) >= limit {
+		last := postTimelineKey(pulled[len(pulled)-1])
+		if boundary == nil || boundary.before(last) {
+			boundary = &last
+		}
+	}
+
+	seen := make(map[primitive.ObjectID]bool, len(posts)+len(pulled))
+	merged := make([]Post, 0, len(posts)+len(pulled))
+	for _, post := range append(posts, pulled...) {
+		if seen[post.ID] || (boundary != nil && postTimelineKey(post).before(*boundary)) {
+			continue
+		}
+		seen[post.ID] = true
+		merged = append(merged, post)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return postTimelineKey(merged[j]).before(postTimelineKey(merged[i]))
+	})
+
+	next := ""
+	switch {
+	case len(merged) >= limit:
+		merged = merged[:limit]
+		next = postTimelineKey(merged[len(merged)-1]).String()
+	case boundary != nil:
+		next = boundary.String()
+	}
+
+	return merged, next
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testObjectID(n byte) primitive.ObjectID {
+	var id primitive.ObjectID
+	id[len(id)-1] = n
+	return id
+}
+
+func testPost(n byte, millis int64) Post {
+	publishedAt := time.UnixMilli(millis)
+	return Post{ID: testObjectID(n), PublishedAt: &publishedAt}
+}
+
+func testEntries(posts ...Post) []TimelineEntry {
+	entries := make([]TimelineEntry, len(posts))
+	for i, post := range posts {
+		entries[i] = TimelineEntry{PostID: post.ID, PublishedAt: *post.PublishedAt}
+	}
+	return entries
+}
+
+func TestTimelineKeyBefore(t *testing.T) {
+	tests := []struct {
+		name  string
+		k     timelineKey
+		other timelineKey
+		want  bool
+	}{
+		{"older", timelineKey{time.UnixMilli(1), testObjectID(9)}, timelineKey{time.UnixMilli(2), testObjectID(1)}, true},
+		{"newer", timelineKey{time.UnixMilli(2), testObjectID(1)}, timelineKey{time.UnixMilli(1), testObjectID(9)}, false},
+		{"same time smaller id", timelineKey{time.UnixMilli(1), testObjectID(1)}, timelineKey{time.UnixMilli(1), testObjectID(2)}, true},
+		{"same time larger id", timelineKey{time.UnixMilli(1), testObjectID(2)}, timelineKey{time.UnixMilli(1), testObjectID(1)}, false},
+		{"equal", timelineKey{time.UnixMilli(1), testObjectID(1)}, timelineKey{time.UnixMilli(1), testObjectID(1)}, false},
+		{"id compared by bytes", timelineKey{time.UnixMilli(1), testObjectID(0x0f)}, timelineKey{time.UnixMilli(1), testObjectID(0xf0)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.k.before(tt.other); got != tt.want {
+				t.Errorf("before() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimelineKey(t *testing.T) {
+	key := timelineKey{publishedAt: time.UnixMilli(1700000000123), id: testObjectID(7)}
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    timelineKey
+		wantErr bool
+	}{
+		{"round trip", key.String(), key, false},
+		{"no separator", "1700000000123", timelineKey{}, true},
+		{"invalid time", "abc_" + key.id.Hex(), timelineKey{}, true},
+		{"invalid id", "1700000000123_xyz", timelineKey{}, true},
+		{"empty", "", timelineKey{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimelineKey(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimelineKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.publishedAt.Equal(tt.want.publishedAt) || got.id != tt.want.id {
+				t.Errorf("parseTimelineKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeTimelinePage(t *testing.T) {
+	p10, p9, p8, p7 := testPost(10, 10), testPost(9, 9), testPost(8, 8), testPost(7, 7)
+	p5, p4 := testPost(5, 5), testPost(4, 4)
+	tieA, tieB := testPost(1, 6), testPost(2, 6)
+
+	tests := []struct {
+		name    string
+		entries []TimelineEntry
+		posts   []Post // fanned out posts still visible
+		pulled  []Post
+		limit   int
+		want    []Post
+		next    string
+	}{
+		{
+			name:    "both sources exhausted",
+			entries: testEntries(p10, p8),
+			posts:   []Post{p10, p8},
+			pulled:  []Post{p9},
+			limit:   5,
+			want:    []Post{p10, p9, p8},
+			next:    "",
+		},
+		{
+			name:    "page filled",
+			entries: testEntries(p10, p8, p7),
+			posts:   []Post{p10, p8, p7},
+			pulled:  []Post{p9},
+			limit:   3,
+			want:    []Post{p10, p9, p8},
+			next:    postTimelineKey(p8).String(),
+		},
+		{
+			name:    "full entries stop the pulled posts",
+			entries: testEntries(p10, p8),
+			posts:   []Post{p10, p8},
+			pulled:  []Post{p9, p5},
+			limit:   2,
+			want:    []Post{p10, p9},
+			next:    postTimelineKey(p9).String(),
+		},
+		{
+			name:    "full entries with hidden posts continue from the last entry",
+			entries: testEntries(p10, p9, p8),
+			posts:   []Post{p10},
+			pulled:  []Post{p5},
+			limit:   3,
+			want:    []Post{p10},
+			next:    postTimelineKey(p8).String(),
+		},
+		{
+			name:    "full pulled posts stop the entries",
+			entries: testEntries(p9, p4),
+			posts:   []Post{p9, p4},
+			pulled:  []Post{p10, p8, p7},
+			limit:   3,
+			want:    []Post{p10, p9, p8},
+			next:    postTimelineKey(p8).String(),
+		},
+		{
+			name:    "both full stop at the newer boundary",
+			entries: testEntries(p10, p5),
+			posts:   []Post{p10},
+			pulled:  []Post{p9, p8},
+			limit:   2,
+			want:    []Post{p10, p9},
+			next:    postTimelineKey(p9).String(),
+		},
+		{
+			name:    "post in both sources appears once",
+			entries: testEntries(p10, p9),
+			posts:   []Post{p10, p9},
+			pulled:  []Post{p9, p8},
+			limit:   5,
+			want:    []Post{p10, p9, p8},
+			next:    "",
+		},
+		{
+			name:    "same publish time ordered by id",
+			entries: testEntries(tieA),
+			posts:   []Post{tieA},
+			pulled:  []Post{tieB},
+			limit:   5,
+			want:    []Post{tieB, tieA},
+			next:    "",
+		},
+		{
+			name:  "empty",
+			limit: 5,
+			want:  []Post{},
+			next:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next := mergeTimelinePage(tt.entries, tt.posts, tt.pulled, tt.limit)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("mergeTimelinePage() returned %d posts, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].ID != tt.want[i].ID {
+					t.Errorf("post %d = %s, want %s", i, got[i].ID.Hex(), tt.want[i].ID.Hex())
+				}
+			}
+			if next != tt.next {
+				t.Errorf("next = %q, want %q", next, tt.next)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TrashRetention - soft-deleted posts and comments can be restored for this long before they are purged
+const TrashRetention = 30 * 24 * time.Hour
+
+// notDeleted - condition on deleted_at excluding soft-deleted documents
+func notDeleted() bson.M {
+	return bson.M{"$exists": false}
+}
+
+// Restore - restore a soft-deleted post of the user that is still within the retention window
+func (p *PostStorage) Restore(ctx context.Context, postID, userID primitive.ObjectID) error {
+	client := p.collection.Database().Client()
+
+	filter := bson.M{
+		"_id":        postID,
+		"user_id":    userID,
+		"deleted_at": bson.M{"$gte": time.Now().Add(-TrashRetention)},
+	}
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var restored Post
+		err := p.collection.FindOneAndUpdate(sessCtx, filter, bson.M{"$unset": bson.M{"deleted_at": ""}}).Decode(&restored)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrPostNotFound
+			}
+			return nil, fmt.Errorf("failed to restore post: %w", err)
+		}
+
+		return nil, p.incrementShareCount(sessCtx, restored.RepostOf, 1)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// GetTrash - soft-deleted posts of the user that can still be restored, most recently deleted first
+func (p *PostStorage) GetTrash(ctx context.Context, userID primitive.ObjectID) ([]Post, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":    userID,
+		"deleted_at": bson.M{"$gte": time.Now().Add(-TrashRetention)},
+	}
+
+	cursor, err := p.collection.Find(ctxTimeout, filter, options.Find().SetSort(bson.M{"deleted_at": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deleted posts: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	posts := make([]Post, 0)
+	if err := cursor.All(ctxTimeout, &posts); err != nil {
+		return nil, fmt.Errorf("failed to decode posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// GetExpiredTrash - posts deleted before the cutoff, oldest first
+func (p *PostStorage) GetExpiredTrash(ctx context.Context, cutoff time.Time, limit int) ([]Post, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.M{"deleted_at": 1}).
+		SetLimit(int64(limit))
+
+	cursor, err := p.collection.Find(ctxTimeout, bson.M{"deleted_at": bson.M{"$lt": cutoff}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expired posts: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	posts := make([]Post, 0)
+	if err := cursor.All(ctxTimeout, &posts); err != nil {
+		return nil, fmt.Errorf("failed to decode posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// ImageKeys - s3 object keys referenced by the post and any of its revisions, without duplicates
+func (p *PostStorage) ImageKeys(ctx context.Context, post *Post) ([]string, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	keys := append([]string{}, post.Images...)
+	if post.Showcase != nil {
+		keys = append(keys, post.Showcase.BeforeImages...)
+		keys = append(keys, post.Showcase.AfterImages...)
+	}
+	if post.Poll != nil {
+		for _, option := range post.Poll.Options {
+			if option.Image != "" {
+				keys = append(keys, option.Image)
+			}
+		}
+	}
+
+	for _, field := range []string{"images", "showcase.before_images", "showcase.after_images"} {
+		values, err := p.revisionCollection.Distinct(ctxTimeout, field, bson.M{"post_id": post.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get revision images: %w", err)
+		}
+		for _, v := range values {
+			if key, ok := v.(string); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+
+	return unique, nil
+}
+
+// Purge - permanently remove a post with its revisions and comments
+func (p *PostStorage) Purge(ctx context.Context, postID primitive.ObjectID) error {
+	client := p.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var purged Post
+		err := p.collection.FindOneAndDelete(sessCtx, bson.M{"_id": postID}).Decode(&purged)
+		switch {
+		case err == nil:
+			if err := p.removeFromProfile(sessCtx, purged.UserID, postID); err != nil {
+				return nil, err
+			}
+		case !errors.Is(err, mongo.ErrNoDocuments):
+			return nil, fmt.Errorf("failed to purge post: %w", err)
+		}
+
+		if _, err := p.revisionCollection.DeleteMany(sessCtx, bson.M{"post_id": postID}); err != nil {
+			return nil, fmt.Errorf("failed to purge post revisions: %w", err)
+		}
+
+		if _, err := p.likeCollection.DeleteMany(sessCtx, bson.M{"post_id": postID}); err != nil {
+			return nil, fmt.Errorf("failed to purge post likes: %w", err)
+		}
+
+		if _, err := p.pollVoteCollection.DeleteMany(sessCtx, bson.M{"post_id": postID}); err != nil {
+			return nil, fmt.Errorf("failed to purge poll votes: %w", err)
+		}
+
+		if _, err := p.answerVoteCollection.DeleteMany(sessCtx, bson.M{"post_id": postID}); err != nil {
+			return nil, fmt.Errorf("failed to purge answer votes: %w", err)
+		}
+
+		if _, err := p.timelineCollection.DeleteMany(sessCtx, bson.M{"post_id": postID}); err != nil {
+			return nil, fmt.Errorf("failed to purge timeline entries: %w", err)
+		}
+
+		commentIDs, err := p.commentCollection.Distinct(sessCtx, "_id", bson.M{"post_id": postID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find post comments: %w", err)
+		}
+
+		if _, err := p.commentCollection.DeleteMany(sessCtx, bson.M{"post_id": postID}); err != nil {
+			return nil, fmt.Errorf("failed to purge post comments: %w", err)
+		}
+
+		reactionFilter := bson.M{"$or": []bson.M{
+			{"target_type": ReactionOnPost, "target_id": postID},
+			{"target_type": ReactionOnComment, "target_id": bson.M{"$in": commentIDs}},
+		}}
+		if _, err := p.reactionCollection.DeleteMany(sessCtx, reactionFilter); err != nil {
+			return nil, fmt.Errorf("failed to purge post reactions: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// Delete - soft delete the comment, it no longer counts towards the post's comments
+func (c *CommentStorage) Delete(ctx context.Context, comment *Comment, deletedBy primitive.ObjectID) error {
+	client := c.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var deleted Comment
+		err := c.collection.FindOneAndUpdate(sessCtx,
+			bson.M{"_id": comment.ID, "deleted_at": notDeleted()},
+			bson.M{"$set": bson.M{"deleted_at": time.Now(), "deleted_by": deletedBy}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&deleted)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrCommentNotFound
+			}
+			return nil, fmt.Errorf("failed to delete comment: %w", err)
+		}
+
+		if _, err := c.postStorage.collection.UpdateByID(sessCtx, comment.PostID, bson.M{"$inc": bson.M{"comment_count": -1}}); err != nil {
+			return nil, fmt.Errorf("failed to decrement comment count: %w", err)
+		}
+
+		// a comment with shown replies stays in the thread as a tombstone
+		if deleted.ReplyCount == 0 {
+			if err := c.removeShownReply(sessCtx, deleted.ParentID); err != nil {
+				return nil, err
+			}
+		}
+
+		// a deleted accepted answer leaves the question unanswered
+		if comment.Accepted {
+			_, err := c.postStorage.collection.UpdateOne(sessCtx,
+				bson.M{"_id": comment.PostID, "accepted_answer_id": comment.ID},
+				bson.M{"$unset": bson.M{"accepted_answer_id": ""}},
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unaccept answer: %w", err)
+			}
+
+			if err := c.unaccept(sessCtx, &comment.ID); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// Restore - restore a soft-deleted comment of the user that is still within the retention window
+func (c *CommentStorage) Restore(ctx context.Context, commentID, userID primitive.ObjectID) error {
+	client := c.collection.Database().Client()
+
+	txnFunc := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		filter := bson.M{
+			"_id":        commentID,
+			"user_id":    userID,
+			"deleted_by": userID,
+			"deleted_at": bson.M{"$gte": time.Now().Add(-TrashRetention)},
+		}
+
+		update := bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}}
+
+		var comment Comment
+		err := c.collection.FindOneAndUpdate(sessCtx, filter, update).Decode(&comment)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, ErrCommentNotFound
+			}
+			return nil, fmt.Errorf("failed to restore comment: %w", err)
+		}
+
+		if _, err := c.postStorage.collection.UpdateByID(sessCtx, comment.PostID, bson.M{"$inc": bson.M{"comment_count": 1}}); err != nil {
+			return nil, fmt.Errorf("failed to increment comment count: %w", err)
+		}
+
+		// a comment with shown replies was still in the thread as a tombstone
+		if comment.ReplyCount == 0 {
+			if err := c.addShownReply(sessCtx, comment.ParentID); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return withTransaction(ctxTimeout, client, txnFunc)
+}
+
+// GetTrash - soft-deleted comments of the user that can still be restored, most recently deleted first
+func (c *CommentStorage) GetTrash(ctx context.Context, userID primitive.ObjectID) ([]Comment, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":    userID,
+		"deleted_by": userID,
+		"deleted_at": bson.M{"$gte": time.Now().Add(-TrashRetention)},
+	}
+
+	cursor, err := c.collection.Find(ctxTimeout, filter, options.Find().SetSort(bson.M{"deleted_at": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deleted comments: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	comments := make([]Comment, 0)
+	if err := cursor.All(ctxTimeout, &comments); err != nil {
+		return nil, fmt.Errorf("failed to decode comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// Purge - permanently remove comments deleted before the cutoff, returns how many were removed.
+// Comments that still have replies keep a tombstone without content so replies keep their parent
+func (c *CommentStorage) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	expired := bson.M{"deleted_at": bson.M{"$lt": cutoff}, "content": bson.M{"$ne": DeletedCommentContent}}
+
+	ids, err := c.collection.Distinct(ctxTimeout, "_id", expired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired comments: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	parentIDs, err := c.collection.Distinct(ctxTimeout, "parent_id", bson.M{"parent_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find comment replies: %w", err)
+	}
+
+	if len(parentIDs) > 0 {
+		_, err := c.collection.UpdateMany(ctxTimeout,
+			bson.M{"_id": bson.M{"$in": parentIDs}},
+			bson.M{
+				"$set":   bson.M{"content": DeletedCommentContent},
+				"$unset": bson.M{"mentions": "", "mention_entities": ""},
+			},
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to clear comments: %w", err)
+		}
+	}
+
+	result, err := c.collection.DeleteMany(ctxTimeout, bson.M{"_id": bson.M{"$in": ids, "$nin": parentIDs}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge comments: %w", err)
+	}
+
+	// tombstones keep their reactions out of view, purged comments drop them
+	reactionFilter := bson.M{"target_type": ReactionOnComment, "target_id": bson.M{"$in": ids, "$nin": parentIDs}}
+	if _, err := c.reactionCollection.DeleteMany(ctxTimeout, reactionFilter); err != nil {
+		return 0, fmt.Errorf("failed to purge comment reactions: %w", err)
+	}
+
+	if _, err := c.answerVoteCollection.DeleteMany(ctxTimeout, bson.M{"comment_id": bson.M{"$in": ids, "$nin": parentIDs}}); err != nil {
+		return 0, fmt.Errorf("failed to purge answer votes: %w", err)
+	}
+
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrStaleCursor = errors.New("cursor has expired, start from the first page")
+)
+
+// TrendingWindow - how far back trending posts are published
+type TrendingWindow string
+
+const (
+	TrendingDay   TrendingWindow = "24h"
+	TrendingWeek  TrendingWindow = "7d"
+	TrendingMonth TrendingWindow = "30d"
+)
+
+// trendingWindows - longer windows decay slower so older posts with lasting engagement can still rank
+var trendingWindows = map[TrendingWindow]struct {
+	duration time.Duration
+	gravity  float64
+}{
+	TrendingDay:   {duration: 24 * time.Hour, gravity: 1.8},
+	TrendingWeek:  {duration: 7 * 24 * time.Hour, gravity: 1.5},
+	TrendingMonth: {duration: 30 * 24 * time.Hour, gravity: 1.2},
+}
+
+// trendingScoreField - precomputed score of the window on the post
+func trendingScoreField(window TrendingWindow) string {
+	return "trending_scores." + string(window)
+}
+
+// RefreshTrendingScores - score posts published within the longest window,
+// score = (likes + 2 * comments + 3 * shares) / (age in hours + 2) ^ gravity.
+// Posts that fell out of every window lose their scores
+func (p *PostStorage) RefreshTrendingScores(ctx context.Context) error {
+	now := time.Now()
+
+	engagement := bson.M{"$add": bson.A{
+		"$like_count",
+		bson.M{"$multiply": bson.A{2, "$comment_count"}},
+		bson.M{"$multiply": bson.A{3, bson.M{"$ifNull": bson.A{"$share_count", 0}}}},
+	}}
+	ageHours := bson.M{"$max": bson.A{0, bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, "$published_at"}}, float64(time.Hour / time.Millisecond)}}}}
+
+	scores := bson.M{}
+	for window, w := range trendingWindows {
+		scores[string(window)] = bson.M{"$divide": bson.A{
+			"$engagement",
+			bson.M{"$pow": bson.A{bson.M{"$add": bson.A{"$age_hours", 2}}, w.gravity}},
+		}}
+	}
+
+	pipeline := mongo.Pipeline{
+		// reposts have no content of their own, the original trends instead
+		{{Key: "$match", Value: bson.M{
+			"status":       PostPublished,
+			"deleted_at":   notDeleted(),
+			"type":         bson.M{"$ne": PostRepost},
+			"published_at": bson.M{"$gte": now.Add(-trendingWindows[TrendingMonth].duration)},
+		}}},
+		{{Key: "$project", Value: bson.M{"engagement": engagement, "age_hours": ageHours}}},
+		{{Key: "$project", Value: bson.M{"trending_scores": scores, "trending_refreshed_at": now}}},
+		{{Key: "$merge", Value: bson.M{
+			"into": p.collection.Name(),
+			"on":   "_id",
+			"whenMatched": mongo.Pipeline{{{Key: "$set", Value: bson.M{
+				"trending_scores":       "$$new.trending_scores",
+				"trending_refreshed_at": "$$new.trending_refreshed_at",
+			}}}},
+			"whenNotMatched": "discard",
+		}}},
+	}
+
+	cursor, err := p.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to refresh trending scores: %w", err)
+	}
+	cursor.Close(ctx)
+
+	_, err = p.collection.UpdateMany(ctx,
+		bson.M{"trending_scores": bson.M{"$exists": true}, "trending_refreshed_at": bson.M{"$lt": now}},
+		bson.M{"$unset": bson.M{"trending_scores": "", "trending_refreshed_at": ""}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clear expired trending scores: %w", err)
+	}
+
+	return nil
+}
+
+// GetTrending - posts of the window by precomputed score, posts published since the last refresh aren't ranked yet
+func (p *PostStorage) GetTrending(ctx context.Context, user *User, cq CursorQuery) ([]PostWithLikeStatus, error) {
+	window := cq.Window
+	if window == "" {
+		window = TrendingWeek
+	}
+	field := trendingScoreField(window)
+
+	visible, err := p.visibilityCondition(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	andConditions := []bson.M{
+		{
+			"status":       PostPublished,
+			"deleted_at":   notDeleted(),
+			"published_at": bson.M{"$gte": time.Now().Add(-trendingWindows[window].duration)},
+			field:          bson.M{"$exists": true},
+		},
+		visible,
+	}
+
+	if len(cq.Roles) > 0 {
+		andConditions = append(andConditions, bson.M{"user_role": bson.M{"$in": cq.Roles}})
+	}
+
+	if len(cq.Tags) > 0 {
+		andConditions = append(andConditions, bson.M{"tags": bson.M{"$in": cq.Tags}})
+	}
+
+	if len(cq.Types) > 0 {
+		andConditions = append(andConditions, bson.M{"type": typeCondition(cq.Types)})
+	}
+
+	if cq.QuestionStatus != "" {
+		andConditions = append(andConditions, questionCondition(cq.QuestionStatus))
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	// scores change on every refresh, a cursor only pages within the refresh it was issued from
+	if cq.Cursor != "" && cq.Cursor != "undefined" {
+		scoreCursor, refreshedAt, err := parseTrendingCursor(cq.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		condition, err := trendingCursorCondition(scoreCursor, field)
+		if err != nil {
+			return nil, err
+		}
+
+		latest, err := p.latestTrendingRefresh(ctxTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if !latest.Equal(refreshedAt) {
+			return nil, ErrStaleCursor
+		}
+
+		andConditions = append(andConditions, condition, bson.M{"trending_refreshed_at": refreshedAt})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: field, Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(cq.Limit))
+
+	cursor, err := p.collection.Find(ctxTimeout, bson.M{"$and": andConditions}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trending posts: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	var posts []Post
+	if err := cursor.All(ctxTimeout, &posts); err != nil {
+		return nil, fmt.Errorf("failed to decode posts: %w", err)
+	}
+
+	return p.withLikeStatus(ctx, user, posts)
+}
+
+// latestTrendingRefresh - time of the last refresh, zero before the first one
+func (p *PostStorage) latestTrendingRefresh(ctx context.Context) (time.Time, error) {
+	opts := options.FindOne().
+		SetSort(bson.M{"trending_refreshed_at": -1}).
+		SetProjection(bson.M{"trending_refreshed_at": 1})
+
+	var post Post
+	err := p.collection.FindOne(ctx, bson.M{"trending_refreshed_at": bson.M{"$exists": true}}, opts).Decode(&post)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to find latest trending refresh: %w", err)
+	}
+
+	return *post.TrendingRefreshedAt, nil
+}
+
+// TrendingCursor - next cursor of a trending page, the score of the window, the post id and the refresh of the score
+func TrendingCursor(post Post, window TrendingWindow) string {
+	if window == "" {
+		window = TrendingWeek
+	}
+
+	var refreshedAt int64
+	if post.TrendingRefreshedAt != nil {
+		refreshedAt = post.TrendingRefreshedAt.UnixMilli()
+	}
+	return fmt.Sprintf("%s_%s_%d", strconv.FormatFloat(post.TrendingScores[window], 'g', -1, 64), post.ID.Hex(), refreshedAt)
+}
+
+// parseTrendingCursor - split the refresh of the scores off a trending cursor, the rest is a score cursor
+func parseTrendingCursor(cursor string) (string, time.Time, error) {
+	i := strings.LastIndex(cursor, "_")
+	if i < 0 {
+		return "", time.Time{}, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+
+	millis, err := strconv.ParseInt(cursor[i+1:], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return cursor[:i], time.UnixMilli(millis), nil
+}
+
+func trendingCursorCondition(cursor, field string) (bson.M, error) {
+	value, id, ok := strings.Cut(cursor, "_")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	cursorID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor ID: %w", err)
+	}
+
+	return bson.M{"$or": []bson.M{
+		{field: bson.M{"$lt": score}},
+		{field: score, "_id": bson.M{"$lt": cursorID}},
+	}}, nil
+}
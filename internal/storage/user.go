@@ -6,12 +6,14 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hnzhou16/project-cocraft-server/internal/security"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
@@ -21,16 +23,24 @@ var (
 )
 
 type User struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"` // omit the 0 generated during instantiating
-	Username  string             `json:"username" bson:"username"`
-	Email     string             `json:"email" bson:"email"`
-	Password  string             `json:"-" bson:"password"`
-	Role      security.Role      `json:"role" bson:"role"`
-	Profile   Profile            `json:"profile,omitempty" bson:"profile,omitempty"`
-	Rating    Rating             `json:"rating,omitempty" bson:"rating,omitempty"`
-	IsActive  bool               `json:"is_active" bson:"is_active"`
-	CreatedAt time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ID       primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"` // omit the 0 generated during instantiating
+	Username string             `json:"username" bson:"username"`
+	Email    string             `json:"email" bson:"email"`
+	Password string             `json:"-" bson:"password"`
+	Role     security.Role      `json:"role" bson:"role"`
+	Profile  Profile            `json:"profile,omitempty" bson:"profile,omitempty"`
+	Rating   Rating             `json:"rating,omitempty" bson:"rating,omitempty"`
+	IsActive bool               `json:"is_active" bson:"is_active"`
+	// kept with follows so large accounts can be read at request time instead of fanned out
+	FollowerCount int64 `json:"-" bson:"follower_count"`
+	// sum of the reputation ledger and badges of the last recompute, returned with the user stats
+	Reputation int64   `json:"-" bson:"reputation,omitempty"`
+	Badges     []Badge `json:"-" bson:"badges,omitempty"`
+	// resolved against the viewer's visibility in the profile, so not returned as is
+	PinnedPostIDs []primitive.ObjectID `json:"-" bson:"pinned_post_ids,omitempty"`
+	Portfolio     []PortfolioSection   `json:"-" bson:"portfolio,omitempty"`
+	CreatedAt     time.Time            `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time            `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
 
 type Profile struct {
@@ -83,16 +93,18 @@ func (u *UserStorage) Create(ctx context.Context, user *User) error {
 	}
 
 	result, err := u.collection.InsertOne(ctxTimeout, bson.M{
-		"_id":        user.ID,
-		"username":   user.Username,
-		"email":      user.Email,
-		"password":   user.Password,
-		"role":       user.Role,
-		"profile":    user.Profile,
-		"rating":     user.Rating,
-		"is_active":  user.IsActive,
-		"created_at": user.CreatedAt,
-		"updated_at": user.UpdatedAt,
+		"_id":            user.ID,
+		"username":       user.Username,
+		"username_lower": strings.ToLower(user.Username),
+		"email":          user.Email,
+		"password":       user.Password,
+		"role":           user.Role,
+		"profile":        user.Profile,
+		"rating":         user.Rating,
+		"is_active":      user.IsActive,
+		"follower_count": 0,
+		"created_at":     user.CreatedAt,
+		"updated_at":     user.UpdatedAt,
 	})
 
 	if err != nil {
@@ -230,28 +242,63 @@ func (u *UserStorage) GetByEmail(ctx context.Context, email string) (*User, erro
 	return &user, nil
 }
 
-func (u *UserStorage) ValidateUsername(ctx context.Context, usernames []string) ([]string, error) {
-	var validUsernames []string
+// GetIDsByUsernames - user id by username, unknown usernames are left out
+func (u *UserStorage) GetIDsByUsernames(ctx context.Context, usernames []string) (map[string]primitive.ObjectID, error) {
+	ids := make(map[string]primitive.ObjectID, len(usernames))
+	if len(usernames) == 0 {
+		return ids, nil
+	}
 
-	cursor, err := u.collection.Find(ctx, bson.M{
-		"username": bson.M{"$in": usernames},
-	})
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	opts := options.Find().SetProjection(bson.M{"username": 1})
+	cursor, err := u.collection.Find(ctxTimeout, bson.M{"username": bson.M{"$in": usernames}}, opts)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to find users by username: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	for cursor.Next(ctxTimeout) {
+		var user struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Username string             `bson:"username"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return nil, fmt.Errorf("failed to decode user: %w", err)
+		}
+		ids[user.Username] = user.ID
+	}
+
+	return ids, cursor.Err()
+}
+
+// getUsernames - username by user id, unknown ids are left out
+func (u *UserStorage) getUsernames(ctx context.Context, userIDs []primitive.ObjectID) (map[primitive.ObjectID]string, error) {
+	usernames := make(map[primitive.ObjectID]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return usernames, nil
+	}
+
+	opts := options.Find().SetProjection(bson.M{"username": 1})
+	cursor, err := u.collection.Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
 	}
 	defer cursor.Close(ctx)
 
 	for cursor.Next(ctx) {
 		var user struct {
-			Username string `json:"username" bson:"username"`
+			ID       primitive.ObjectID `bson:"_id"`
+			Username string             `bson:"username"`
 		}
 		if err := cursor.Decode(&user); err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to decode user: %w", err)
 		}
-		validUsernames = append(validUsernames, user.Username)
+		usernames[user.ID] = user.Username
 	}
 
-	return validUsernames, nil
+	return usernames, nil
 }
 
 func (u *UserStorage) AddRating(ctx context.Context, user *User, score int) error {
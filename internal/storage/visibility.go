@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PostVisibility - who can read a post besides its author
+type PostVisibility string
+
+const (
+	VisibilityPublic    PostVisibility = "public"
+	VisibilityFollowers PostVisibility = "followers" // followers of the author
+	VisibilityMentioned PostVisibility = "mentioned" // users mentioned in the post
+	VisibilityPrivate   PostVisibility = "private"   // unlisted, only holders of a share token
+)
+
+// visibilityCondition - posts the viewer can read, anonymous viewers only see public posts
+func (p *PostStorage) visibilityCondition(ctx context.Context, viewer *User) (bson.M, error) {
+	if viewer == nil {
+		return bson.M{"visibility": VisibilityPublic}, nil
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	followeeIDs, err := p.followCollection.Distinct(ctxTimeout, "followee_id", bson.M{"follower_id": viewer.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followed users: %w", err)
+	}
+
+	conditions := []bson.M{
+		{"visibility": VisibilityPublic},
+		{"user_id": viewer.ID},
+		{"visibility": VisibilityMentioned, "mentions": viewer.Username},
+	}
+	if len(followeeIDs) > 0 {
+		conditions = append(conditions, bson.M{"visibility": VisibilityFollowers, "user_id": bson.M{"$in": followeeIDs}})
+	}
+
+	return bson.M{"$or": conditions}, nil
+}
+
+func hashShareToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// SetShareToken - only the hash is stored, a new token replaces and revokes the previous one
+func (p *PostStorage) SetShareToken(ctx context.Context, postID primitive.ObjectID, token string) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := p.collection.UpdateOne(ctxTimeout,
+		bson.M{"_id": postID, "deleted_at": notDeleted()},
+		bson.M{"$set": bson.M{"share_token": hashShareToken(token)}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set share token: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrPostNotFound
+	}
+
+	return nil
+}
+
+func (p *PostStorage) RevokeShareToken(ctx context.Context, postID primitive.ObjectID) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := p.collection.UpdateOne(ctxTimeout,
+		bson.M{"_id": postID, "deleted_at": notDeleted()},
+		bson.M{"$unset": bson.M{"share_token": ""}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke share token: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrPostNotFound
+	}
+
+	return nil
+}
+
+// GetByShareToken - published post the token was issued for, whatever its visibility
+func (p *PostStorage) GetByShareToken(ctx context.Context, token string) (*Post, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{"share_token": hashShareToken(token), "status": PostPublished, "deleted_at": notDeleted()}
+
+	var post Post
+	if err := p.collection.FindOne(ctxTimeout, filter).Decode(&post); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrPostNotFound
+		}
+		return nil, fmt.Errorf("post query failed: %w", err)
+	}
+
+	return &post, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// getAnswerFromURL - top-level comment of the question in ctx by the {commentID} url param
+func (app *application) getAnswerFromURL(w http.ResponseWriter, r *http.Request) (*storage.Comment, bool) {
+	post := getPostFromCtx(r)
+
+	comment, ok := app.getCommentFromURL(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !storage.IsAnswer(post, comment) {
+		app.badRequestError(w, r, storage.ErrNotAnswer)
+		return nil, false
+	}
+
+	return comment, true
+}
+
+func (app *application) toggleUpvoteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	answer, ok := app.getAnswerFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	upvoted, err := app.storage.Comment.ToggleUpvote(r.Context(), user.ID, answer)
+	if err != nil {
+		app.answerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, upvoted)
+}
+
+// acceptAnswerHandler - only the asker can accept, accepting another answer replaces the previous one
+func (app *application) acceptAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenError(w, r, fmt.Errorf("only the asker can accept an answer"))
+		return
+	}
+
+	answer, ok := app.getAnswerFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.storage.Comment.AcceptAnswer(r.Context(), post, answer); err != nil {
+		app.answerError(w, r, err)
+		return
+	}
+
+	if post.AcceptedAnswerID != nil && *post.AcceptedAnswerID != answer.ID {
+		app.revokeReputation(r.Context(), storage.AnswerKey(*post.AcceptedAnswerID))
+	}
+	app.recordReputation(r.Context(), answer.UserID, storage.RepAnswerAccepted, storage.AnswerKey(answer.ID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) unacceptAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenError(w, r, fmt.Errorf("only the asker can unaccept an answer"))
+		return
+	}
+
+	answer, ok := app.getAnswerFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if post.AcceptedAnswerID == nil || *post.AcceptedAnswerID != answer.ID {
+		app.notFoundError(w, r, fmt.Errorf("answer is not accepted"))
+		return
+	}
+
+	if err := app.storage.Comment.UnacceptAnswer(r.Context(), post); err != nil {
+		app.answerError(w, r, err)
+		return
+	}
+
+	app.revokeReputation(r.Context(), storage.AnswerKey(answer.ID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) answerError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotQuestion), errors.Is(err, storage.ErrNotAnswer), errors.Is(err, storage.ErrOwnAnswer):
+		app.badRequestError(w, r, err)
+	case errors.Is(err, storage.ErrCommentNotFound):
+		app.notFoundError(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// attachAnswerVotes - mark the answers of a page the user has upvoted
+func (app *application) attachAnswerVotes(ctx context.Context, user *storage.User, answers []storage.CommentWithParentAndUser) error {
+	commentIDs := make([]primitive.ObjectID, 0, len(answers))
+	for _, answer := range answers {
+		commentIDs = append(commentIDs, answer.ID)
+	}
+
+	upvoted, err := app.storage.Comment.GetUpvotedAnswerIDs(ctx, user.ID, commentIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range answers {
+		answers[i].ViewerUpvoted = upvoted[answers[i].ID]
+	}
+
+	return nil
+}
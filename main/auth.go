@@ -115,6 +115,11 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 	app.logger.Infow("Email sent", "status code", status)
 
+	// the profile is only set at registration
+	if storage.ProfileCompleted(user.Profile) {
+		app.recordReputation(ctx, user.ID, storage.RepProfileCompleted, storage.ProfileKey(user.ID))
+	}
+
 	app.OutputJSON(w, http.StatusCreated, userWithToken)
 }
 
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CreateBoardPayload struct {
+	Name        string                  `json:"name" validate:"required,max=100"`
+	Description string                  `json:"description" validate:"omitempty,max=500"`
+	Visibility  storage.BoardVisibility `json:"visibility" validate:"required,oneof=public private collaborators"`
+}
+
+// UpdateBoardPayload - pointer fields, nil means the field is not updated
+type UpdateBoardPayload struct {
+	Name        *string                  `json:"name" validate:"omitempty,min=1,max=100"`
+	Description *string                  `json:"description" validate:"omitempty,max=500"`
+	Visibility  *storage.BoardVisibility `json:"visibility" validate:"omitempty,oneof=public private collaborators"`
+}
+
+type SaveBoardItemPayload struct {
+	PostID string `json:"post_id" validate:"required,hexadecimal,len=24"`
+	Note   string `json:"note" validate:"omitempty,max=500"`
+}
+
+type UpdateBoardItemPayload struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+type ReorderBoardItemsPayload struct {
+	ItemIDs []string `json:"item_ids" validate:"required,min=1,dive,hexadecimal,len=24"`
+}
+
+type InviteCollaboratorPayload struct {
+	UserID string `json:"user_id" validate:"required,hexadecimal,len=24"`
+}
+
+// BoardItemWithPost - post is nil when the saved post has been deleted
+type BoardItemWithPost struct {
+	Item storage.BoardItem           `json:"item"`
+	Post *storage.PostWithLikeStatus `json:"post"`
+}
+
+type boardItemsResponse struct {
+	Items      []BoardItemWithPost `json:"items"`
+	NextCursor *string             `json:"next_cursor"`
+}
+
+// attachSavedStatus - mark posts the user has saved into any board
+func (app *application) attachSavedStatus(ctx context.Context, user *storage.User, posts []storage.PostWithLikeStatus) error {
+	postIDs := make([]primitive.ObjectID, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.Post.ID)
+	}
+
+	saved, err := app.storage.Board.GetSavedPostIDs(ctx, user.ID, postIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].SavedByUser = saved[posts[i].Post.ID]
+	}
+
+	return nil
+}
+
+func (app *application) createBoardHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateBoardPayload
+	user := getUserFromCtx(r)
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	board := &storage.Board{
+		OwnerID:     user.ID,
+		Name:        payload.Name,
+		Description: payload.Description,
+		Visibility:  payload.Visibility,
+	}
+
+	if err := app.storage.Board.Create(r.Context(), board); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, board)
+}
+
+func (app *application) getBoardHandler(w http.ResponseWriter, r *http.Request) {
+	app.OutputJSON(w, http.StatusOK, getBoardFromCtx(r))
+}
+
+// getUserBoardsHandler - a user's own boards include the ones shared with them
+func (app *application) getUserBoardsHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := getUserFromCtx(r)
+	userID := chi.URLParam(r, "userID")
+	ctx := r.Context()
+
+	user, err := app.storage.User.GetByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	boards, err := app.storage.Board.GetByUserID(ctx, user.ID, viewer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, boards)
+}
+
+func (app *application) getBoardInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	boards, err := app.storage.Board.GetInvites(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, boards)
+}
+
+func (app *application) updateBoardHandler(w http.ResponseWriter, r *http.Request) {
+	board := getBoardFromCtx(r)
+	var payload UpdateBoardPayload
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.Name != nil {
+		board.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		board.Description = *payload.Description
+	}
+	if payload.Visibility != nil {
+		board.Visibility = *payload.Visibility
+	}
+
+	if err := app.storage.Board.Update(r.Context(), board); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, board)
+}
+
+func (app *application) deleteBoardHandler(w http.ResponseWriter, r *http.Request) {
+	board := getBoardFromCtx(r)
+
+	if err := app.storage.Board.Delete(r.Context(), board.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) getBoardItemsHandler(w http.ResponseWriter, r *http.Request) {
+	board := getBoardFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 50 {
+		limit = l
+	}
+
+	// cursor is the position of the last item on the previous page
+	var cursor *int
+	if c := r.URL.Query().Get("cursor"); c != "" && c != "undefined" {
+		position, err := strconv.Atoi(c)
+		if err != nil {
+			app.badRequestError(w, r, fmt.Errorf("invalid cursor: %w", err))
+			return
+		}
+		cursor = &position
+	}
+
+	items, err := app.storage.Board.GetItems(ctx, board.ID, cursor, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	postIDs := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		postIDs = append(postIDs, item.PostID)
+	}
+
+	posts, err := app.storage.Post.GetByIDs(ctx, postIDs, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	liked, err := app.storage.Post.GetLikedPostIDs(ctx, user.ID, postIDs)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	postsWithStatus := make([]storage.PostWithLikeStatus, 0, len(posts))
+	for _, post := range posts {
+		author, err := app.storage.User.GetByID(ctx, post.UserID.Hex())
+		if err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to fetch username for userID %s: %w", post.UserID.Hex(), err))
+			return
+		}
+		postsWithStatus = append(postsWithStatus, storage.PostWithLikeStatus{
+			Post:        post,
+			Username:    author.Username,
+			LikedByUser: liked[post.ID],
+		})
+	}
+
+	if err := app.attachViewerStatus(ctx, user, postsWithStatus); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	postMap := make(map[primitive.ObjectID]*storage.PostWithLikeStatus, len(postsWithStatus))
+	for i := range postsWithStatus {
+		postMap[postsWithStatus[i].Post.ID] = &postsWithStatus[i]
+	}
+
+	result := make([]BoardItemWithPost, 0, len(items))
+	for _, item := range items {
+		result = append(result, BoardItemWithPost{Item: item, Post: postMap[item.PostID]})
+	}
+
+	var nextCursor *string
+	if len(items) >= limit {
+		c := strconv.Itoa(items[len(items)-1].Position)
+		nextCursor = &c
+	}
+
+	app.OutputJSON(w, http.StatusOK, boardItemsResponse{
+		Items:      result,
+		NextCursor: nextCursor,
+	})
+}
+
+func (app *application) saveBoardItemHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SaveBoardItemPayload
+	board := getBoardFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	post, err := app.storage.Post.GetByID(ctx, payload.PostID, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// only published posts can be saved
+	if post.Status != storage.PostPublished {
+		app.notFoundError(w, r, storage.ErrPostNotFound)
+		return
+	}
+
+	item := &storage.BoardItem{
+		BoardID: board.ID,
+		PostID:  post.ID,
+		SavedBy: user.ID,
+		Note:    payload.Note,
+	}
+
+	if err := app.storage.Board.AddItem(ctx, item); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrAlreadySaved):
+			app.conflictError(w, r, "ALREADY_SAVED", err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, item)
+}
+
+func (app *application) updateBoardItemHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateBoardItemPayload
+	board := getBoardFromCtx(r)
+	ctx := r.Context()
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	item, err := app.storage.Board.GetItem(ctx, board.ID, chi.URLParam(r, "itemID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrBoardItemNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	item.Note = payload.Note
+
+	if err := app.storage.Board.UpdateItemNote(ctx, item); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, item)
+}
+
+func (app *application) removeBoardItemHandler(w http.ResponseWriter, r *http.Request) {
+	board := getBoardFromCtx(r)
+	ctx := r.Context()
+
+	item, err := app.storage.Board.GetItem(ctx, board.ID, chi.URLParam(r, "itemID"))
+	if err == nil {
+		err = app.storage.Board.RemoveItem(ctx, item)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrBoardItemNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) reorderBoardItemsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ReorderBoardItemsPayload
+	board := getBoardFromCtx(r)
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	seen := make(map[primitive.ObjectID]bool, len(payload.ItemIDs))
+	itemIDs := make([]primitive.ObjectID, 0, len(payload.ItemIDs))
+	for _, id := range payload.ItemIDs {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil || seen[objID] {
+			app.badRequestError(w, r, fmt.Errorf("invalid or duplicate item id %s", id))
+			return
+		}
+		seen[objID] = true
+		itemIDs = append(itemIDs, objID)
+	}
+
+	if err := app.storage.Board.Reorder(r.Context(), board.ID, itemIDs); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrBoardItemNotFound):
+			app.badRequestError(w, r, fmt.Errorf("item_ids must list every item of the board: %w", err))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) inviteCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload InviteCollaboratorPayload
+	board := getBoardFromCtx(r)
+	ctx := r.Context()
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if board.Visibility == storage.BoardPrivate {
+		app.badRequestError(w, r, fmt.Errorf("private boards can not have collaborators"))
+		return
+	}
+
+	invitee, err := app.storage.User.GetByID(ctx, payload.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if invitee.ID == board.OwnerID {
+		app.badRequestError(w, r, fmt.Errorf("you cannot invite yourself"))
+		return
+	}
+
+	if err := app.storage.Board.Invite(ctx, board.ID, invitee.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) acceptBoardInviteHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondBoardInvite(w, r, true)
+}
+
+func (app *application) declineBoardInviteHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondBoardInvite(w, r, false)
+}
+
+func (app *application) respondBoardInvite(w http.ResponseWriter, r *http.Request, accept bool) {
+	board := getBoardFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := app.storage.Board.RespondInvite(r.Context(), board.ID, user.ID, accept); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInviteNotPending):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeCollaboratorHandler - the owner removes a collaborator, or a collaborator leaves the board
+func (app *application) removeCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	board := getBoardFromCtx(r)
+	user := getUserFromCtx(r)
+
+	collaboratorID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "userID"))
+	if err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	if user.ID != board.OwnerID && user.ID != collaboratorID {
+		app.forbiddenError(w, r, fmt.Errorf("only the board owner can remove collaborators"))
+		return
+	}
+
+	if err := app.storage.Board.RemoveCollaborator(r.Context(), board.ID, collaboratorID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"github.com/go-chi/chi/v5"
 	"github.com/hnzhou16/project-cocraft-server/internal/storage"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
+	"time"
 )
 
 type CreateCommentPayload struct {
@@ -13,6 +15,16 @@ type CreateCommentPayload struct {
 	Content  string `json:"content" validate:"required,max=500"`
 }
 
+type commentThreadResponse struct {
+	Comments     []storage.CommentWithParentAndUser `json:"comments"`
+	CommentCount int64                              `json:"comment_count"` // all comments on the post
+	NextCursor   *string                            `json:"next_cursor"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=500"`
+}
+
 func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
 	var payload CreateCommentPayload
 	ctx := r.Context()
@@ -30,10 +42,18 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 	user := getUserFromCtx(r)
 	post := getPostFromCtx(r)
 
+	mentions, mentionEntities, err := app.resolveMentions(ctx, payload.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	comment := &storage.Comment{
-		UserID:  user.ID,
-		PostID:  post.ID,
-		Content: payload.Content,
+		UserID:          user.ID,
+		PostID:          post.ID,
+		Content:         payload.Content,
+		Mentions:        mentions,
+		MentionEntities: mentionEntities,
 	}
 
 	// use "" to check empty string rather than nil
@@ -44,7 +64,7 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
-		_, err = app.storage.Comment.Exists(ctx, objID)
+		parent, err := app.storage.Comment.GetByID(ctx, objID)
 		if err != nil {
 			switch {
 			case errors.Is(err, storage.ErrCommentNotFound):
@@ -56,7 +76,18 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 			}
 		}
 
+		if parent.PostID != post.ID {
+			app.notFoundError(w, r, storage.ErrCommentNotFound)
+			return
+		}
+
+		if parent.Depth+1 > app.config.commentConfig.maxDepth {
+			app.badRequestError(w, r, fmt.Errorf("replies can only be nested %d levels deep", app.config.commentConfig.maxDepth))
+			return
+		}
+
 		comment.ParentID = &objID
+		comment.Depth = parent.Depth + 1
 	}
 
 	commentWithData, err := app.storage.Comment.Create(ctx, comment)
@@ -65,26 +96,226 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if post.UserID != user.ID {
+		app.recordReputation(ctx, post.UserID, storage.RepCommentReceived, storage.CommentKey(comment.ID))
+	}
+
 	app.OutputJSON(w, http.StatusCreated, commentWithData)
 }
 
+// getCommentHandler - a page of top-level comments, replies are paged per comment
+// answers of a question are sorted by votes unless another sort is asked for
 func (app *application) getCommentHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := app.storage.Comment.GetByPostID(r.Context(), post.ID)
+	cq, ok := app.parseThreadQuery(w, r)
+	if !ok {
+		return
+	}
+
+	if post.Question && r.URL.Query().Get("sort") == "" {
+		cq.CommentSort = storage.CommentVotes
+	}
+
+	comments, err := app.storage.Comment.GetTopLevel(r.Context(), post.ID, cq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	// !!! can simply return post.CommentCount instead of fetching data again
-	commentsWithCount := struct {
-		Comments     []storage.CommentWithParentAndUser `json:"comments"`
-		CommentCount int64                              `json:"comment_count"`
-	}{
+	if err := app.attachCommentReactions(r.Context(), getUserFromCtx(r), comments); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if post.Question {
+		if err := app.attachAnswerVotes(r.Context(), getUserFromCtx(r), comments); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.outputThread(w, comments, cq, post.CommentCount)
+}
+
+func (app *application) getCommentRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	commentID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "commentID"))
+	if err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid comment ID"))
+		return
+	}
+
+	cq, ok := app.parseThreadQuery(w, r)
+	if !ok {
+		return
+	}
+
+	replies, err := app.storage.Comment.GetReplies(r.Context(), post.ID, commentID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.attachCommentReactions(r.Context(), getUserFromCtx(r), replies); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.outputThread(w, replies, cq, post.CommentCount)
+}
+
+func (app *application) parseThreadQuery(w http.ResponseWriter, r *http.Request) (storage.CursorQuery, bool) {
+	cq := storage.CursorQuery{
+		Limit:       10,
+		Sort:        "desc",
+		CommentSort: storage.CommentNewest,
+	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return cq, false
+	}
+
+	// threads have their own sorts, the feed's sort=asc means oldest first
+	if cq.Sort == "asc" {
+		cq.CommentSort = storage.CommentOldest
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return cq, false
+	}
+
+	return cq, true
+}
+
+func (app *application) outputThread(w http.ResponseWriter, comments []storage.CommentWithParentAndUser, cq storage.CursorQuery, commentCount int64) {
+	// !!! return cursor only if len(comments) >= limit
+	var nextCursor *string
+	if len(comments) >= cq.Limit {
+		cursor := storage.ThreadCursor(comments[len(comments)-1], cq.CommentSort)
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, commentThreadResponse{
 		Comments:     comments,
-		CommentCount: post.CommentCount,
+		CommentCount: commentCount,
+		NextCursor:   nextCursor,
+	})
+}
+
+// getCommentFromURL - comment of the post in ctx by the {commentID} url param
+func (app *application) getCommentFromURL(w http.ResponseWriter, r *http.Request) (*storage.Comment, bool) {
+	post := getPostFromCtx(r)
+
+	commentID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "commentID"))
+	if err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid comment ID"))
+		return nil, false
+	}
+
+	comment, err := app.storage.Comment.GetByID(r.Context(), commentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrCommentNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if comment.PostID != post.ID {
+		app.notFoundError(w, r, storage.ErrCommentNotFound)
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// updateCommentHandler - only the author can edit, within the edit window after creating the comment
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload UpdateCommentPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	comment, ok := app.getCommentFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != user.ID {
+		app.forbiddenError(w, r, fmt.Errorf("only the author can edit the comment"))
+		return
+	}
+
+	if time.Since(comment.CreatedAt) > app.config.commentConfig.editWindow {
+		app.forbiddenError(w, r, fmt.Errorf("comments can only be edited within %s", app.config.commentConfig.editWindow))
+		return
+	}
+
+	mentions, mentionEntities, err := app.resolveMentions(r.Context(), payload.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	comment.Content = payload.Content
+	comment.Mentions = mentions
+	comment.MentionEntities = mentionEntities
+	if err := app.storage.Comment.Update(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrCommentNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, comment)
+}
+
+// deleteCommentHandler - the author or the post owner can delete, only the author can restore it from the trash
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	comment, ok := app.getCommentFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != user.ID && post.UserID != user.ID {
+		app.forbiddenError(w, r, fmt.Errorf("only the author or the post owner can delete the comment"))
+		return
+	}
+
+	if err := app.storage.Comment.Delete(r.Context(), comment, user.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrCommentNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.revokeReputation(r.Context(), storage.CommentKey(comment.ID))
+	if comment.Accepted {
+		app.revokeReputation(r.Context(), storage.AnswerKey(comment.ID))
 	}
 
-	app.OutputJSON(w, http.StatusOK, commentsWithCount)
+	w.WriteHeader(http.StatusNoContent)
 }
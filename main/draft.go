@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+)
+
+var errInvalidPublishing = errors.New("invalid publishing")
+
+// applyPublishing - set the status of a post, published posts cannot go back to draft or scheduled
+func applyPublishing(post *storage.Post, status storage.PostStatus, publishAt *time.Time) error {
+	if post.Status == storage.PostPublished && status != storage.PostPublished {
+		return fmt.Errorf("%w: published posts cannot be unpublished", errInvalidPublishing)
+	}
+
+	switch status {
+	case storage.PostScheduled:
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return fmt.Errorf("%w: scheduled posts need a publish_at in the future", errInvalidPublishing)
+		}
+		post.PublishAt = publishAt
+	case storage.PostDraft:
+		if publishAt != nil {
+			return fmt.Errorf("%w: publish_at is only allowed for scheduled posts", errInvalidPublishing)
+		}
+		post.PublishAt = nil
+	case storage.PostPublished:
+		if publishAt != nil {
+			return fmt.Errorf("%w: publish_at is only allowed for scheduled posts", errInvalidPublishing)
+		}
+		if post.Status != storage.PostPublished {
+			now := time.Now()
+			post.PublishedAt = &now
+		}
+		post.PublishAt = nil
+	}
+
+	post.Status = status
+	return nil
+}
+
+// getDraftsHandler - draft and scheduled posts of the current user, only visible to the author
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	cq := storage.CursorQuery{
+		Limit: 10,
+		Sort:  "desc",
+	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	drafts, err := app.storage.Post.GetDrafts(ctx, user.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	posts := make([]storage.PostWithLikeStatus, 0, len(drafts))
+	for i := range drafts {
+		if err := app.s3KeysToUrl(ctx, &drafts[i]); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		posts = append(posts, storage.PostWithLikeStatus{Post: drafts[i], Username: user.Username})
+	}
+
+	var nextCursor *string
+	if len(posts) >= cq.Limit {
+		cursor := posts[len(posts)-1].Post.ID.Hex()
+		nextCursor = &cursor
+	}
+
+	response := feedResponse{
+		PostsWithStatus: posts,
+		NextCursor:      nextCursor,
+	}
+
+	app.OutputJSON(w, http.StatusOK, response)
+}
+
+// publishPostHandler - publish a draft or scheduled post immediately
+func (app *application) publishPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	publishedAt, err := app.storage.Post.Publish(r.Context(), post.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostPublished):
+			app.conflictError(w, r, "ALREADY_PUBLISHED", err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post.Status = storage.PostPublished
+	post.PublishAt = nil
+	post.PublishedAt = &publishedAt
+
+	app.fanOutPost(r.Context(), post.ID)
+
+	app.OutputJSON(w, http.StatusOK, post)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/hnzhou16/project-cocraft-server/internal/storage"
 	"net/http"
@@ -43,6 +45,16 @@ func (app *application) getPublicFeedHandler(w http.ResponseWriter, r *http.Requ
 		posts[i].Username = user.Username
 	}
 
+	if err := app.attachSharedPosts(ctx, nil, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.attachPollStatus(ctx, nil, feedPolls(posts)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	// do not allow infinite scroll for public feed
 	response := feedResponse{
 		PostsWithStatus: posts,
@@ -66,25 +78,16 @@ func (app *application) getFeedHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if cq.ShowFollowing == true {
-		followeeIDs, err := app.storage.Follow.GetFollowing(ctx, user.ID)
-		if err != nil {
-			app.internalServerError(w, r, fmt.Errorf("failed to get following user: %w", err))
-			return
-		}
-
-		// ! if user not following anyone, should avoid passing into the query
-		if len(followeeIDs) == 0 {
-			app.OutputJSON(w, http.StatusOK, nil)
-		}
-		cq.FolloweeIDs = followeeIDs
-	}
-
 	if err := Validate.Struct(cq); err != nil {
 		app.badRequestError(w, r, err)
 		return
 	}
 
+	if cq.ShowFollowing {
+		app.getTimelineFeed(w, r, cq)
+		return
+	}
+
 	posts, err := app.storage.Post.GetFeed(ctx, user, cq)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -101,10 +104,15 @@ func (app *application) getFeedHandler(w http.ResponseWriter, r *http.Request) {
 		posts[i].Username = user.Username
 	}
 
+	if err := app.attachViewerStatus(ctx, user, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	// !!! return cursor only if len(posts) >= limit, avoiding infinite loop of infinite scrolling if here is just 1 post
 	var nextCursor *string
 	if len(posts) >= cq.Limit {
-		cursor := posts[len(posts)-1].Post.ID.Hex()
+		cursor := storage.PostCursor(posts[len(posts)-1].Post)
 		nextCursor = &cursor
 	}
 
@@ -116,13 +124,58 @@ func (app *application) getFeedHandler(w http.ResponseWriter, r *http.Request) {
 	app.OutputJSON(w, http.StatusOK, response)
 }
 
+// getTimelineFeed - following feed read from the precomputed home timeline, the cursor is opaque
+func (app *application) getTimelineFeed(w http.ResponseWriter, r *http.Request, cq storage.CursorQuery) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	// followed tags are read at request time
+	followedTags, err := app.storage.Tag.GetFollowed(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	cq.FollowedTags = followedTags
+
+	posts, cursor, err := app.storage.Timeline.Get(ctx, user, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i, post := range posts {
+		author, err := app.storage.User.GetByID(ctx, post.Post.UserID.Hex())
+		if err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to fetch username for userID %s: %w", post.Post.UserID.Hex(), err))
+			return
+		}
+		posts[i].Username = author.Username
+	}
+
+	if err := app.attachViewerStatus(ctx, user, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if cursor != "" {
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, feedResponse{
+		PostsWithStatus: posts,
+		NextCursor:      nextCursor,
+	})
+}
+
 func (app *application) getTrendingHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := getUserFromCtx(r)
 
 	cq := storage.CursorQuery{
-		Limit: 10,
-		Sort:  "desc",
+		Limit:  10,
+		Sort:   "desc",
+		Window: storage.TrendingWeek,
 	}
 
 	if err := cq.Parse(r); err != nil {
@@ -137,7 +190,12 @@ func (app *application) getTrendingHandler(w http.ResponseWriter, r *http.Reques
 
 	posts, err := app.storage.Post.GetTrending(ctx, user, cq)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, storage.ErrStaleCursor):
+			app.conflictError(w, r, "STALE_CURSOR", err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
@@ -151,9 +209,15 @@ func (app *application) getTrendingHandler(w http.ResponseWriter, r *http.Reques
 		posts[i].Username = user.Username
 	}
 
+	if err := app.attachViewerStatus(ctx, user, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// trending pages continue after the score of the last post
 	var nextCursor *string
 	if len(posts) >= cq.Limit {
-		cursor := posts[len(posts)-1].Post.ID.Hex()
+		cursor := storage.TrendingCursor(posts[len(posts)-1].Post, cq.Window)
 		nextCursor = &cursor
 	}
 
@@ -165,7 +229,8 @@ func (app *application) getTrendingHandler(w http.ResponseWriter, r *http.Reques
 	app.OutputJSON(w, http.StatusOK, response)
 }
 
-func (app *application) getSearchHandler(w http.ResponseWriter, r *http.Request) {
+// getForYouFeedHandler - ranked feed mode, the cursor names the server-side ranking session
+func (app *application) getForYouFeedHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := getUserFromCtx(r)
 
@@ -173,6 +238,82 @@ func (app *application) getSearchHandler(w http.ResponseWriter, r *http.Request)
 		Limit: 10,
 		Sort:  "desc",
 	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	followeeIDs, err := app.storage.Follow.GetFollowing(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("failed to get following user: %w", err))
+		return
+	}
+
+	followedTags, err := app.storage.Tag.GetFollowed(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	cq.FolloweeIDs = followeeIDs
+	cq.FollowedTags = followedTags
+
+	posts, cursor, err := app.storage.Post.GetForYou(ctx, user, cq)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrStaleCursor):
+			app.conflictError(w, r, "STALE_CURSOR", err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for i, post := range posts {
+		author, err := app.storage.User.GetByID(ctx, post.Post.UserID.Hex())
+		if err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to fetch username for userID %s: %w", post.Post.UserID.Hex(), err))
+			return
+		}
+		posts[i].Username = author.Username
+	}
+
+	if err := app.attachViewerStatus(ctx, user, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if cursor != "" {
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, feedResponse{
+		PostsWithStatus: posts,
+		NextCursor:      nextCursor,
+	})
+}
+
+// refreshTrendingScores - rescore posts of the trending windows
+func (app *application) refreshTrendingScores(ctx context.Context) error {
+	return app.storage.Post.RefreshTrendingScores(ctx)
+}
+
+func (app *application) getSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	cq := storage.CursorQuery{
+		Limit:      10,
+		Sort:       "desc",
+		SearchSort: storage.SearchRelevance,
+	}
 	if err := cq.Parse(r); err != nil {
 		app.badRequestError(w, r, err)
 		return
@@ -188,31 +329,43 @@ func (app *application) getSearchHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	posts, err := app.storage.Post.Search(ctx, user, cq.Search, cq)
+	response, err := app.searchPosts(ctx, user, cq)
 	if err != nil {
-		app.internalServerError(w, r, fmt.Errorf("search error: %w", err))
+		app.internalServerError(w, r, err)
 		return
 	}
 
+	app.OutputJSON(w, http.StatusOK, response)
+}
+
+// searchPosts - a page of posts matching cq.Search with usernames and viewer status attached
+func (app *application) searchPosts(ctx context.Context, user *storage.User, cq storage.CursorQuery) (*feedResponse, error) {
+	posts, err := app.storage.Post.Search(ctx, user, cq.Search, cq)
+	if err != nil {
+		return nil, fmt.Errorf("search error: %w", err)
+	}
+
 	for i, post := range posts {
 		u, err := app.storage.User.GetByID(ctx, post.Post.UserID.Hex())
 		if err != nil {
-			app.internalServerError(w, r, fmt.Errorf("failed to fetch username for userID %s: %w", post.Post.UserID.Hex(), err))
-			return
+			return nil, fmt.Errorf("failed to fetch username for userID %s: %w", post.Post.UserID.Hex(), err)
 		}
 		posts[i].Username = u.Username
 	}
 
+	if err := app.attachViewerStatus(ctx, user, posts); err != nil {
+		return nil, err
+	}
+
+	// relevance pages continue after the score of the last post, recent pages after its id
 	var nextCursor *string
 	if len(posts) >= cq.Limit {
-		cursor := posts[len(posts)-1].Post.ID.Hex()
+		cursor := storage.SearchCursor(posts[len(posts)-1].Post, cq.SearchSort)
 		nextCursor = &cursor
 	}
 
-	response := feedResponse{
+	return &feedResponse{
 		PostsWithStatus: posts,
 		NextCursor:      nextCursor,
-	}
-
-	app.OutputJSON(w, http.StatusOK, response)
+	}, nil
 }
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var allowedExtensions = map[string]bool{
@@ -16,6 +19,11 @@ var allowedExtensions = map[string]bool{
 	"webp": true,
 }
 
+// allowedDocumentExtensions - product spec sheets and shared project documents
+var allowedDocumentExtensions = map[string]bool{
+	"pdf": true,
+}
+
 type PresignURLResponse struct {
 	UploadURL string `json:"upload_url"`
 	S3Key     string `json:"s3_key"`
@@ -35,7 +43,10 @@ func (app *application) generateUploadURLHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if _, valid := allowedExtensions[req.Extension]; !valid {
+	_, validImage := allowedExtensions[req.Extension]
+	_, validDocument := allowedDocumentExtensions[req.Extension]
+
+	if !validImage && !validDocument {
 		app.badRequestError(w, r, fmt.Errorf("extension '%s' is not allowed", req.Extension))
 		return
 	}
@@ -62,7 +73,6 @@ func (app *application) generateUploadURLHandler(w http.ResponseWriter, r *http.
 func (app *application) deleteImageHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user := getUserFromCtx(r)
-	userIDStr := user.ID.Hex()
 
 	var req struct {
 		ObjectKey string `json:"key"`
@@ -76,7 +86,7 @@ func (app *application) deleteImageHandler(w http.ResponseWriter, r *http.Reques
 	log.Println(req.ObjectKey)
 
 	// check if key starts with user's folder
-	if !strings.HasPrefix(req.ObjectKey, fmt.Sprintf("user_uploads/%s/", userIDStr)) {
+	if !ownsS3Key(user.ID, req.ObjectKey) {
 		app.unauthorizedError(w, r, errors.New("object key is not the correct format"))
 		return
 	}
@@ -89,3 +99,26 @@ func (app *application) deleteImageHandler(w http.ResponseWriter, r *http.Reques
 
 	app.OutputJSON(w, http.StatusOK, nil)
 }
+
+// ownsS3Key - uploaded objects are stored under the uploader's folder
+func ownsS3Key(userID primitive.ObjectID, key string) bool {
+	return strings.HasPrefix(key, fmt.Sprintf("user_uploads/%s/", userID.Hex()))
+}
+
+// presignS3Keys - turn s3 object keys into time-limited urls
+func (app *application) presignS3Keys(ctx context.Context, keys []string) ([]string, error) {
+	urls := make([]string, len(keys))
+	for i, key := range keys {
+		url, err := app.awsPresigner.GetImageURL(
+			ctx,
+			key,
+			app.config.awsConfig.exp,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image url: %w", err)
+		}
+		urls[i] = url
+	}
+
+	return urls, nil
+}
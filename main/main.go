@@ -63,6 +63,17 @@ func main() {
 			imageNumber: 1,
 			imageSize:   "1024x1024",
 		},
+		commentConfig: commentConfig{
+			editWindow: time.Duration(env.GetInt("COMMENT_EDIT_WINDOW", 15)) * time.Minute,
+			maxDepth:   env.GetInt("COMMENT_MAX_DEPTH", 5),
+		},
+		schedulerConfig: schedulerConfig{
+			publishInterval:    time.Duration(env.GetInt("PUBLISH_INTERVAL", 60)) * time.Second,
+			purgeInterval:      time.Duration(env.GetInt("TRASH_PURGE_INTERVAL", 3600)) * time.Second,
+			tagInterval:        time.Duration(env.GetInt("TAG_REFRESH_INTERVAL", 600)) * time.Second,
+			reputationInterval: time.Duration(env.GetInt("REPUTATION_RECOMPUTE_INTERVAL", 3600)) * time.Second,
+			trendingInterval:   time.Duration(env.GetInt("TRENDING_REFRESH_INTERVAL", 300)) * time.Second,
+		},
 	}
 
 	// initialize logger
@@ -94,6 +105,12 @@ func main() {
 	}
 	logger.Info("✅ Indexes ensured for all collections")
 
+	// Run data migrations
+	if err := storage.Migrate(ctx, s); err != nil {
+		logger.Fatal("❌ Failed to migrate data: %v", err)
+	}
+	logger.Info("✅ Data migrations completed")
+
 	// Initialize Mailer
 	mailerSendgrid := mailer.NewSendgrid(cfg.mailConfig.apiKey, cfg.mailConfig.fromEmail)
 
@@ -126,6 +143,9 @@ func main() {
 	// Start the server in a goroutine
 	server := app.run(mux)
 
+	// Start background jobs
+	jobScheduler := app.startScheduler(app.jobs())
+
 	// Gracefully shutdown
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
@@ -141,5 +161,8 @@ func main() {
 		logger.Info("⚠️ Error during server shutdown: %v", err)
 	}
 
+	// Stop background jobs after in-flight requests are done
+	jobScheduler.stop()
+
 	logger.Info("✅ Server gracefully stopped.")
 }
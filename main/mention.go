@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+)
+
+var mentionRegexp = regexp.MustCompile(`@([a-zA-Z0-9_]+)`)
+
+// extractMentions - every @username in the text with its byte offsets, not validated yet
+func extractMentions(text string) []storage.Mention {
+	// '-1' means no limit, return all matches
+	// [[start, end, usernameStart, usernameEnd], ...]
+	matches := mentionRegexp.FindAllStringSubmatchIndex(text, -1)
+
+	var mentions []storage.Mention
+	for _, match := range matches {
+		mentions = append(mentions, storage.Mention{
+			Username: text[match[2]:match[3]],
+			Start:    match[0],
+			End:      match[1],
+		})
+	}
+
+	return mentions
+}
+
+// resolveMentions - mentions of existing users only
+// returns the unique usernames used by the "mentioned me" filter and the entities used to render links
+func (app *application) resolveMentions(ctx context.Context, text string) ([]string, []storage.Mention, error) {
+	found := extractMentions(text)
+	if len(found) == 0 {
+		return nil, nil, nil
+	}
+
+	usernames := make([]string, 0, len(found))
+	for _, mention := range found {
+		usernames = append(usernames, mention.Username)
+	}
+
+	userIDs, err := app.storage.User.GetIDsByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var mentioned []string
+	var entities []storage.Mention
+	seen := make(map[string]bool)
+	for _, mention := range found {
+		userID, ok := userIDs[mention.Username]
+		if !ok {
+			continue
+		}
+
+		mention.UserID = userID
+		entities = append(entities, mention)
+
+		if !seen[mention.Username] {
+			seen[mention.Username] = true
+			mentioned = append(mentioned, mention.Username)
+		}
+	}
+
+	return mentioned, entities, nil
+}
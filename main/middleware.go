@@ -15,8 +15,11 @@ import (
 type ctxKey string
 
 const (
-	userCtx ctxKey = "user"
-	postCtx ctxKey = "post"
+	userCtx    ctxKey = "user"
+	postCtx    ctxKey = "post"
+	productCtx ctxKey = "product"
+	boardCtx   ctxKey = "board"
+	projectCtx ctxKey = "project"
 )
 
 // Middleware wraps an HTTP handler, modifying the request(r) or response(w) before passing control to next handler
@@ -68,7 +71,7 @@ func (app *application) postCtxtMiddleware(next http.Handler) http.Handler {
 		postID := chi.URLParam(r, "postID")
 		ctx := r.Context()
 
-		post, err := app.storage.Post.GetByID(ctx, postID)
+		post, err := app.storage.Post.GetByID(ctx, postID, getUserFromCtx(r))
 		if err != nil {
 			switch {
 			case errors.Is(err, storage.ErrPostNotFound):
@@ -80,11 +83,100 @@ func (app *application) postCtxtMiddleware(next http.Handler) http.Handler {
 			}
 		}
 
+		// drafts and scheduled posts are only visible to the author
+		if post.Status != storage.PostPublished && post.UserID != getUserFromCtx(r).ID {
+			app.notFoundError(w, r, storage.ErrPostNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// productCtxMiddleware - add product to ctx
+func (app *application) productCtxMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		productID := chi.URLParam(r, "productID")
+		ctx := r.Context()
+
+		product, err := app.storage.Product.GetByID(ctx, productID)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrProductNotFound):
+				app.notFoundError(w, r, err)
+				return
+			default:
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		ctx = context.WithValue(ctx, productCtx, product)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// boardCtxMiddleware - add board to ctx, boards the user can't see are reported as not found
+func (app *application) boardCtxMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		boardID := chi.URLParam(r, "boardID")
+		user := getUserFromCtx(r)
+		ctx := r.Context()
+
+		board, err := app.storage.Board.GetByID(ctx, boardID)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrBoardNotFound):
+				app.notFoundError(w, r, err)
+				return
+			default:
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		// invited users can look at the board before accepting
+		if !board.CanView(user.ID) && !board.IsInvited(user.ID) {
+			app.notFoundError(w, r, storage.ErrBoardNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, boardCtx, board)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// projectCtxMiddleware - add project to ctx, only members can access a project workspace
+func (app *application) projectCtxMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		projectID := chi.URLParam(r, "projectID")
+		user := getUserFromCtx(r)
+		ctx := r.Context()
+
+		project, err := app.storage.Project.GetByID(ctx, projectID)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrProjectNotFound):
+				app.notFoundError(w, r, err)
+				return
+			default:
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		// don't reveal projects to non-members
+		if project.Member(user.ID) == nil {
+			app.notFoundError(w, r, storage.ErrProjectNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, projectCtx, project)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // RequirePermission return type is a middleware function -> func(http.Handler) http.Handler
 func (app *application) RequirePermission(required security.Permission) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -115,6 +207,66 @@ func (app *application) RequirePostOwnership(next http.Handler) http.Handler {
 	})
 }
 
+func (app *application) RequireProductOwnership(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		product := getProductFromCtx(r)
+
+		if user.ID != product.ManufacturerID {
+			app.forbiddenError(w, r, fmt.Errorf("user does not own product"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) RequireBoardOwnership(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		board := getBoardFromCtx(r)
+
+		if user.ID != board.OwnerID {
+			app.forbiddenError(w, r, fmt.Errorf("user does not own board"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireBoardEdit - owner or collaborator
+func (app *application) RequireBoardEdit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		board := getBoardFromCtx(r)
+
+		if !board.CanEdit(user.ID) {
+			app.forbiddenError(w, r, fmt.Errorf("user can not edit board"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireProjectRole - must be used after projectCtxMiddleware
+func (app *application) RequireProjectRole(required storage.ProjectRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromCtx(r)
+			member := getProjectFromCtx(r).Member(user.ID)
+
+			if !member.Role.AtLeast(required) {
+				app.forbiddenError(w, r, fmt.Errorf("project role %s does not have required access", member.Role))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func getUserFromCtx(r *http.Request) *storage.User {
 	user, _ := r.Context().Value(userCtx).(*storage.User)
 	return user
@@ -124,3 +276,18 @@ func getPostFromCtx(r *http.Request) *storage.Post {
 	post, _ := r.Context().Value(postCtx).(*storage.Post)
 	return post
 }
+
+func getProductFromCtx(r *http.Request) *storage.Product {
+	product, _ := r.Context().Value(productCtx).(*storage.Product)
+	return product
+}
+
+func getBoardFromCtx(r *http.Request) *storage.Board {
+	board, _ := r.Context().Value(boardCtx).(*storage.Board)
+	return board
+}
+
+func getProjectFromCtx(r *http.Request) *storage.Project {
+	project, _ := r.Context().Value(projectCtx).(*storage.Project)
+	return project
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errInvalidPoll = errors.New("invalid poll")
+
+// PollPayload - polls without closes_at stay open until the post is deleted
+type PollPayload struct {
+	Options        []PollOptionPayload `json:"options" validate:"required,min=2,max=6,dive"`
+	MultipleChoice bool                `json:"multiple_choice"`
+	ClosesAt       *time.Time          `json:"closes_at"`
+}
+
+type PollOptionPayload struct {
+	Text  string `json:"text" validate:"required,max=100"`
+	Image string `json:"image" validate:"omitempty"` // s3 object key
+}
+
+// VotePayload - option indexes, single choice polls take exactly one
+type VotePayload struct {
+	Options []int `json:"options" validate:"required,min=1,max=6,dive,gte=0"`
+}
+
+// buildPoll - option images must be uploaded by the author
+func buildPoll(author *storage.User, payload *PollPayload) (*storage.Poll, error) {
+	if payload.ClosesAt != nil && !payload.ClosesAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: closes_at must be in the future", errInvalidPoll)
+	}
+
+	options := make([]storage.PollOption, 0, len(payload.Options))
+	for _, option := range payload.Options {
+		if option.Image != "" && !ownsS3Key(author.ID, option.Image) {
+			return nil, fmt.Errorf("%w: image key '%s' is not the correct format", errInvalidPoll, option.Image)
+		}
+		options = append(options, storage.PollOption{Text: option.Text, Image: option.Image})
+	}
+
+	return &storage.Poll{
+		Options:        options,
+		MultipleChoice: payload.MultipleChoice,
+		ClosesAt:       payload.ClosesAt,
+	}, nil
+}
+
+// validateVote - option indexes must exist on the poll and be chosen only once
+func validateVote(poll *storage.Poll, options []int) error {
+	if !poll.MultipleChoice && len(options) != 1 {
+		return fmt.Errorf("this poll takes a single option")
+	}
+
+	seen := make(map[int]bool, len(options))
+	for _, option := range options {
+		if option >= len(poll.Options) {
+			return fmt.Errorf("option %d does not exist", option)
+		}
+		if seen[option] {
+			return fmt.Errorf("option %d is chosen more than once", option)
+		}
+		seen[option] = true
+	}
+
+	return nil
+}
+
+// hidePollResults - tallies are only shown once the viewer voted or the poll closed, authors always see them
+func hidePollResults(poll *storage.Poll, viewer *storage.User, authorID primitive.ObjectID) {
+	if poll.ViewerVote != nil || poll.Closed(time.Now()) || (viewer != nil && viewer.ID == authorID) {
+		return
+	}
+
+	poll.ResultsHidden = true
+	poll.VoterCount = 0
+	for i := range poll.Options {
+		poll.Options[i].VoteCount = 0
+	}
+}
+
+// attachPollStatus - mark the viewer's vote on every poll and hide results they can't see yet
+// anonymous viewers have voted in nothing
+func (app *application) attachPollStatus(ctx context.Context, user *storage.User, posts []*storage.Post) error {
+	var postIDs []primitive.ObjectID
+	for _, post := range posts {
+		if post != nil && post.Poll != nil {
+			postIDs = append(postIDs, post.ID)
+		}
+	}
+
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	votes := make(map[primitive.ObjectID][]int)
+	if user != nil {
+		var err error
+		votes, err = app.storage.Post.GetPollVotes(ctx, user.ID, postIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, post := range posts {
+		if post == nil || post.Poll == nil {
+			continue
+		}
+		post.Poll.ViewerVote = votes[post.ID]
+		hidePollResults(post.Poll, user, post.UserID)
+	}
+
+	return nil
+}
+
+// feedPolls - posts on a page of the feed and the originals they share
+func feedPolls(posts []storage.PostWithLikeStatus) []*storage.Post {
+	polls := make([]*storage.Post, 0, len(posts))
+	for i := range posts {
+		polls = append(polls, &posts[i].Post)
+		if posts[i].Original != nil {
+			polls = append(polls, posts[i].Original.Post)
+		}
+	}
+	return polls
+}
+
+// pollKeysToUrl - turn option images saved as s3 object keys into url
+func (app *application) pollKeysToUrl(ctx context.Context, poll *storage.Poll) error {
+	for i, option := range poll.Options {
+		if option.Image == "" {
+			continue
+		}
+
+		urls, err := app.presignS3Keys(ctx, []string{option.Image})
+		if err != nil {
+			return err
+		}
+		poll.Options[i].Image = urls[0]
+	}
+	return nil
+}
+
+// respondPoll - the poll of the post as the viewer is allowed to see it
+func (app *application) respondPoll(w http.ResponseWriter, r *http.Request, postID primitive.ObjectID) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	post, err := app.storage.Post.GetByID(ctx, postID.Hex(), user)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if post.Poll == nil {
+		app.notFoundError(w, r, storage.ErrPollNotFound)
+		return
+	}
+
+	if err := app.attachPollStatus(ctx, user, []*storage.Post{post}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.pollKeysToUrl(ctx, post.Poll); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, post.Poll)
+}
+
+func (app *application) getPollHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondPoll(w, r, getPostFromCtx(r).ID)
+}
+
+func (app *application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+	var payload VotePayload
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if post.Poll == nil || post.Status != storage.PostPublished {
+		app.notFoundError(w, r, storage.ErrPollNotFound)
+		return
+	}
+
+	if err := validateVote(post.Poll, payload.Options); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.storage.Post.Vote(r.Context(), post.ID, user.ID, payload.Options); err != nil {
+		app.pollError(w, r, err)
+		return
+	}
+
+	app.respondPoll(w, r, post.ID)
+}
+
+func (app *application) unvotePollHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if post.Poll == nil {
+		app.notFoundError(w, r, storage.ErrPollNotFound)
+		return
+	}
+
+	if err := app.storage.Post.Unvote(r.Context(), post.ID, user.ID); err != nil {
+		app.pollError(w, r, err)
+		return
+	}
+
+	app.respondPoll(w, r, post.ID)
+}
+
+func (app *application) pollError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errInvalidPoll), errors.Is(err, storage.ErrPollClosed):
+		app.badRequestError(w, r, err)
+	case errors.Is(err, storage.ErrAlreadyVoted):
+		app.conflictError(w, r, "ALREADY_VOTED", err)
+	case errors.Is(err, storage.ErrPollVoteMissing):
+		app.notFoundError(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
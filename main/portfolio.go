@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PortfolioPayload struct {
+	Sections []PortfolioSectionPayload `json:"sections" validate:"max=10,dive"`
+}
+
+type PortfolioSectionPayload struct {
+	Title   string   `json:"title" validate:"required,max=50"`
+	PostIDs []string `json:"post_ids" validate:"required,min=1,max=20,dive,hexadecimal,len=24"`
+}
+
+// featuredPost - post card of pinned posts and portfolio sections
+type featuredPost struct {
+	PostID   primitive.ObjectID `json:"post_id"`
+	Type     storage.PostType   `json:"type"`
+	Title    string             `json:"title"`
+	CoverURL string             `json:"cover_url,omitempty"`
+}
+
+type featuredSection struct {
+	ID    primitive.ObjectID `json:"id"`
+	Title string             `json:"title"`
+	Posts []featuredPost     `json:"posts"`
+}
+
+// coverImage - first image of the post, showcase posts use their first after image
+func coverImage(post *storage.Post) string {
+	if post.Showcase != nil && len(post.Showcase.AfterImages) > 0 {
+		return post.Showcase.AfterImages[0]
+	}
+	if len(post.Images) > 0 {
+		return post.Images[0]
+	}
+	return ""
+}
+
+// featuredPosts - cards in the given order, posts the viewer can't see or that were deleted are left out
+func (app *application) featuredPosts(ctx context.Context, viewer *storage.User, postIDs []primitive.ObjectID) ([]featuredPost, error) {
+	posts, err := app.storage.Post.GetByIDs(ctx, postIDs, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	postMap := make(map[primitive.ObjectID]*storage.Post, len(posts))
+	for i := range posts {
+		if posts[i].Status == storage.PostPublished {
+			postMap[posts[i].ID] = &posts[i]
+		}
+	}
+
+	cards := make([]featuredPost, 0, len(postIDs))
+	for _, id := range postIDs {
+		post, ok := postMap[id]
+		if !ok {
+			continue
+		}
+
+		card := featuredPost{PostID: post.ID, Type: post.Type, Title: post.Title}
+		if cover := coverImage(post); cover != "" {
+			urls, err := app.presignS3Keys(ctx, []string{cover})
+			if err != nil {
+				return nil, err
+			}
+			card.CoverURL = urls[0]
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, nil
+}
+
+// attachFeatured - pinned posts and portfolio sections of the profile owner as seen by the viewer
+func (app *application) attachFeatured(ctx context.Context, viewer *storage.User, resp *UserWithStats) error {
+	pinned, err := app.featuredPosts(ctx, viewer, resp.User.PinnedPostIDs)
+	if err != nil {
+		return err
+	}
+	resp.PinnedPosts = pinned
+
+	for _, section := range resp.User.Portfolio {
+		posts, err := app.featuredPosts(ctx, viewer, section.PostIDs)
+		if err != nil {
+			return err
+		}
+
+		// sections whose posts are all hidden from the viewer are left out
+		if len(posts) == 0 {
+			continue
+		}
+		resp.Portfolio = append(resp.Portfolio, featuredSection{ID: section.ID, Title: section.Title, Posts: posts})
+	}
+
+	return nil
+}
+
+func (app *application) pinPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if post.Status != storage.PostPublished {
+		app.badRequestError(w, r, fmt.Errorf("only published posts can be pinned"))
+		return
+	}
+
+	if err := app.storage.User.PinPost(r.Context(), user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPinLimit):
+			app.conflictError(w, r, "PIN_LIMIT", fmt.Errorf("%w: at most %d posts can be pinned", err, storage.MaxPinnedPosts))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) unpinPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if err := app.storage.User.UnpinPost(r.Context(), user.ID, post.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updatePortfolioHandler - replace the featured portfolio, only the user's own published posts can be featured
+func (app *application) updatePortfolioHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+	var payload PortfolioPayload
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	sections := make([]storage.PortfolioSection, 0, len(payload.Sections))
+	var postIDs []primitive.ObjectID
+	for _, sectionPayload := range payload.Sections {
+		section := storage.PortfolioSection{ID: primitive.NewObjectID(), Title: sectionPayload.Title}
+
+		seen := make(map[primitive.ObjectID]bool)
+		for _, hex := range sectionPayload.PostIDs {
+			postID, err := primitive.ObjectIDFromHex(hex)
+			if err != nil {
+				app.badRequestError(w, r, fmt.Errorf("invalid post ID: %s", hex))
+				return
+			}
+			if seen[postID] {
+				continue
+			}
+			seen[postID] = true
+
+			section.PostIDs = append(section.PostIDs, postID)
+			postIDs = append(postIDs, postID)
+		}
+
+		sections = append(sections, section)
+	}
+
+	posts, err := app.storage.Post.GetByIDs(ctx, postIDs, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	owned := make(map[primitive.ObjectID]bool, len(posts))
+	for _, post := range posts {
+		if post.UserID == user.ID && post.Status == storage.PostPublished {
+			owned[post.ID] = true
+		}
+	}
+
+	for _, postID := range postIDs {
+		if !owned[postID] {
+			app.badRequestError(w, r, fmt.Errorf("post %s is not one of your published posts", postID.Hex()))
+			return
+		}
+	}
+
+	if err := app.storage.User.SetPortfolio(ctx, user.ID, sections); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user.Portfolio = sections
+	resp := UserWithStats{User: user}
+	if err := app.attachFeatured(ctx, user, &resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, resp.Portfolio)
+}
@@ -2,43 +2,121 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
-	"regexp"
+	"time"
 )
 
+var errInvalidHotspot = errors.New("invalid hotspot")
+
+// CreatePostPayload - showcase is required for showcase posts and not allowed otherwise
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=150"`
-	Content string   `json:"content" validate:"required,max=1500"`
-	Tags    []string `json:"tags" validate:"omitempty,dive,required"`
-	Images  []string `json:"images" validate:"omitempty,dive,required"`
+	Type     storage.PostType `json:"type" validate:"omitempty,oneof=standard showcase"`
+	Title    string           `json:"title" validate:"required,max=150"`
+	Content  string           `json:"content" validate:"required,max=1500"`
+	Tags     []string         `json:"tags" validate:"omitempty,dive,required"`
+	Images   []string         `json:"images" validate:"omitempty,dive,required"`
+	Hotspots []HotspotPayload `json:"hotspots" validate:"omitempty,max=50,dive"`
+	Showcase *ShowcasePayload `json:"showcase"`
+	Poll     *PollPayload     `json:"poll"`
+	Question bool             `json:"question"` // comments on a question are answers
+	// status defaults to published, scheduled posts need publish_at
+	Status    storage.PostStatus `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time         `json:"publish_at"`
+	// visibility defaults to public
+	Visibility storage.PostVisibility `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
+}
+
+// HotspotPayload - a hotspot links to at most one of a user, a catalog product or an external product url
+type HotspotPayload struct {
+	ImageIndex int     `json:"image_index" validate:"gte=0"`
+	X          float64 `json:"x" validate:"gte=0,lte=1"`
+	Y          float64 `json:"y" validate:"gte=0,lte=1"`
+	Label      string  `json:"label" validate:"required,max=100"`
+	UserID     string  `json:"user_id,omitempty" validate:"omitempty,hexadecimal,len=24"`
+	ProductID  string  `json:"product_id,omitempty" validate:"omitempty,hexadecimal,len=24"`
+	ProductURL string  `json:"product_url,omitempty" validate:"omitempty,url,max=2048"`
 }
 
 // UpdatePostPayload - all fields are pointers with nil as default, otherwise they'll be a 0("") as default
 //
 //	so fields can be nil if no input from the client vs "" if input is intentionally ""
 type UpdatePostPayload struct {
-	Title      *string   `json:"title" validate:"omitempty,max=150"`
-	Content    *string   `json:"content" validate:"omitempty,max=1500"`
-	Tags       *[]string `json:"tags" validate:"omitempty,dive,required"`
-	ImagesPath *[]string `json:"images_path" validate:"omitempty,dive,required"`
-	Version    int64     `json:"version" validate:"required"`
+	Title      *string                 `json:"title" validate:"omitempty,max=150"`
+	Content    *string                 `json:"content" validate:"omitempty,max=1500"`
+	Tags       *[]string               `json:"tags" validate:"omitempty,dive,required"`
+	ImagesPath *[]string               `json:"images_path" validate:"omitempty,dive,required"`
+	Hotspots   *[]HotspotPayload       `json:"hotspots" validate:"omitempty,max=50,dive"`
+	Showcase   *ShowcasePayload        `json:"showcase"`
+	Status     *storage.PostStatus     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt  *time.Time              `json:"publish_at"`
+	Visibility *storage.PostVisibility `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
+	Version    int64                   `json:"version" validate:"required"`
 }
 
-func extractMentions(text string) []string {
-	re := regexp.MustCompile(`@([a-zA-Z0-9_]+)`)
-	// '-1' means no limit, return all matches
-	//[["@user1", "user1"], ["@user2", "user2"] ]
-	matches := re.FindAllStringSubmatch(text, -1)
+// buildHotspots - check hotspots against the post images and resolve the linked user/product
+func (app *application) buildHotspots(ctx context.Context, payloads []HotspotPayload, imageCount int) ([]storage.Hotspot, error) {
+	hotspots := make([]storage.Hotspot, 0, len(payloads))
+
+	for _, hp := range payloads {
+		if hp.ImageIndex >= imageCount {
+			return nil, fmt.Errorf("%w: image index %d out of range", errInvalidHotspot, hp.ImageIndex)
+		}
+
+		links := 0
+		for _, link := range []string{hp.UserID, hp.ProductID, hp.ProductURL} {
+			if link != "" {
+				links++
+			}
+		}
+		if links > 1 {
+			return nil, fmt.Errorf("%w: only one of user_id, product_id and product_url can be set", errInvalidHotspot)
+		}
+
+		hotspot := storage.Hotspot{
+			ImageIndex: hp.ImageIndex,
+			X:          hp.X,
+			Y:          hp.Y,
+			Label:      hp.Label,
+			ProductURL: hp.ProductURL,
+		}
+
+		if hp.UserID != "" {
+			user, err := app.storage.User.GetByID(ctx, hp.UserID)
+			if err != nil {
+				return nil, err
+			}
+			hotspot.UserID = &user.ID
+		}
+
+		if hp.ProductID != "" {
+			product, err := app.storage.Product.GetByID(ctx, hp.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			hotspot.ProductID = &product.ID
+		}
 
-	var mentions []string
-	for _, match := range matches {
-		mentions = append(mentions, match[1])
+		hotspots = append(hotspots, hotspot)
 	}
 
-	return mentions
+	return hotspots, nil
+}
+
+// hotspotError - map errors from buildHotspots to a response
+func (app *application) hotspotError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errInvalidHotspot):
+		app.badRequestError(w, r, err)
+	case errors.Is(err, storage.ErrUserNotFound), errors.Is(err, storage.ErrProductNotFound):
+		app.notFoundError(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
 }
 
 func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request) {
@@ -56,27 +134,73 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	mentions := extractMentions(payload.Content)
+	mentions, mentionEntities, err := app.resolveMentions(ctx, payload.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 
-	if len(mentions) > 0 {
-		validMentions, err := app.storage.User.ValidateUsername(ctx, mentions)
+	hotspots, err := app.buildHotspots(ctx, payload.Hotspots, len(payload.Images))
+	if err != nil {
+		app.hotspotError(w, r, err)
+		return
+	}
+
+	if payload.Type == "" {
+		payload.Type = storage.PostStandard
+	}
+
+	var showcase *storage.Showcase
+	switch {
+	case payload.Type == storage.PostShowcase && payload.Showcase == nil:
+		app.badRequestError(w, r, fmt.Errorf("showcase is required for showcase posts"))
+		return
+	case payload.Type != storage.PostShowcase && payload.Showcase != nil:
+		app.badRequestError(w, r, fmt.Errorf("showcase is only allowed for showcase posts"))
+		return
+	case payload.Showcase != nil:
+		showcase, err = app.buildShowcase(ctx, user, payload.Showcase, nil)
 		if err != nil {
-			app.notFoundError(w, r, err)
+			app.showcaseError(w, r, err)
+			return
+		}
+	}
+
+	var poll *storage.Poll
+	if payload.Poll != nil {
+		poll, err = buildPoll(user, payload.Poll)
+		if err != nil {
+			app.pollError(w, r, err)
 			return
 		}
-		mentions = validMentions
 	}
 
 	post := &storage.Post{
-		UserID:       user.ID,
-		UserRole:     user.Role,
-		Title:        payload.Title,
-		Content:      payload.Content,
-		Tags:         payload.Tags,
-		Mentions:     mentions,
-		Images:       payload.Images,
-		CommentCount: 0,
-		Version:      1,
+		UserID:          user.ID,
+		UserRole:        user.Role,
+		Type:            payload.Type,
+		Title:           payload.Title,
+		Content:         payload.Content,
+		Tags:            postTags(payload.Tags, payload.Content),
+		Mentions:        mentions,
+		MentionEntities: mentionEntities,
+		Images:          payload.Images,
+		Hotspots:        hotspots,
+		Showcase:        showcase,
+		Poll:            poll,
+		Question:        payload.Question,
+		Visibility:      payload.Visibility,
+		CommentCount:    0,
+		Version:         1,
+	}
+
+	if payload.Status == "" {
+		payload.Status = storage.PostPublished
+	}
+
+	if err := applyPublishing(post, payload.Status, payload.PublishAt); err != nil {
+		app.badRequestError(w, r, err)
+		return
 	}
 
 	if err := app.storage.Post.Create(ctx, post); err != nil {
@@ -84,25 +208,36 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.useTags(ctx, post)
+	app.fanOutPost(ctx, post.ID)
+
 	app.OutputJSON(w, http.StatusCreated, post)
 }
 
-// s3KeysToUrl - turn images saved as s3 object keys into url
+// s3KeysToUrl - turn images saved as s3 object keys into url, hotspots get the url of the image they annotate
 func (app *application) s3KeysToUrl(ctx context.Context, post *storage.Post) error {
-	urls := make([]string, len(post.Images))
-	for i, key := range post.Images {
-		url, err := app.awsPresigner.GetImageURL(
-			ctx,
-			key,
-			app.config.awsConfig.exp,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to get image url: %w", err)
+	urls, err := app.presignS3Keys(ctx, post.Images)
+	if err != nil {
+		return err
+	}
+
+	for i, hotspot := range post.Hotspots {
+		if hotspot.ImageIndex < len(urls) {
+			post.Hotspots[i].ImageURL = urls[hotspot.ImageIndex]
 		}
-		urls[i] = url
 	}
 
 	post.Images = urls
+
+	if post.Poll != nil {
+		if err := app.pollKeysToUrl(ctx, post.Poll); err != nil {
+			return err
+		}
+	}
+
+	if post.Showcase != nil {
+		return app.showcaseKeysToUrl(ctx, post.Showcase)
+	}
 	return nil
 }
 
@@ -131,7 +266,7 @@ func (app *application) getAllUserPostsHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	posts, err := app.storage.Post.GetByUserID(ctx, user.ID, cq)
+	posts, err := app.storage.Post.GetByUserID(ctx, user.ID, getUserFromCtx(r), cq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -145,10 +280,15 @@ func (app *application) getAllUserPostsHandler(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	if err := app.attachViewerStatus(ctx, getUserFromCtx(r), posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	// !!! return cursor only if len(posts) >= limit, avoiding infinite loop of infinite scrolling if here is just 1 post
 	var nextCursor *string
 	if len(posts) >= cq.Limit {
-		cursor := posts[len(posts)-1].Post.ID.Hex()
+		cursor := storage.PostCursor(posts[len(posts)-1].Post)
 		nextCursor = &cursor
 	}
 
@@ -161,14 +301,42 @@ func (app *application) getAllUserPostsHandler(w http.ResponseWriter, r *http.Re
 }
 
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	post := getPostFromCtx(r)
 
-	if err := app.s3KeysToUrl(r.Context(), post); err != nil {
+	if err := app.s3KeysToUrl(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	app.OutputJSON(w, http.StatusOK, post)
+	response := postResponse{Post: post}
+	if post.RepostOf != nil {
+		shared, err := app.getSharedPosts(ctx, getUserFromCtx(r), []primitive.ObjectID{*post.RepostOf})
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		response.Original = shared[*post.RepostOf]
+		if response.Original.Post != nil {
+			if err := app.s3KeysToUrl(ctx, response.Original.Post); err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+	}
+
+	polls := []*storage.Post{post}
+	if response.Original != nil {
+		polls = append(polls, response.Original.Post)
+	}
+
+	if err := app.attachPollStatus(ctx, getUserFromCtx(r), polls); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, response)
 }
 
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
@@ -185,43 +353,109 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Optimistic Concurrency Control - check version mismatch
-	if payload.Version != 0 && payload.Version != post.Version {
-		app.conflictError(w, r, "VERSION_MISMATCH", fmt.Errorf("version mismatch"))
+	if post.Type == storage.PostRepost {
+		app.badRequestError(w, r, fmt.Errorf("reposts can't be edited"))
 		return
 	}
 
+	// Optimistic Concurrency Control - the update only applies if the stored version still matches
+	post.Version = payload.Version
+	wasPublished := post.Status == storage.PostPublished
+	wasVisibility := post.Visibility
+
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
 
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+
 	if payload.Content != nil {
 		post.Content = *payload.Content
-		mentions := extractMentions(*payload.Content)
-
-		validMentions, err := app.storage.User.ValidateUsername(r.Context(), mentions)
+		mentions, mentionEntities, err := app.resolveMentions(r.Context(), post.Content)
 		if err != nil {
-			app.notFoundError(w, r, err)
+			app.internalServerError(w, r, err)
 			return
 		}
-		post.Mentions = validMentions
+		post.Mentions = mentions
+		post.MentionEntities = mentionEntities
 	}
 
-	if payload.Tags != nil {
-		post.Tags = *payload.Tags
+	// hashtags added to the content are kept with the explicit tags
+	if payload.Tags != nil || payload.Content != nil {
+		tags := post.Tags
+		if payload.Tags != nil {
+			tags = *payload.Tags
+		}
+		post.Tags = postTags(tags, post.Content)
 	}
 
 	if payload.ImagesPath != nil {
 		post.Images = *payload.ImagesPath
 	}
 
-	post.Version++
+	if payload.Hotspots != nil {
+		hotspots, err := app.buildHotspots(r.Context(), *payload.Hotspots, len(post.Images))
+		if err != nil {
+			app.hotspotError(w, r, err)
+			return
+		}
+		post.Hotspots = hotspots
+	}
+
+	if payload.Showcase != nil {
+		if post.Type != storage.PostShowcase {
+			app.badRequestError(w, r, fmt.Errorf("showcase is only allowed for showcase posts"))
+			return
+		}
+
+		showcase, err := app.buildShowcase(r.Context(), getUserFromCtx(r), payload.Showcase, post.Showcase)
+		if err != nil {
+			app.showcaseError(w, r, err)
+			return
+		}
+		post.Showcase = showcase
+	}
+
+	// a new status replaces publish_at, otherwise only the publish time of a scheduled post can change
+	if payload.Status != nil || payload.PublishAt != nil {
+		status := post.Status
+		if payload.Status != nil {
+			status = *payload.Status
+		}
+
+		if err := applyPublishing(post, status, payload.PublishAt); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	// existing hotspots must still point at an image if images were removed
+	for _, hotspot := range post.Hotspots {
+		if hotspot.ImageIndex >= len(post.Images) {
+			app.badRequestError(w, r, fmt.Errorf("%w: image index %d out of range", errInvalidHotspot, hotspot.ImageIndex))
+			return
+		}
+	}
+
+	if wasPublished {
+		now := time.Now()
+		post.EditedAt = &now
+	}
 
 	if err := app.storage.Post.Update(r.Context(), post); err != nil {
-		app.internalServerError(w, r, err)
+		app.postUpdateError(w, r, err)
 		return
 	}
 
+	app.useTags(r.Context(), post)
+
+	// newly published or visible posts reach the followers, fanning out again keeps existing entries
+	if post.Status == storage.PostPublished && (!wasPublished || post.Visibility != wasVisibility) {
+		app.fanOutPost(r.Context(), post.ID)
+	}
+
 	app.OutputJSON(w, http.StatusCreated, post)
 }
 
@@ -235,35 +469,82 @@ func (app *application) toggleLikePostHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	app.likeReputation(r.Context(), post, user.ID, liked)
+
 	app.OutputJSON(w, http.StatusCreated, liked)
 }
 
-func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
-	postID := chi.URLParam(r, "postID")
-	ctx := r.Context()
+type likesResponse struct {
+	Likes      []storage.PostLiker `json:"likes"`
+	NextCursor *string             `json:"next_cursor"`
+}
 
-	// Get the post first to retrieve image keys for S3 deletion
-	post, err := app.storage.Post.GetByID(ctx, postID)
-	if err != nil {
-		app.notFoundError(w, r, err)
+// getPostLikesHandler - who liked the post, most recent first
+func (app *application) getPostLikesHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	cq := storage.CursorQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
 		return
 	}
 
-	// Delete images from S3 if they exist
-	if len(post.Images) > 0 {
-		for _, imageKey := range post.Images {
-			if err := app.awsPresigner.DeleteImage(ctx, imageKey); err != nil {
-				app.internalServerError(w, r, err)
-			}
-		}
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
 	}
 
-	// Delete the post from database
-	err = app.storage.Post.Delete(ctx, postID)
+	likes, err := app.storage.Post.GetLikes(r.Context(), post.ID, cq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	var nextCursor *string
+	if len(likes) >= cq.Limit {
+		cursor := likes[len(likes)-1].ID.Hex()
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, likesResponse{
+		Likes:      likes,
+		NextCursor: nextCursor,
+	})
+}
+
+// deletePostHandler - posts go to the trash, images are removed from S3 once the post is purged
+func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	// reposts have no content to restore, they are removed right away
+	if post.Type == storage.PostRepost {
+		repostID, err := app.storage.Post.Unrepost(r.Context(), post.UserID, *post.RepostOf)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.removeFromTimelines(r.Context(), repostID)
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := app.storage.Post.Delete(r.Context(), post.ID.Hex()); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.removeFromTimelines(r.Context(), post.ID)
+
 	w.WriteHeader(http.StatusNoContent)
 }
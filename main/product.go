@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hnzhou16/project-cocraft-server/internal/security"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+)
+
+type CreateProductPayload struct {
+	SKU         string             `json:"sku" validate:"required,max=64"`
+	Name        string             `json:"name" validate:"required,max=150"`
+	Description string             `json:"description" validate:"omitempty,max=1500"`
+	Category    string             `json:"category" validate:"required,max=50"`
+	Materials   []string           `json:"materials" validate:"omitempty,max=20,dive,required,max=50"`
+	Dimensions  storage.Dimensions `json:"dimensions"`
+	Finishes    []string           `json:"finishes" validate:"omitempty,max=20,dive,required,max=50"`
+	PriceRange  storage.PriceRange `json:"price_range"`
+	Images      []string           `json:"images" validate:"omitempty,max=10,dive,required"`
+	SpecSheets  []string           `json:"spec_sheets" validate:"omitempty,max=5,dive,required"`
+}
+
+// UpdateProductPayload - pointer fields, nil means the field is not updated
+type UpdateProductPayload struct {
+	SKU         *string             `json:"sku" validate:"omitempty,min=1,max=64"`
+	Name        *string             `json:"name" validate:"omitempty,min=1,max=150"`
+	Description *string             `json:"description" validate:"omitempty,max=1500"`
+	Category    *string             `json:"category" validate:"omitempty,min=1,max=50"`
+	Materials   *[]string           `json:"materials" validate:"omitempty,max=20,dive,required,max=50"`
+	Dimensions  *storage.Dimensions `json:"dimensions"`
+	Finishes    *[]string           `json:"finishes" validate:"omitempty,max=20,dive,required,max=50"`
+	PriceRange  *storage.PriceRange `json:"price_range"`
+	Images      *[]string           `json:"images" validate:"omitempty,max=10,dive,required"`
+	SpecSheets  *[]string           `json:"spec_sheets" validate:"omitempty,max=5,dive,required"`
+}
+
+type productListResponse struct {
+	Products   []storage.Product      `json:"products"`
+	Facets     *storage.ProductFacets `json:"facets"`
+	NextCursor *string                `json:"next_cursor"`
+}
+
+// validateProduct - checks that can't be expressed with validator tags
+func validateProduct(user *storage.User, product *storage.Product) error {
+	pr := product.PriceRange
+	if pr.Min < 0 || pr.Max < pr.Min {
+		return fmt.Errorf("price range is invalid")
+	}
+	if pr.Currency == "" {
+		return fmt.Errorf("price range currency is required")
+	}
+
+	d := product.Dimensions
+	if d.Width < 0 || d.Height < 0 || d.Depth < 0 {
+		return fmt.Errorf("dimensions must not be negative")
+	}
+
+	for _, key := range product.Images {
+		if !ownsS3Key(user.ID, key) {
+			return fmt.Errorf("image key '%s' is not the correct format", key)
+		}
+	}
+
+	for _, key := range product.SpecSheets {
+		if !ownsS3Key(user.ID, key) || !strings.HasSuffix(key, ".pdf") {
+			return fmt.Errorf("spec sheet key '%s' is not the correct format", key)
+		}
+	}
+
+	return nil
+}
+
+// productKeysToUrl - turn images and spec sheets saved as s3 object keys into url
+func (app *application) productKeysToUrl(ctx context.Context, product *storage.Product) error {
+	images, err := app.presignS3Keys(ctx, product.Images)
+	if err != nil {
+		return err
+	}
+
+	specSheets, err := app.presignS3Keys(ctx, product.SpecSheets)
+	if err != nil {
+		return err
+	}
+
+	product.Images = images
+	product.SpecSheets = specSheets
+	return nil
+}
+
+func (app *application) createProductHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateProductPayload
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	product := &storage.Product{
+		ManufacturerID: user.ID,
+		SKU:            payload.SKU,
+		Name:           payload.Name,
+		Description:    payload.Description,
+		Category:       payload.Category,
+		Materials:      payload.Materials,
+		Dimensions:     payload.Dimensions,
+		Finishes:       payload.Finishes,
+		PriceRange:     payload.PriceRange,
+		Images:         payload.Images,
+		SpecSheets:     payload.SpecSheets,
+	}
+
+	if err := validateProduct(user, product); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.storage.Product.Create(ctx, product); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrDupSKU):
+			app.conflictError(w, r, "DUPLICATE_SKU", err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, product)
+}
+
+func (app *application) getProductHandler(w http.ResponseWriter, r *http.Request) {
+	product := getProductFromCtx(r)
+
+	if err := app.productKeysToUrl(r.Context(), product); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, product)
+}
+
+func (app *application) updateProductHandler(w http.ResponseWriter, r *http.Request) {
+	product := getProductFromCtx(r)
+	user := getUserFromCtx(r)
+	var payload UpdateProductPayload
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.SKU != nil {
+		product.SKU = *payload.SKU
+	}
+	if payload.Name != nil {
+		product.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		product.Description = *payload.Description
+	}
+	if payload.Category != nil {
+		product.Category = *payload.Category
+	}
+	if payload.Materials != nil {
+		product.Materials = *payload.Materials
+	}
+	if payload.Dimensions != nil {
+		product.Dimensions = *payload.Dimensions
+	}
+	if payload.Finishes != nil {
+		product.Finishes = *payload.Finishes
+	}
+	if payload.PriceRange != nil {
+		product.PriceRange = *payload.PriceRange
+	}
+	if payload.Images != nil {
+		product.Images = *payload.Images
+	}
+	if payload.SpecSheets != nil {
+		product.SpecSheets = *payload.SpecSheets
+	}
+
+	if err := validateProduct(user, product); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.storage.Product.Update(r.Context(), product); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrDupSKU):
+			app.conflictError(w, r, "DUPLICATE_SKU", err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, product)
+}
+
+func (app *application) deleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	product := getProductFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.storage.Product.Delete(ctx, product.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrProductNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the product is gone already, failing to clean up s3 should not fail the request
+	keys := append(product.Images, product.SpecSheets...)
+	for _, key := range keys {
+		if err := app.awsPresigner.DeleteImage(ctx, key); err != nil {
+			app.logger.Errorw("failed to delete product file", "key", key, "error", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// browseProductsHandler - search the catalog with faceted filters
+func (app *application) browseProductsHandler(w http.ResponseWriter, r *http.Request) {
+	pq := storage.ProductQuery{
+		Limit: 10,
+	}
+
+	if err := pq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	app.outputProducts(w, r, pq)
+}
+
+// getManufacturerProductsHandler - products listed on a manufacturer's profile
+func (app *application) getManufacturerProductsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+
+	manufacturer, err := app.storage.User.GetByID(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if manufacturer.Role != security.Manufacturer {
+		app.badRequestError(w, r, fmt.Errorf("user is not a manufacturer"))
+		return
+	}
+
+	pq := storage.ProductQuery{
+		Limit: 10,
+	}
+
+	if err := pq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	pq.ManufacturerID = &manufacturer.ID
+
+	app.outputProducts(w, r, pq)
+}
+
+func (app *application) outputProducts(w http.ResponseWriter, r *http.Request, pq storage.ProductQuery) {
+	ctx := r.Context()
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	products, err := app.storage.Product.Browse(ctx, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// facets only need to be computed once, not for every page
+	var facets *storage.ProductFacets
+	if pq.Cursor == "" {
+		facets, err = app.storage.Product.GetFacets(ctx, pq)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	for i := range products {
+		if err := app.productKeysToUrl(ctx, &products[i]); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	var nextCursor *string
+	if len(products) >= pq.Limit {
+		cursor := products[len(products)-1].ID.Hex()
+		nextCursor = &cursor
+	}
+
+	response := productListResponse{
+		Products:   products,
+		Facets:     facets,
+		NextCursor: nextCursor,
+	}
+
+	app.OutputJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,684 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CreateProjectPayload struct {
+	Name        string `json:"name" validate:"required,max=150"`
+	Description string `json:"description" validate:"omitempty,max=1500"`
+}
+
+// UpdateProjectPayload - pointer fields, nil means the field is not updated
+type UpdateProjectPayload struct {
+	Name        *string `json:"name" validate:"omitempty,min=1,max=150"`
+	Description *string `json:"description" validate:"omitempty,max=1500"`
+}
+
+// ProjectMemberPayload - owner role can't be granted, a project has exactly one owner
+type ProjectMemberPayload struct {
+	UserID string              `json:"user_id" validate:"required,hexadecimal,len=24"`
+	Role   storage.ProjectRole `json:"role" validate:"required,oneof=manager member viewer"`
+}
+
+type UpdateProjectMemberPayload struct {
+	Role storage.ProjectRole `json:"role" validate:"required,oneof=manager member viewer"`
+}
+
+type CreateProjectUpdatePayload struct {
+	Content string   `json:"content" validate:"required,max=1500"`
+	Images  []string `json:"images" validate:"omitempty,max=10,dive,required"`
+}
+
+type CreateProjectTaskPayload struct {
+	Title       string     `json:"title" validate:"required,max=150"`
+	Description string     `json:"description" validate:"omitempty,max=1500"`
+	AssigneeID  string     `json:"assignee_id" validate:"omitempty,hexadecimal,len=24"`
+	DueDate     *time.Time `json:"due_date"`
+}
+
+// UpdateProjectTaskPayload - nil means not updated, "" clears assignee_id/due_date
+type UpdateProjectTaskPayload struct {
+	Title       *string `json:"title" validate:"omitempty,min=1,max=150"`
+	Description *string `json:"description" validate:"omitempty,max=1500"`
+	AssigneeID  *string `json:"assignee_id"`
+	DueDate     *string `json:"due_date"`
+	Completed   *bool   `json:"completed"`
+}
+
+type AddProjectDocumentPayload struct {
+	Name string `json:"name" validate:"required,max=150"`
+	Key  string `json:"key" validate:"required"`
+}
+
+type projectUpdatesResponse struct {
+	Updates    []storage.ProjectUpdate `json:"updates"`
+	NextCursor *string                 `json:"next_cursor"`
+}
+
+type projectActivityResponse struct {
+	Activity   []storage.ProjectActivity `json:"activity"`
+	NextCursor *string                   `json:"next_cursor"`
+}
+
+// projectAssignee - tasks can only be assigned to project members
+func projectAssignee(project *storage.Project, assigneeID string) (*primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(assigneeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assignee ID")
+	}
+
+	if project.Member(objID) == nil {
+		return nil, fmt.Errorf("assignee is not a project member")
+	}
+
+	return &objID, nil
+}
+
+func (app *application) createProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateProjectPayload
+	user := getUserFromCtx(r)
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	project := &storage.Project{
+		Name:        payload.Name,
+		Description: payload.Description,
+	}
+
+	if err := app.storage.Project.Create(r.Context(), project, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, project)
+}
+
+func (app *application) getMyProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	projects, err := app.storage.Project.GetByMemberID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, projects)
+}
+
+func (app *application) getProjectHandler(w http.ResponseWriter, r *http.Request) {
+	app.OutputJSON(w, http.StatusOK, getProjectFromCtx(r))
+}
+
+func (app *application) updateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProjectPayload
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.Name != nil {
+		project.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		project.Description = *payload.Description
+	}
+
+	if err := app.storage.Project.Update(r.Context(), project, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, project)
+}
+
+func (app *application) deleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromCtx(r)
+
+	if err := app.storage.Project.Delete(r.Context(), project.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addProjectMemberHandler - only the owner can add managers
+func (app *application) addProjectMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ProjectMemberPayload
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if payload.Role == storage.ProjectRoleManager && user.ID != project.OwnerID {
+		app.forbiddenError(w, r, fmt.Errorf("only the project owner can add managers"))
+		return
+	}
+
+	newMember, err := app.storage.User.GetByID(ctx, payload.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	member := storage.ProjectMember{
+		UserID: newMember.ID,
+		Role:   payload.Role,
+		Trade:  newMember.Role,
+	}
+
+	if err := app.storage.Project.AddMember(ctx, project.ID, user.ID, member); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrAlreadyMember):
+			app.conflictError(w, r, "ALREADY_MEMBER", err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, member)
+}
+
+func (app *application) updateProjectMemberHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProjectMemberPayload
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "userID"))
+	if err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	if memberID == project.OwnerID {
+		app.badRequestError(w, r, fmt.Errorf("the role of the project owner can not be changed"))
+		return
+	}
+
+	if err := app.storage.Project.UpdateMemberRole(r.Context(), project.ID, user.ID, memberID, payload.Role); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotMember):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeProjectMemberHandler - managers remove members ranked below them, anyone but the owner can leave
+func (app *application) removeProjectMemberHandler(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+	actor := project.Member(user.ID)
+
+	memberID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "userID"))
+	if err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid user ID"))
+		return
+	}
+
+	target := project.Member(memberID)
+	if target == nil {
+		app.notFoundError(w, r, storage.ErrNotMember)
+		return
+	}
+
+	if target.Role == storage.ProjectRoleOwner {
+		app.badRequestError(w, r, fmt.Errorf("the project owner can not be removed"))
+		return
+	}
+
+	leaving := memberID == user.ID
+	if !leaving && (!actor.Role.AtLeast(storage.ProjectRoleManager) || target.Role.AtLeast(actor.Role)) {
+		app.forbiddenError(w, r, fmt.Errorf("not allowed to remove this member"))
+		return
+	}
+
+	if err := app.storage.Project.RemoveMember(r.Context(), project.ID, user.ID, memberID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotMember):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) createProjectUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateProjectUpdatePayload
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	for _, key := range payload.Images {
+		if !ownsS3Key(user.ID, key) {
+			app.badRequestError(w, r, fmt.Errorf("image key '%s' is not the correct format", key))
+			return
+		}
+	}
+
+	update := &storage.ProjectUpdate{
+		ProjectID: project.ID,
+		AuthorID:  user.ID,
+		Content:   payload.Content,
+		Images:    payload.Images,
+	}
+
+	if err := app.storage.Project.CreateUpdate(ctx, update); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.projectUpdateKeysToUrl(ctx, update); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, update)
+}
+
+func (app *application) projectUpdateKeysToUrl(ctx context.Context, update *storage.ProjectUpdate) error {
+	urls, err := app.presignS3Keys(ctx, update.Images)
+	if err != nil {
+		return err
+	}
+
+	update.Images = urls
+	return nil
+}
+
+func (app *application) getProjectUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromCtx(r)
+	ctx := r.Context()
+
+	cq := storage.CursorQuery{
+		Limit: 10,
+		Sort:  "desc",
+	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	updates, err := app.storage.Project.GetUpdates(ctx, project.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range updates {
+		if err := app.projectUpdateKeysToUrl(ctx, &updates[i]); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	var nextCursor *string
+	if len(updates) >= cq.Limit {
+		cursor := updates[len(updates)-1].ID.Hex()
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, projectUpdatesResponse{
+		Updates:    updates,
+		NextCursor: nextCursor,
+	})
+}
+
+func (app *application) getProjectTasksHandler(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromCtx(r)
+
+	tasks, err := app.storage.Project.GetTasks(r.Context(), project.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, tasks)
+}
+
+func (app *application) createProjectTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateProjectTaskPayload
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	task := &storage.ProjectTask{
+		ProjectID:   project.ID,
+		CreatedBy:   user.ID,
+		Title:       payload.Title,
+		Description: payload.Description,
+		DueDate:     payload.DueDate,
+	}
+
+	if payload.AssigneeID != "" {
+		assigneeID, err := projectAssignee(project, payload.AssigneeID)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		task.AssigneeID = assigneeID
+	}
+
+	if err := app.storage.Project.CreateTask(r.Context(), task); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, task)
+}
+
+func (app *application) updateProjectTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProjectTaskPayload
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	task, err := app.storage.Project.GetTask(ctx, project.ID, chi.URLParam(r, "taskID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrProjectTaskNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if payload.Title != nil {
+		task.Title = *payload.Title
+	}
+	if payload.Description != nil {
+		task.Description = *payload.Description
+	}
+
+	if payload.AssigneeID != nil {
+		task.AssigneeID = nil
+		if *payload.AssigneeID != "" {
+			assigneeID, err := projectAssignee(project, *payload.AssigneeID)
+			if err != nil {
+				app.badRequestError(w, r, err)
+				return
+			}
+			task.AssigneeID = assigneeID
+		}
+	}
+
+	if payload.DueDate != nil {
+		task.DueDate = nil
+		if *payload.DueDate != "" {
+			dueDate, err := time.Parse(time.RFC3339, *payload.DueDate)
+			if err != nil {
+				app.badRequestError(w, r, fmt.Errorf("invalid due date: %w", err))
+				return
+			}
+			task.DueDate = &dueDate
+		}
+	}
+
+	justCompleted := false
+	if payload.Completed != nil && *payload.Completed != task.Completed {
+		task.Completed = *payload.Completed
+		task.CompletedAt = nil
+		if task.Completed {
+			now := time.Now()
+			task.CompletedAt = &now
+			justCompleted = true
+		}
+	}
+
+	if err := app.storage.Project.UpdateTask(ctx, task, user.ID, justCompleted); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, task)
+}
+
+// deleteProjectTaskHandler - managers or the creator of the task
+func (app *application) deleteProjectTaskHandler(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	task, err := app.storage.Project.GetTask(ctx, project.ID, chi.URLParam(r, "taskID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrProjectTaskNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if task.CreatedBy != user.ID && !project.Member(user.ID).Role.AtLeast(storage.ProjectRoleManager) {
+		app.forbiddenError(w, r, fmt.Errorf("not allowed to delete this task"))
+		return
+	}
+
+	if err := app.storage.Project.DeleteTask(ctx, task, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) getProjectDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromCtx(r)
+	ctx := r.Context()
+
+	documents, err := app.storage.Project.GetDocuments(ctx, project.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range documents {
+		url, err := app.awsPresigner.GetImageURL(ctx, documents[i].Key, app.config.awsConfig.exp)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		documents[i].URL = url
+	}
+
+	app.OutputJSON(w, http.StatusOK, documents)
+}
+
+func (app *application) addProjectDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload AddProjectDocumentPayload
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if !ownsS3Key(user.ID, payload.Key) {
+		app.badRequestError(w, r, fmt.Errorf("document key '%s' is not the correct format", payload.Key))
+		return
+	}
+
+	document := &storage.ProjectDocument{
+		ProjectID:  project.ID,
+		UploadedBy: user.ID,
+		Name:       payload.Name,
+		Key:        payload.Key,
+	}
+
+	if err := app.storage.Project.AddDocument(r.Context(), document); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, document)
+}
+
+// removeProjectDocumentHandler - managers or the uploader of the document
+func (app *application) removeProjectDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	document, err := app.storage.Project.GetDocument(ctx, project.ID, chi.URLParam(r, "documentID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrProjectDocumentNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if document.UploadedBy != user.ID && !project.Member(user.ID).Role.AtLeast(storage.ProjectRoleManager) {
+		app.forbiddenError(w, r, fmt.Errorf("not allowed to remove this document"))
+		return
+	}
+
+	if err := app.storage.Project.RemoveDocument(ctx, document, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.awsPresigner.DeleteImage(ctx, document.Key); err != nil {
+		app.logger.Errorw("failed to delete project document", "key", document.Key, "error", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) getProjectActivityHandler(w http.ResponseWriter, r *http.Request) {
+	project := getProjectFromCtx(r)
+
+	cq := storage.CursorQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	activity, err := app.storage.Project.GetActivity(r.Context(), project.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(activity) >= cq.Limit {
+		cursor := activity[len(activity)-1].ID.Hex()
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, projectActivityResponse{
+		Activity:   activity,
+		NextCursor: nextCursor,
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReactionPayload struct {
+	Type storage.ReactionType `json:"type" validate:"required,oneof=like love helpful wow"`
+}
+
+type reactionResponse struct {
+	ReactionCounts storage.ReactionCounts `json:"reaction_counts"`
+	ViewerReaction storage.ReactionType   `json:"viewer_reaction,omitempty"`
+}
+
+// attachViewerStatus - mark saved posts, poll votes and the user's own reaction on a page of posts, and embed shared originals
+func (app *application) attachViewerStatus(ctx context.Context, user *storage.User, posts []storage.PostWithLikeStatus) error {
+	if err := app.attachSavedStatus(ctx, user, posts); err != nil {
+		return err
+	}
+
+	if err := app.attachSharedPosts(ctx, user, posts); err != nil {
+		return err
+	}
+
+	if err := app.attachPollStatus(ctx, user, feedPolls(posts)); err != nil {
+		return err
+	}
+
+	postIDs := make([]primitive.ObjectID, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.Post.ID)
+	}
+
+	reactions, err := app.storage.Reaction.GetUserReactions(ctx, storage.ReactionOnPost, user.ID, postIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].ViewerReaction = reactions[posts[i].Post.ID]
+	}
+
+	return nil
+}
+
+// attachCommentReactions - mark the user's own reaction on a page of comments
+func (app *application) attachCommentReactions(ctx context.Context, user *storage.User, comments []storage.CommentWithParentAndUser) error {
+	commentIDs := make([]primitive.ObjectID, 0, len(comments))
+	for _, comment := range comments {
+		commentIDs = append(commentIDs, comment.ID)
+	}
+
+	reactions, err := app.storage.Reaction.GetUserReactions(ctx, storage.ReactionOnComment, user.ID, commentIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].ViewerReaction = reactions[comments[i].ID]
+	}
+
+	return nil
+}
+
+// reactToPostHandler - the like reaction of a post is the post's like and earns the author its reputation
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if reactionType, ok := app.react(w, r, storage.ReactionOnPost, post.ID); ok {
+		app.likeReputation(r.Context(), post, getUserFromCtx(r).ID, reactionType == storage.ReactionLike)
+	}
+}
+
+func (app *application) unreactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if app.unreact(w, r, storage.ReactionOnPost, post.ID) {
+		app.likeReputation(r.Context(), post, getUserFromCtx(r).ID, false)
+	}
+}
+
+func (app *application) reactToCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.getCommentFromURL(w, r)
+	if !ok {
+		return
+	}
+	app.react(w, r, storage.ReactionOnComment, comment.ID)
+}
+
+func (app *application) unreactToCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.getCommentFromURL(w, r)
+	if !ok {
+		return
+	}
+	app.unreact(w, r, storage.ReactionOnComment, comment.ID)
+}
+
+// react - returns the reaction that was set, false if an error response was written
+func (app *application) react(w http.ResponseWriter, r *http.Request, target storage.ReactionTarget, targetID primitive.ObjectID) (storage.ReactionType, bool) {
+	user := getUserFromCtx(r)
+
+	var payload ReactionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return "", false
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return "", false
+	}
+
+	counts, err := app.storage.Reaction.React(r.Context(), target, targetID, user.ID, payload.Type)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return "", false
+	}
+
+	app.OutputJSON(w, http.StatusOK, reactionResponse{
+		ReactionCounts: counts,
+		ViewerReaction: payload.Type,
+	})
+	return payload.Type, true
+}
+
+// unreact - false if an error response was written
+func (app *application) unreact(w http.ResponseWriter, r *http.Request, target storage.ReactionTarget, targetID primitive.ObjectID) bool {
+	user := getUserFromCtx(r)
+
+	counts, err := app.storage.Reaction.Unreact(r.Context(), target, targetID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrReactionNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return false
+	}
+
+	app.OutputJSON(w, http.StatusOK, reactionResponse{ReactionCounts: counts})
+	return true
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QuotePostPayload - a quote is a post with commentary about the original
+type QuotePostPayload struct {
+	Title   string   `json:"title" validate:"omitempty,max=150"`
+	Content string   `json:"content" validate:"required,max=1500"`
+	Tags    []string `json:"tags" validate:"omitempty,dive,required"`
+}
+
+// postResponse - a single post, reposts and quotes also carry their original
+type postResponse struct {
+	*storage.Post
+	Original *storage.SharedPost `json:"original,omitempty"`
+}
+
+// shareTarget - reposting a repost shares its original instead
+func shareTarget(post *storage.Post) primitive.ObjectID {
+	if post.Type == storage.PostRepost && post.RepostOf != nil {
+		return *post.RepostOf
+	}
+	return post.ID
+}
+
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	original := getPostFromCtx(r)
+	originalID := shareTarget(original)
+
+	repost := &storage.Post{
+		UserID:   user.ID,
+		UserRole: user.Role,
+		Type:     storage.PostRepost,
+		RepostOf: &originalID,
+		Version:  1,
+	}
+
+	if err := app.storage.Post.Share(r.Context(), repost); err != nil {
+		app.shareError(w, r, err)
+		return
+	}
+
+	app.fanOutPost(r.Context(), repost.ID)
+
+	app.OutputJSON(w, http.StatusCreated, repost)
+}
+
+func (app *application) unrepostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	original := getPostFromCtx(r)
+
+	repostID, err := app.storage.Post.Unrepost(r.Context(), user.ID, shareTarget(original))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.removeFromTimelines(r.Context(), repostID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) quotePostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+	original := getPostFromCtx(r)
+	var payload QuotePostPayload
+
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	mentions, mentionEntities, err := app.resolveMentions(ctx, payload.Content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	originalID := shareTarget(original)
+	quote := &storage.Post{
+		UserID:          user.ID,
+		UserRole:        user.Role,
+		Type:            storage.PostQuote,
+		Title:           payload.Title,
+		Content:         payload.Content,
+		Tags:            postTags(payload.Tags, payload.Content),
+		Mentions:        mentions,
+		MentionEntities: mentionEntities,
+		RepostOf:        &originalID,
+		Version:         1,
+	}
+
+	if err := app.storage.Post.Share(ctx, quote); err != nil {
+		app.shareError(w, r, err)
+		return
+	}
+
+	app.useTags(ctx, quote)
+	app.fanOutPost(ctx, quote.ID)
+
+	app.OutputJSON(w, http.StatusCreated, quote)
+}
+
+func (app *application) shareError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrAlreadyReposted):
+		app.conflictError(w, r, "ALREADY_REPOSTED", err)
+	case errors.Is(err, storage.ErrNotShareable):
+		app.badRequestError(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getSharedPosts - originals of the given reposts and quotes, with author and the viewer's liked status
+// deleted or unpublished originals are returned as unavailable
+func (app *application) getSharedPosts(ctx context.Context, user *storage.User, originalIDs []primitive.ObjectID) (map[primitive.ObjectID]*storage.SharedPost, error) {
+	shared := make(map[primitive.ObjectID]*storage.SharedPost, len(originalIDs))
+	if len(originalIDs) == 0 {
+		return shared, nil
+	}
+
+	originals, err := app.storage.Post.GetByIDs(ctx, originalIDs, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// anonymous viewers have liked nothing
+	liked := make(map[primitive.ObjectID]bool)
+	if user != nil {
+		liked, err = app.storage.Post.GetLikedPostIDs(ctx, user.ID, originalIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	usernames := make(map[primitive.ObjectID]string)
+	for i := range originals {
+		original := &originals[i]
+		if original.Status != storage.PostPublished {
+			continue
+		}
+
+		if _, ok := usernames[original.UserID]; !ok {
+			author, err := app.storage.User.GetByID(ctx, original.UserID.Hex())
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch username for userID %s: %w", original.UserID.Hex(), err)
+			}
+			usernames[original.UserID] = author.Username
+		}
+
+		shared[original.ID] = &storage.SharedPost{
+			Post:        original,
+			Username:    usernames[original.UserID],
+			LikedByUser: liked[original.ID],
+		}
+	}
+
+	for _, id := range originalIDs {
+		if _, ok := shared[id]; !ok {
+			shared[id] = &storage.SharedPost{Unavailable: true}
+		}
+	}
+
+	return shared, nil
+}
+
+// attachSharedPosts - embed the original of every repost and quote on a page of posts
+func (app *application) attachSharedPosts(ctx context.Context, user *storage.User, posts []storage.PostWithLikeStatus) error {
+	var originalIDs []primitive.ObjectID
+	for _, post := range posts {
+		if post.Post.RepostOf != nil {
+			originalIDs = append(originalIDs, *post.Post.RepostOf)
+		}
+	}
+
+	shared, err := app.getSharedPosts(ctx, user, originalIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		if posts[i].Post.RepostOf != nil {
+			posts[i].Original = shared[*posts[i].Post.RepostOf]
+		}
+	}
+
+	return nil
+}
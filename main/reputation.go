@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type reputationResponse struct {
+	Reputation int64                     `json:"reputation"`
+	Badges     []storage.Badge           `json:"badges"`
+	Entries    []storage.ReputationEntry `json:"entries"`
+	NextCursor *string                   `json:"next_cursor"`
+}
+
+// recordReputation - failures are logged, the recompute job restores entries missed here
+func (app *application) recordReputation(ctx context.Context, userID primitive.ObjectID, event storage.ReputationEvent, key string) {
+	if err := app.storage.Reputation.Record(ctx, userID, event, key); err != nil {
+		app.logger.Errorw("failed to record reputation", "user_id", userID.Hex(), "key", key, "error", err.Error())
+	}
+}
+
+// revokeReputation - failures are logged, the recompute job drops entries whose source is gone
+func (app *application) revokeReputation(ctx context.Context, key string) {
+	if err := app.storage.Reputation.Revoke(ctx, key); err != nil {
+		app.logger.Errorw("failed to revoke reputation", "key", key, "error", err.Error())
+	}
+}
+
+// likeReputation - the author earns a like received while the user likes the post, liking your own post earns nothing
+func (app *application) likeReputation(ctx context.Context, post *storage.Post, userID primitive.ObjectID, liked bool) {
+	if post.UserID == userID {
+		return
+	}
+
+	key := storage.LikeKey(post.ID, userID)
+	if liked {
+		app.recordReputation(ctx, post.UserID, storage.RepLikeReceived, key)
+	} else {
+		app.revokeReputation(ctx, key)
+	}
+}
+
+// recomputeReputation - rebuild the ledger, reputation and badges of every user
+func (app *application) recomputeReputation(ctx context.Context) error {
+	return app.storage.Reputation.Recompute(ctx)
+}
+
+// getUserReputationHandler - the user's reputation, badges and a page of the ledger, most recent first
+func (app *application) getUserReputationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := app.storage.User.GetByID(ctx, chi.URLParam(r, "userID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	cq := storage.CursorQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	entries, err := app.storage.Reputation.GetEntries(ctx, user.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(entries) >= cq.Limit {
+		cursor := entries[len(entries)-1].ID.Hex()
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, reputationResponse{
+		Reputation: user.Reputation,
+		Badges:     user.Badges,
+		Entries:    entries,
+		NextCursor: nextCursor,
+	})
+}
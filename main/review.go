@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
 )
 
@@ -57,6 +58,8 @@ func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.recordReputation(ctx, ratedUser.ID, storage.RepReviewReceived, storage.ReviewKey(review.ID))
+
 	app.OutputJSON(w, http.StatusCreated, review)
 }
 
@@ -117,5 +120,9 @@ func (app *application) deleteReviewHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if id, err := primitive.ObjectIDFromHex(reviewID); err == nil {
+		app.revokeReputation(ctx, storage.ReviewKey(id))
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
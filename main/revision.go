@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+)
+
+type RestoreRevisionPayload struct {
+	Version int64 `json:"version" validate:"required"` // current version of the post
+}
+
+type revisionListResponse struct {
+	Revisions  []storage.PostRevision `json:"revisions"`
+	NextCursor *string                `json:"next_cursor"`
+}
+
+// postUpdateError - map errors from PostStorage.Update to a response
+func (app *application) postUpdateError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrVersionConflict):
+		app.conflictError(w, r, "VERSION_MISMATCH", fmt.Errorf("version mismatch"))
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// revisionKeysToUrl - turn images of a revision saved as s3 object keys into url
+func (app *application) revisionKeysToUrl(ctx context.Context, revision *storage.PostRevision) error {
+	post := storage.Post{Images: revision.Images, Hotspots: revision.Hotspots, Showcase: revision.Showcase}
+	if err := app.s3KeysToUrl(ctx, &post); err != nil {
+		return err
+	}
+
+	revision.Images = post.Images
+	revision.Hotspots = post.Hotspots
+	return nil
+}
+
+// getRevisionFromURL - revision of the post in ctx by the {version} url param
+func (app *application) getRevisionFromURL(w http.ResponseWriter, r *http.Request) (*storage.PostRevision, bool) {
+	post := getPostFromCtx(r)
+
+	version, err := strconv.ParseInt(chi.URLParam(r, "version"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid version: %w", err))
+		return nil, false
+	}
+
+	revision, err := app.storage.Post.GetRevision(r.Context(), post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrRevisionNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return revision, true
+}
+
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	cq := storage.CursorQuery{
+		Limit: 10,
+		Sort:  "desc",
+	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	revisions, err := app.storage.Post.GetRevisions(r.Context(), post.ID, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range revisions {
+		if err := app.revisionKeysToUrl(r.Context(), &revisions[i]); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	var nextCursor *string
+	if len(revisions) >= cq.Limit {
+		cursor := revisions[len(revisions)-1].ID.Hex()
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, revisionListResponse{
+		Revisions:  revisions,
+		NextCursor: nextCursor,
+	})
+}
+
+func (app *application) getPostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	revision, ok := app.getRevisionFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.revisionKeysToUrl(r.Context(), revision); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, revision)
+}
+
+// restorePostRevisionHandler - restoring writes the old content as a new version, history is never rewritten
+func (app *application) restorePostRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	var payload RestoreRevisionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	revision, ok := app.getRevisionFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	post.Version = payload.Version
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Tags = revision.Tags
+	post.Mentions = revision.Mentions
+	post.MentionEntities = revision.MentionEntities
+	post.Images = revision.Images
+	post.Hotspots = revision.Hotspots
+
+	// collaborators keep their current confirmation
+	if revision.Showcase != nil && post.Showcase != nil {
+		revision.Showcase.Collaborators = post.Showcase.Collaborators
+	}
+	post.Showcase = revision.Showcase
+
+	if post.Status == storage.PostPublished {
+		now := time.Now()
+		post.EditedAt = &now
+	}
+
+	if err := app.storage.Post.Update(r.Context(), post); err != nil {
+		app.postUpdateError(w, r, err)
+		return
+	}
+
+	if err := app.s3KeysToUrl(r.Context(), post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, post)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// job - background task the scheduler runs every interval
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// scheduler - runs background jobs until stopped during graceful shutdown
+type scheduler struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startScheduler - start one goroutine per job, each job also runs once on startup
+func (app *application) startScheduler(jobs []job) *scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &scheduler{cancel: cancel}
+
+	for _, j := range jobs {
+		s.wg.Add(1)
+		go func(j job) {
+			defer s.wg.Done()
+
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+
+			for {
+				if err := j.run(ctx); err != nil && ctx.Err() == nil {
+					app.logger.Errorw("scheduled job failed", "job", j.name, "error", err.Error())
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(j)
+	}
+
+	app.logger.Infow("scheduler started", "jobs", len(jobs))
+	return s
+}
+
+// stop - cancel running jobs and wait for them to return
+func (s *scheduler) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// jobs - background jobs of the application
+func (app *application) jobs() []job {
+	return []job{
+		{name: "publish_scheduled_posts", interval: app.config.schedulerConfig.publishInterval, run: app.publishDuePosts},
+		{name: "purge_trash", interval: app.config.schedulerConfig.purgeInterval, run: app.purgeTrash},
+		{name: "refresh_tag_counts", interval: app.config.schedulerConfig.tagInterval, run: app.refreshTagCounts},
+		{name: "recompute_reputation", interval: app.config.schedulerConfig.reputationInterval, run: app.recomputeReputation},
+		{name: "refresh_trending_scores", interval: app.config.schedulerConfig.trendingInterval, run: app.refreshTrendingScores},
+	}
+}
+
+// publishDuePosts - publish scheduled posts whose publish time has passed
+func (app *application) publishDuePosts(ctx context.Context) error {
+	postIDs, err := app.storage.Post.PublishDue(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, postID := range postIDs {
+		app.fanOutPost(ctx, postID)
+	}
+
+	if len(postIDs) > 0 {
+		app.logger.Infow("published scheduled posts", "count", len(postIDs))
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+)
+
+// searchSection - typed section of the unified search, each one paginates on its own
+type searchSection string
+
+const (
+	sectionPeople   searchSection = "people"
+	sectionPosts    searchSection = "posts"
+	sectionTags     searchSection = "tags"
+	sectionProducts searchSection = "products"
+)
+
+var searchSections = []searchSection{sectionPeople, sectionPosts, sectionTags, sectionProducts}
+
+// maxSearchQuery - longest accepted search query, in bytes
+const maxSearchQuery = 100
+
+type peopleSearchResponse struct {
+	People     []storage.UserSummary `json:"people"`
+	NextCursor *string               `json:"next_cursor"`
+}
+
+type tagSearchResponse struct {
+	Tags       []storage.Tag `json:"tags"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+// unifiedSearchResponse - only the requested sections are set
+type unifiedSearchResponse struct {
+	Query    string                `json:"query"`
+	People   *peopleSearchResponse `json:"people,omitempty"`
+	Posts    *feedResponse         `json:"posts,omitempty"`
+	Tags     *tagSearchResponse    `json:"tags,omitempty"`
+	Products *productListResponse  `json:"products,omitempty"`
+}
+
+type typeaheadResponse struct {
+	Users []storage.UserSummary `json:"users"`
+	Tags  []storage.Tag         `json:"tags"`
+}
+
+func parseSearchSection(value string) (searchSection, error) {
+	for _, section := range searchSections {
+		if string(section) == value {
+			return section, nil
+		}
+	}
+	return "", fmt.Errorf("invalid search section: %s", value)
+}
+
+// parseSearchQuery - ?q= is required, filters of the sections come from the cursor query
+func parseSearchQuery(r *http.Request, cq *storage.CursorQuery) error {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		return fmt.Errorf("search query is required")
+	}
+	if len(query) > maxSearchQuery {
+		return fmt.Errorf("search query must be at most %d characters", maxSearchQuery)
+	}
+
+	if err := cq.Parse(r); err != nil {
+		return err
+	}
+	cq.Search = query
+
+	return Validate.Struct(cq)
+}
+
+// searchSection - fill one section of the response, cq.Cursor pages within that section only
+func (app *application) searchSection(ctx context.Context, user *storage.User, section searchSection, cq storage.CursorQuery, response *unifiedSearchResponse) error {
+	switch section {
+	case sectionPeople:
+		people, err := app.storage.User.Search(ctx, cq.Search, cq)
+		if err != nil {
+			return err
+		}
+
+		var nextCursor *string
+		if len(people) >= cq.Limit {
+			cursor := storage.UserSearchCursor(people[len(people)-1])
+			nextCursor = &cursor
+		}
+		response.People = &peopleSearchResponse{People: people, NextCursor: nextCursor}
+
+	case sectionPosts:
+		posts, err := app.searchPosts(ctx, user, cq)
+		if err != nil {
+			return err
+		}
+		response.Posts = posts
+
+	case sectionTags:
+		tags, err := app.storage.Tag.Search(ctx, cq.Search, cq)
+		if err != nil {
+			return err
+		}
+
+		var nextCursor *string
+		if len(tags) >= cq.Limit {
+			cursor := storage.TagSearchCursor(tags[len(tags)-1])
+			nextCursor = &cursor
+		}
+		response.Tags = &tagSearchResponse{Tags: tags, NextCursor: nextCursor}
+
+	case sectionProducts:
+		pq := storage.ProductQuery{Limit: cq.Limit, Cursor: cq.Cursor, Search: cq.Search}
+		products, err := app.storage.Product.Browse(ctx, pq)
+		if err != nil {
+			return err
+		}
+
+		for i := range products {
+			if err := app.productKeysToUrl(ctx, &products[i]); err != nil {
+				return err
+			}
+		}
+
+		var nextCursor *string
+		if len(products) >= pq.Limit {
+			cursor := products[len(products)-1].ID.Hex()
+			nextCursor = &cursor
+		}
+		response.Products = &productListResponse{Products: products, NextCursor: nextCursor}
+	}
+
+	return nil
+}
+
+// unifiedSearchHandler - ?q= across ?sections=people,posts,tags,products (all by default),
+// first page of each section, later pages come from the section endpoint
+func (app *application) unifiedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	cq := storage.CursorQuery{
+		Limit:      5,
+		Sort:       "desc",
+		SearchSort: storage.SearchRelevance,
+	}
+
+	if err := parseSearchQuery(r, &cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	cq.Cursor = ""
+
+	sections := searchSections
+	if value := r.URL.Query().Get("sections"); value != "" && value != "undefined" {
+		sections = nil
+		for _, name := range strings.Split(value, ",") {
+			section, err := parseSearchSection(strings.TrimSpace(name))
+			if err != nil {
+				app.badRequestError(w, r, err)
+				return
+			}
+			sections = append(sections, section)
+		}
+	}
+
+	response := unifiedSearchResponse{Query: cq.Search}
+	for _, section := range sections {
+		if err := app.searchSection(ctx, user, section, cq, &response); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.OutputJSON(w, http.StatusOK, response)
+}
+
+// searchSectionHandler - next pages of one section of the unified search
+func (app *application) searchSectionHandler(w http.ResponseWriter, r *http.Request) {
+	section, err := parseSearchSection(chi.URLParam(r, "section"))
+	if err != nil {
+		app.notFoundError(w, r, err)
+		return
+	}
+
+	cq := storage.CursorQuery{
+		Limit:      10,
+		Sort:       "desc",
+		SearchSort: storage.SearchRelevance,
+	}
+
+	if err := parseSearchQuery(r, &cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	response := unifiedSearchResponse{Query: cq.Search}
+	if err := app.searchSection(r.Context(), getUserFromCtx(r), section, cq, &response); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, response)
+}
+
+// typeaheadHandler - composer completion, ?q=@prefix completes usernames, ?q=#prefix tags, a bare prefix both
+func (app *application) typeaheadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit := parseTagLimit(r)
+
+	response := typeaheadResponse{Users: []storage.UserSummary{}, Tags: []storage.Tag{}}
+
+	completeUsers := !strings.HasPrefix(query, "#")
+	completeTags := !strings.HasPrefix(query, "@")
+	prefix := strings.TrimLeft(query, "@#")
+
+	if prefix == "" || len(prefix) > maxSearchQuery {
+		app.OutputJSON(w, http.StatusOK, response)
+		return
+	}
+
+	if completeUsers {
+		users, err := app.storage.User.Autocomplete(ctx, prefix, limit)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		response.Users = users
+	}
+
+	if tag := storage.NormalizeTag(prefix); completeTags && tag != "" {
+		tags, err := app.storage.Tag.Autocomplete(ctx, tag, limit)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		response.Tags = tags
+	}
+
+	app.OutputJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errInvalidShowcase = errors.New("invalid showcase")
+
+// ShowcasePayload - before/after transformation details of a showcase post
+type ShowcasePayload struct {
+	BeforeImages    []string           `json:"before_images" validate:"required,min=1,max=10,dive,required"`
+	AfterImages     []string           `json:"after_images" validate:"required,min=1,max=10,dive,required"`
+	RoomType        string             `json:"room_type" validate:"required,oneof=kitchen bathroom living_room bedroom dining_room basement office outdoor exterior whole_home other"`
+	Style           string             `json:"style" validate:"omitempty,max=50"`
+	BudgetBand      storage.BudgetBand `json:"budget_band" validate:"required,oneof=under_10k 10k_25k 25k_50k 50k_100k over_100k undisclosed"`
+	DurationWeeks   int                `json:"duration_weeks" validate:"omitempty,gte=1,lte=520"`
+	Materials       []string           `json:"materials" validate:"omitempty,max=20,dive,required,max=50"`
+	CollaboratorIDs []string           `json:"collaborator_ids" validate:"omitempty,max=10,dive,hexadecimal,len=24"`
+}
+
+// buildShowcase - collaborators already tagged on the post keep their confirmation
+func (app *application) buildShowcase(ctx context.Context, author *storage.User, payload *ShowcasePayload, existing *storage.Showcase) (*storage.Showcase, error) {
+	for _, keys := range [][]string{payload.BeforeImages, payload.AfterImages} {
+		for _, key := range keys {
+			if !ownsS3Key(author.ID, key) {
+				return nil, fmt.Errorf("%w: image key '%s' is not the correct format", errInvalidShowcase, key)
+			}
+		}
+	}
+
+	previous := make(map[primitive.ObjectID]storage.ShowcaseCollaborator)
+	if existing != nil {
+		for _, c := range existing.Collaborators {
+			previous[c.UserID] = c
+		}
+	}
+
+	collaborators := make([]storage.ShowcaseCollaborator, 0, len(payload.CollaboratorIDs))
+	seen := make(map[primitive.ObjectID]bool)
+	for _, id := range payload.CollaboratorIDs {
+		user, err := app.storage.User.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if user.ID == author.ID {
+			return nil, fmt.Errorf("%w: you cannot tag yourself as a collaborator", errInvalidShowcase)
+		}
+		if seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+
+		if c, ok := previous[user.ID]; ok {
+			collaborators = append(collaborators, c)
+			continue
+		}
+		collaborators = append(collaborators, storage.ShowcaseCollaborator{UserID: user.ID})
+	}
+
+	return &storage.Showcase{
+		BeforeImages:  payload.BeforeImages,
+		AfterImages:   payload.AfterImages,
+		RoomType:      payload.RoomType,
+		Style:         payload.Style,
+		BudgetBand:    payload.BudgetBand,
+		DurationWeeks: payload.DurationWeeks,
+		Materials:     payload.Materials,
+		Collaborators: collaborators,
+	}, nil
+}
+
+// showcaseError - map errors from buildShowcase to a response
+func (app *application) showcaseError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errInvalidShowcase):
+		app.badRequestError(w, r, err)
+	case errors.Is(err, storage.ErrUserNotFound):
+		app.notFoundError(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// showcaseKeysToUrl - turn before/after images saved as s3 object keys into url
+func (app *application) showcaseKeysToUrl(ctx context.Context, showcase *storage.Showcase) error {
+	before, err := app.presignS3Keys(ctx, showcase.BeforeImages)
+	if err != nil {
+		return err
+	}
+
+	after, err := app.presignS3Keys(ctx, showcase.AfterImages)
+	if err != nil {
+		return err
+	}
+
+	showcase.BeforeImages = before
+	showcase.AfterImages = after
+	return nil
+}
+
+func (app *application) confirmCollaborationHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondCollaboration(w, r, true)
+}
+
+func (app *application) declineCollaborationHandler(w http.ResponseWriter, r *http.Request) {
+	app.respondCollaboration(w, r, false)
+}
+
+func (app *application) respondCollaboration(w http.ResponseWriter, r *http.Request, confirm bool) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	if err := app.storage.Post.RespondCollaboration(r.Context(), post.ID, user.ID, confirm); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotCollaborator):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPendingCollaborationsHandler - showcase posts the user was tagged on and still has to confirm
+func (app *application) getPendingCollaborationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	posts, err := app.storage.Post.GetPendingCollaborations(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, posts)
+}
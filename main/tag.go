@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+)
+
+// hashtagRegexp - '#' at the start or after whitespace, so url fragments aren't hashtags
+var hashtagRegexp = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_]+)`)
+
+// trendingTagWindows - time windows of the trending tags endpoint
+var trendingTagWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+type tagListResponse struct {
+	Tags []storage.Tag `json:"tags"`
+}
+
+type trendingTagsResponse struct {
+	Window string                `json:"window"`
+	Tags   []storage.TrendingTag `json:"tags"`
+}
+
+// extractHashtags - every #hashtag in the text, not normalized yet
+func extractHashtags(text string) []string {
+	matches := hashtagRegexp.FindAllStringSubmatch(text, -1)
+
+	var hashtags []string
+	for _, match := range matches {
+		hashtags = append(hashtags, match[1])
+	}
+
+	return hashtags
+}
+
+// postTags - normalized tags of the post, explicit tags first then hashtags from the content
+func postTags(tags []string, content string) []string {
+	return storage.NormalizeTags(append(append([]string{}, tags...), extractHashtags(content)...))
+}
+
+// useTags - tags are recounted by a background job, a failure here only delays autocomplete
+func (app *application) useTags(ctx context.Context, post *storage.Post) {
+	if err := app.storage.Tag.Use(ctx, post.Tags); err != nil {
+		app.logger.Errorw("failed to save post tags", "post_id", post.ID.Hex(), "error", err.Error())
+	}
+}
+
+// refreshTagCounts - recount published posts per tag
+func (app *application) refreshTagCounts(ctx context.Context) error {
+	return app.storage.Tag.RefreshCounts(ctx)
+}
+
+func (app *application) getTagFromURL(w http.ResponseWriter, r *http.Request) (*storage.Tag, bool) {
+	name := storage.NormalizeTag(chi.URLParam(r, "tag"))
+
+	tag, err := app.storage.Tag.GetByName(r.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrTagNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return tag, true
+}
+
+func (app *application) getTagHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	tag, ok := app.getTagFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	following, err := app.storage.Tag.IsFollowing(r.Context(), user.ID, tag.Name)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, storage.TagWithStatus{Tag: *tag, FollowedByUser: following})
+}
+
+// getTagPostsHandler - published posts with the tag, newest first
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	tag, ok := app.getTagFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	cq := storage.CursorQuery{
+		Limit: 10,
+		Sort:  "desc",
+	}
+
+	if err := cq.Parse(r); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// the tag page only lists its own tag, not the user's feed filters
+	cq.Tags = []string{tag.Name}
+	cq.ShowFollowing = false
+	cq.ShowMentioned = false
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	posts, err := app.storage.Post.GetFeed(ctx, user, cq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i, post := range posts {
+		author, err := app.storage.User.GetByID(ctx, post.Post.UserID.Hex())
+		if err != nil {
+			app.internalServerError(w, r, fmt.Errorf("failed to fetch username for userID %s: %w", post.Post.UserID.Hex(), err))
+			return
+		}
+		posts[i].Username = author.Username
+	}
+
+	if err := app.attachViewerStatus(ctx, user, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var nextCursor *string
+	if len(posts) >= cq.Limit {
+		cursor := storage.PostCursor(posts[len(posts)-1].Post)
+		nextCursor = &cursor
+	}
+
+	app.OutputJSON(w, http.StatusOK, feedResponse{
+		PostsWithStatus: posts,
+		NextCursor:      nextCursor,
+	})
+}
+
+func (app *application) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	tag, ok := app.getTagFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.storage.Tag.Follow(r.Context(), user.ID, tag.Name); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, map[string]bool{"isFollowing": true})
+}
+
+func (app *application) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	tag, ok := app.getTagFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.storage.Tag.Unfollow(r.Context(), user.ID, tag.Name); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, map[string]bool{"isFollowing": false})
+}
+
+func (app *application) getFollowedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	tags, err := app.storage.Tag.GetFollowed(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, tags)
+}
+
+// getTrendingTagsHandler - ?window=24h|7d|30d, defaults to 7d
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "7d"
+	}
+
+	duration, ok := trendingTagWindows[window]
+	if !ok {
+		app.badRequestError(w, r, fmt.Errorf("window must be one of 24h, 7d, 30d"))
+		return
+	}
+
+	limit := parseTagLimit(r)
+
+	tags, err := app.storage.Tag.GetTrending(r.Context(), time.Now().Add(-duration), limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, trendingTagsResponse{Window: window, Tags: tags})
+}
+
+// autocompleteTagsHandler - ?q=prefix, most used tags first
+func (app *application) autocompleteTagsHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := storage.NormalizeTag(r.URL.Query().Get("q"))
+	if prefix == "" {
+		app.OutputJSON(w, http.StatusOK, tagListResponse{Tags: []storage.Tag{}})
+		return
+	}
+
+	tags, err := app.storage.Tag.Autocomplete(r.Context(), prefix, parseTagLimit(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, tagListResponse{Tags: tags})
+}
+
+// parseTagLimit - ?limit between 1 and 20, defaults to 10
+func parseTagLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 20 {
+		return 10
+	}
+	return limit
+}
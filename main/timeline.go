@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fanOutPost - failures are logged, the post is still on the author's profile
+func (app *application) fanOutPost(ctx context.Context, postID primitive.ObjectID) {
+	if err := app.storage.Timeline.FanOut(ctx, postID); err != nil {
+		app.logger.Errorw("failed to fan out post", "post_id", postID.Hex(), "error", err.Error())
+	}
+}
+
+// removeFromTimelines - failures are logged, timelines skip deleted posts and purge removes the rest
+func (app *application) removeFromTimelines(ctx context.Context, postID primitive.ObjectID) {
+	if err := app.storage.Timeline.Remove(ctx, postID); err != nil {
+		app.logger.Errorw("failed to remove post from timelines", "post_id", postID.Hex(), "error", err.Error())
+	}
+}
+
+// backfillTimeline - failures are logged, newer posts of the followee still reach the timeline
+func (app *application) backfillTimeline(ctx context.Context, followerID, followeeID primitive.ObjectID) {
+	if err := app.storage.Timeline.Backfill(ctx, followerID, followeeID); err != nil {
+		app.logger.Errorw("failed to backfill timeline", "follower_id", followerID.Hex(), "followee_id", followeeID.Hex(), "error", err.Error())
+	}
+}
+
+// removeAuthorFromTimeline - failures are logged, posts already in the timeline stay until they are deleted
+func (app *application) removeAuthorFromTimeline(ctx context.Context, followerID, followeeID primitive.ObjectID) {
+	if err := app.storage.Timeline.RemoveAuthor(ctx, followerID, followeeID); err != nil {
+		app.logger.Errorw("failed to remove followee from timeline", "follower_id", followerID.Hex(), "followee_id", followeeID.Hex(), "error", err.Error())
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// purgeBatchSize - posts purged per run of the purge job, the rest wait for the next run
+const purgeBatchSize = 100
+
+type trashResponse struct {
+	Posts    []storage.Post    `json:"posts"`
+	Comments []storage.Comment `json:"comments"`
+}
+
+// getTrashHandler - the user's deleted posts and comments that can still be restored
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	posts, err := app.storage.Post.GetTrash(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range posts {
+		if err := app.s3KeysToUrl(ctx, &posts[i]); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	comments, err := app.storage.Comment.GetTrash(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, trashResponse{
+		Posts:    posts,
+		Comments: comments,
+	})
+}
+
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postID"))
+	if err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid post ID"))
+		return
+	}
+
+	if err := app.storage.Post.Restore(r.Context(), postID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.fanOutPost(r.Context(), postID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) restoreCommentHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	commentID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "commentID"))
+	if err != nil {
+		app.badRequestError(w, r, fmt.Errorf("invalid comment ID"))
+		return
+	}
+
+	if err := app.storage.Comment.Restore(r.Context(), commentID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrCommentNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeTrash - permanently remove posts and comments deleted longer than the retention window,
+// a post is only removed from the database once all its images are deleted from S3
+func (app *application) purgeTrash(ctx context.Context) error {
+	cutoff := time.Now().Add(-storage.TrashRetention)
+
+	posts, err := app.storage.Post.GetExpiredTrash(ctx, cutoff, purgeBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		if err := app.purgePost(ctx, &posts[i]); err != nil {
+			app.logger.Errorw("failed to purge post", "post_id", posts[i].ID.Hex(), "error", err.Error())
+		}
+	}
+
+	count, err := app.storage.Comment.Purge(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	if len(posts) > 0 || count > 0 {
+		app.logger.Infow("purged trash", "posts", len(posts), "comments", count)
+	}
+	return nil
+}
+
+func (app *application) purgePost(ctx context.Context, post *storage.Post) error {
+	keys, err := app.storage.Post.ImageKeys(ctx, post)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := app.awsPresigner.DeleteImage(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return app.storage.Post.Purge(ctx, post.ID)
+}
@@ -10,10 +10,15 @@ import (
 )
 
 type UserWithStats struct {
-	User           *storage.User `json:"user"`
-	PostCount      int           `json:"post_count"`
-	FollowerCount  int           `json:"follower_count"`
-	FollowingCount int           `json:"following_count"`
+	User           *storage.User   `json:"user"`
+	PostCount      int             `json:"post_count"`
+	FollowerCount  int             `json:"follower_count"`
+	FollowingCount int             `json:"following_count"`
+	Reputation     int64           `json:"reputation"`
+	Badges         []storage.Badge `json:"badges"`
+	// profile only
+	PinnedPosts []featuredPost    `json:"pinned_posts,omitempty"`
+	Portfolio   []featuredSection `json:"portfolio,omitempty"`
 }
 
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +43,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromCtx(r)
-	postCount, _ := app.storage.Post.GetCountByUserID(r.Context(), user.ID)
+	postCount, _ := app.storage.Post.GetCountByUserID(r.Context(), user.ID, user)
 	followerCount, _ := app.storage.Follow.GetFollowerCount(r.Context(), user.ID)
 	followingCount, _ := app.storage.Follow.GetFollowingCount(r.Context(), user.ID)
 
@@ -47,6 +52,8 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		PostCount:      postCount,
 		FollowerCount:  followerCount,
 		FollowingCount: followingCount,
+		Reputation:     user.Reputation,
+		Badges:         user.Badges,
 	}
 
 	app.OutputJSON(w, http.StatusOK, resp)
@@ -62,7 +69,7 @@ func (app *application) getUserProfileHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	postCount, _ := app.storage.Post.GetCountByUserID(r.Context(), user.ID)
+	postCount, _ := app.storage.Post.GetCountByUserID(r.Context(), user.ID, getUserFromCtx(r))
 	followerCount, _ := app.storage.Follow.GetFollowerCount(r.Context(), user.ID)
 	followingCount, _ := app.storage.Follow.GetFollowingCount(r.Context(), user.ID)
 
@@ -71,6 +78,13 @@ func (app *application) getUserProfileHandler(w http.ResponseWriter, r *http.Req
 		PostCount:      postCount,
 		FollowerCount:  followerCount,
 		FollowingCount: followingCount,
+		Reputation:     user.Reputation,
+		Badges:         user.Badges,
+	}
+
+	if err := app.attachFeatured(ctx, getUserFromCtx(r), &resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
 	app.OutputJSON(w, http.StatusOK, resp)
@@ -154,6 +168,9 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.recordReputation(ctx, followee.ID, storage.RepFollowerGained, storage.FollowKey(followerUserID, followee.ID))
+	app.backfillTimeline(ctx, followerUserID, followee.ID)
+
 	app.OutputJSON(w, http.StatusOK, map[string]bool{"isFollowing": true})
 }
 
@@ -178,5 +195,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.revokeReputation(ctx, storage.FollowKey(followerUserID, followee.ID))
+	app.removeAuthorFromTimeline(ctx, followerUserID, followee.ID)
+
 	app.OutputJSON(w, http.StatusOK, map[string]bool{"isFollowing": false})
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/hnzhou16/project-cocraft-server/internal/storage"
+)
+
+type shareTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// createShareTokenHandler - anyone with the token can open the post, a new token revokes the previous one
+func (app *application) createShareTokenHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if post.Status != storage.PostPublished {
+		app.badRequestError(w, r, errors.New("only published posts can be shared"))
+		return
+	}
+
+	// plain token is only returned once, the post keeps its hash
+	token := uuid.New().String()
+
+	if err := app.storage.Post.SetShareToken(r.Context(), post.ID, token); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.OutputJSON(w, http.StatusCreated, shareTokenResponse{Token: token})
+}
+
+func (app *application) revokeShareTokenHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if err := app.storage.Post.RevokeShareToken(r.Context(), post.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getSharedLinkPostHandler - open a post through its share link without an account
+func (app *application) getSharedLinkPostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	post, err := app.storage.Post.GetByShareToken(ctx, chi.URLParam(r, "token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPostNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.attachPollStatus(ctx, nil, []*storage.Post{post}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.s3KeysToUrl(ctx, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.OutputJSON(w, http.StatusOK, post)
+}
@@ -39,6 +39,9 @@ type config struct {
 	authConfig authConfig
 	awsConfig  awsConfig
 	aiConfig   aiConfig
+
+	commentConfig   commentConfig
+	schedulerConfig schedulerConfig
 }
 
 type dbConfig struct {
@@ -71,6 +74,19 @@ type awsConfig struct {
 	exp             time.Duration
 }
 
+type commentConfig struct {
+	editWindow time.Duration
+	maxDepth   int
+}
+
+type schedulerConfig struct {
+	publishInterval    time.Duration
+	purgeInterval      time.Duration
+	tagInterval        time.Duration
+	reputationInterval time.Duration
+	trendingInterval   time.Duration
+}
+
 type aiConfig struct {
 	apiKey      string
 	apiUrl      string
@@ -125,6 +141,8 @@ func (app *application) mount() *chi.Mux {
 		r.Group(func(r chi.Router) {
 			r.Use(app.authCtxMiddleware)
 			r.Get("/me", app.getUserHandler)
+			r.With(app.RequirePermission(security.PermUser)).
+				Put("/portfolio", app.updatePortfolioHandler)
 
 			// upload and remove images on aws
 			r.Group(func(r chi.Router) {
@@ -140,6 +158,9 @@ func (app *application) mount() *chi.Mux {
 
 			r.Get("/profile", app.getUserProfileHandler)
 			r.Get("/reviews", app.getUserReviewHandler)
+			r.Get("/reputation", app.getUserReputationHandler)
+			r.Get("/products", app.getManufacturerProductsHandler)
+			r.Get("/boards", app.getUserBoardsHandler)
 			// follow/unfollow
 			r.Get("/following", app.getFollowingUserHandler)
 			r.Get("/follow-status", app.followStatusHandler)
@@ -156,11 +177,24 @@ func (app *application) mount() *chi.Mux {
 			r.Use(app.authCtxMiddleware)
 			r.Use(app.RequirePermission(security.PermUser))
 			r.Get("/user", app.getFeedHandler)
+			r.Get("/for-you", app.getForYouFeedHandler)
 			r.Get("/trending", app.getTrendingHandler)
 			r.Get("/search", app.getSearchHandler)
 		})
 	})
 
+	// unified search
+	r.Route("/search", func(r chi.Router) {
+		r.Use(app.authCtxMiddleware)
+		r.Use(app.RequirePermission(security.PermUser))
+		r.Get("/", app.unifiedSearchHandler)
+		r.Get("/typeahead", app.typeaheadHandler)
+		r.Get("/{section}", app.searchSectionHandler)
+	})
+
+	// unlisted share links
+	r.Get("/share/{token}", app.getSharedLinkPostHandler)
+
 	// post
 	r.Route("/post", func(r chi.Router) {
 		r.Use(app.authCtxMiddleware)
@@ -168,6 +202,8 @@ func (app *application) mount() *chi.Mux {
 		r.Post("/", app.createPostHandler)
 		// add '/user', otherwise chi will continue to confuse {userID} with {postID} in the following routes
 		r.Get("/user/{userID}", app.getAllUserPostsHandler)
+		r.Get("/collaborations", app.getPendingCollaborationsHandler)
+		r.Get("/drafts", app.getDraftsHandler)
 
 		r.Route("/{postID}", func(r chi.Router) {
 			r.Use(app.postCtxtMiddleware)
@@ -177,16 +213,174 @@ func (app *application) mount() *chi.Mux {
 				r.Use(app.RequirePostOwnership)
 				r.Patch("/", app.updatePostHandler)
 				r.Delete("/", app.deletePostHandler)
+				r.Post("/publish", app.publishPostHandler)
+				r.Post("/revisions/{version}/restore", app.restorePostRevisionHandler)
+				r.Post("/share-token", app.createShareTokenHandler)
+				r.Delete("/share-token", app.revokeShareTokenHandler)
+				r.Post("/pin", app.pinPostHandler)
+				r.Delete("/pin", app.unpinPostHandler)
 			})
 
+			// edit history
+			r.Get("/revisions", app.getPostRevisionsHandler)
+			r.Get("/revisions/{version}", app.getPostRevisionHandler)
+
 			// like
 			r.Patch("/like", app.toggleLikePostHandler)
+			r.Get("/likes", app.getPostLikesHandler)
+
+			// reposts and quotes
+			r.Post("/repost", app.repostHandler)
+			r.Delete("/repost", app.unrepostHandler)
+			r.Post("/quote", app.quotePostHandler)
+
+			// poll
+			r.Get("/poll", app.getPollHandler)
+			r.Post("/poll/vote", app.votePollHandler)
+			r.Delete("/poll/vote", app.unvotePollHandler)
+
+			// reactions
+			r.Put("/reaction", app.reactToPostHandler)
+			r.Delete("/reaction", app.unreactToPostHandler)
+
+			// showcase collaborator tagging
+			r.Post("/showcase/confirm", app.confirmCollaborationHandler)
+			r.Post("/showcase/decline", app.declineCollaborationHandler)
 
 			// comment
 			r.Route("/comment", func(r chi.Router) {
 				r.Get("/", app.getCommentHandler)
 				r.With(app.RequirePermission(security.PermUser)).
 					Post("/", app.createCommentHandler)
+				r.Get("/{commentID}/replies", app.getCommentRepliesHandler)
+				r.Put("/{commentID}/reaction", app.reactToCommentHandler)
+				r.Delete("/{commentID}/reaction", app.unreactToCommentHandler)
+				r.Patch("/{commentID}/upvote", app.toggleUpvoteAnswerHandler)
+				r.Post("/{commentID}/accept", app.acceptAnswerHandler)
+				r.Delete("/{commentID}/accept", app.unacceptAnswerHandler)
+				r.Patch("/{commentID}", app.updateCommentHandler)
+				r.Delete("/{commentID}", app.deleteCommentHandler)
+			})
+		})
+	})
+
+	// tag
+	r.Route("/tag", func(r chi.Router) {
+		r.Use(app.authCtxMiddleware)
+		r.Use(app.RequirePermission(security.PermUser))
+		r.Get("/trending", app.getTrendingTagsHandler)
+		r.Get("/autocomplete", app.autocompleteTagsHandler)
+		r.Get("/following", app.getFollowedTagsHandler)
+
+		r.Route("/{tag}", func(r chi.Router) {
+			r.Get("/", app.getTagHandler)
+			r.Get("/posts", app.getTagPostsHandler)
+			r.Post("/follow", app.followTagHandler)
+			r.Delete("/follow", app.unfollowTagHandler)
+		})
+	})
+
+	// trash
+	r.Route("/trash", func(r chi.Router) {
+		r.Use(app.authCtxMiddleware)
+		r.Use(app.RequirePermission(security.PermUser))
+		r.Get("/", app.getTrashHandler)
+		r.Post("/post/{postID}/restore", app.restorePostHandler)
+		r.Post("/comment/{commentID}/restore", app.restoreCommentHandler)
+	})
+
+	// product catalog
+	r.Route("/product", func(r chi.Router) {
+		r.Use(app.authCtxMiddleware)
+		r.Use(app.RequirePermission(security.PermUser))
+		r.Get("/", app.browseProductsHandler)
+		r.With(app.RequirePermission(security.PermManufacturer)).
+			Post("/", app.createProductHandler)
+
+		r.Route("/{productID}", func(r chi.Router) {
+			r.Use(app.productCtxMiddleware)
+			r.Get("/", app.getProductHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequirePermission(security.PermManufacturer))
+				r.Use(app.RequireProductOwnership)
+				r.Patch("/", app.updateProductHandler)
+				r.Delete("/", app.deleteProductHandler)
+			})
+		})
+	})
+
+	// mood boards
+	r.Route("/board", func(r chi.Router) {
+		r.Use(app.authCtxMiddleware)
+		r.Use(app.RequirePermission(security.PermUser))
+		r.Post("/", app.createBoardHandler)
+		r.Get("/invites", app.getBoardInvitesHandler)
+
+		r.Route("/{boardID}", func(r chi.Router) {
+			r.Use(app.boardCtxMiddleware)
+			r.Get("/", app.getBoardHandler)
+			r.Get("/items", app.getBoardItemsHandler)
+
+			// collaborator invites
+			r.Post("/invite/accept", app.acceptBoardInviteHandler)
+			r.Post("/invite/decline", app.declineBoardInviteHandler)
+			r.Delete("/collaborators/{userID}", app.removeCollaboratorHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequireBoardEdit)
+				r.Post("/items", app.saveBoardItemHandler)
+				r.Put("/items/order", app.reorderBoardItemsHandler)
+				r.Patch("/items/{itemID}", app.updateBoardItemHandler)
+				r.Delete("/items/{itemID}", app.removeBoardItemHandler)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequireBoardOwnership)
+				r.Patch("/", app.updateBoardHandler)
+				r.Delete("/", app.deleteBoardHandler)
+				r.Post("/collaborators", app.inviteCollaboratorHandler)
+			})
+		})
+	})
+
+	// project workspaces
+	r.Route("/project", func(r chi.Router) {
+		r.Use(app.authCtxMiddleware)
+		r.Use(app.RequirePermission(security.PermUser))
+		r.Post("/", app.createProjectHandler)
+		r.Get("/", app.getMyProjectsHandler)
+
+		r.Route("/{projectID}", func(r chi.Router) {
+			r.Use(app.projectCtxMiddleware)
+			r.Get("/", app.getProjectHandler)
+			r.Get("/updates", app.getProjectUpdatesHandler)
+			r.Get("/tasks", app.getProjectTasksHandler)
+			r.Get("/documents", app.getProjectDocumentsHandler)
+			r.Get("/activity", app.getProjectActivityHandler)
+			// members can leave, managers remove others
+			r.Delete("/members/{userID}", app.removeProjectMemberHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequireProjectRole(storage.ProjectRoleMember))
+				r.Post("/updates", app.createProjectUpdateHandler)
+				r.Post("/tasks", app.createProjectTaskHandler)
+				r.Patch("/tasks/{taskID}", app.updateProjectTaskHandler)
+				r.Delete("/tasks/{taskID}", app.deleteProjectTaskHandler)
+				r.Post("/documents", app.addProjectDocumentHandler)
+				r.Delete("/documents/{documentID}", app.removeProjectDocumentHandler)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequireProjectRole(storage.ProjectRoleManager))
+				r.Patch("/", app.updateProjectHandler)
+				r.Post("/members", app.addProjectMemberHandler)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.RequireProjectRole(storage.ProjectRoleOwner))
+				r.Delete("/", app.deleteProjectHandler)
+				r.Patch("/members/{userID}", app.updateProjectMemberHandler)
 			})
 		})
 	})